@@ -1,255 +1,272 @@
 package apply
 
 import (
-	"bytes"
 	"context"
-	"io/ioutil"
-	"path/filepath"
-	"strings"
+	"os"
 
 	"github.com/pkg/errors"
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
-	existinginfrav1 "github.com/weaveworks/cluster-api-provider-existinginfra/apis/cluster.weave.works/v1alpha3"
-	"github.com/weaveworks/cluster-api-provider-existinginfra/pkg/apis/wksprovider/machine/config"
+	"github.com/spf13/pflag"
 	capeios "github.com/weaveworks/cluster-api-provider-existinginfra/pkg/apis/wksprovider/machine/os"
-	"github.com/weaveworks/cluster-api-provider-existinginfra/pkg/cluster/machine"
+	capeimachine "github.com/weaveworks/cluster-api-provider-existinginfra/pkg/cluster/machine"
+	"github.com/weaveworks/cluster-api-provider-existinginfra/pkg/plan"
 	capeispecs "github.com/weaveworks/cluster-api-provider-existinginfra/pkg/specs"
 	"github.com/weaveworks/cluster-api-provider-existinginfra/pkg/utilities/kubeadm"
-	"github.com/weaveworks/wksctl/pkg/addons"
-	wksos "github.com/weaveworks/wksctl/pkg/apis/wksprovider/machine/os"
 	"github.com/weaveworks/wksctl/pkg/manifests"
+	"github.com/weaveworks/wksctl/pkg/plan/events"
+	"github.com/weaveworks/wksctl/pkg/plan/journal"
 	"github.com/weaveworks/wksctl/pkg/plan/runners/ssh"
+	"github.com/weaveworks/wksctl/pkg/preflight"
+	"github.com/weaveworks/wksctl/pkg/seednode"
 	"github.com/weaveworks/wksctl/pkg/specs"
-	"github.com/weaveworks/wksctl/pkg/utilities"
-	"github.com/weaveworks/wksctl/pkg/utilities/manifest"
-	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha3"
+	"github.com/weaveworks/wksctl/pkg/utilities/logging"
+	"github.com/weaveworks/wksctl/pkg/utilities/path"
+	kubeadmapi "k8s.io/kubernetes/cmd/kubeadm/app/apis/kubeadm/v1beta1"
 )
 
 // Cmd represents the apply command
 var Cmd = &cobra.Command{
 	Use:   "apply",
 	Short: "Create or update a Kubernetes cluster",
-	RunE:  func(cmd *cobra.Command, _ []string) error { a := Applier{&globalParams}; return a.Apply(cmd.Context()) },
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		a := Applier{Params: &globalParams}
+		return a.Apply(cmd.Context())
+	},
 }
 
 type Params struct {
+	seednode.Options
+	sshOptions           ssh.Options
 	clusterManifestPath  string
 	machinesManifestPath string
-	controllerImage      string
-	gitURL               string
-	gitBranch            string
-	gitPath              string
-	gitDeployKeyPath     string
-	sshKeyPath           string
-	sealedSecretKeyPath  string
-	sealedSecretCertPath string
-	configDirectory      string
-	namespace            string
-	useManifestNamespace bool
-	addonNamespaces      []string
+	manifestsLocation    string
+	dryRun               bool
+	output               string
+	resume               bool
+	skipPreflight        bool
+	outputEvents         string
 }
 
 var globalParams Params
 
 func init() {
-	Cmd.Flags().StringVar(&globalParams.clusterManifestPath, "cluster", "cluster.yaml", "Location of cluster manifest")
-	Cmd.Flags().StringVar(&globalParams.machinesManifestPath, "machines", "machines.yaml", "Location of machines manifest")
-	Cmd.Flags().StringVar(&globalParams.gitURL, "git-url", "", "Git repo containing your cluster and machine information")
-	Cmd.Flags().StringVar(&globalParams.gitBranch, "git-branch", "master", "Git branch WKS should use to sync with your cluster")
-	Cmd.Flags().StringVar(&globalParams.gitPath, "git-path", ".", "Relative path to files in Git")
-	Cmd.Flags().StringVar(&globalParams.gitDeployKeyPath, "git-deploy-key", "", "Path to the Git deploy key")
-	Cmd.Flags().StringVar(&globalParams.sshKeyPath, "ssh-key", "./cluster-key", "Path to a key authorized to log in to machines by SSH")
-	Cmd.Flags().StringVar(&globalParams.sealedSecretKeyPath, "sealed-secret-key", "", "Path to a key used to decrypt sealed secrets")
-	Cmd.Flags().StringVar(&globalParams.sealedSecretCertPath, "sealed-secret-cert", "", "Path to a certificate used to encrypt sealed secrets")
-	Cmd.Flags().StringVar(&globalParams.configDirectory, "config-directory", ".", "Directory containing configuration information for the cluster")
-	Cmd.Flags().StringVar(&globalParams.namespace, "namespace", manifest.DefaultNamespace, "namespace override for WKS components")
-	Cmd.Flags().BoolVar(&globalParams.useManifestNamespace, "use-manifest-namespace", false, "use namespaces from supplied manifests (overriding any --namespace argument)")
-	Cmd.Flags().StringSliceVar(&globalParams.addonNamespaces, "addon-namespace", []string{"weave-net=kube-system"}, "override namespace for specific addons")
+	globalParams.AddFlags(Cmd.Flags())
+}
+
+// AddFlags registers the flags of the apply command, bound to p.
+func (p *Params) AddFlags(fs *pflag.FlagSet) {
+	fs.StringVar(&p.clusterManifestPath, "cluster", "cluster.yaml", "Location of cluster manifest")
+	fs.StringVar(&p.machinesManifestPath, "machines", "machines.yaml", "Location of machines manifest")
+	fs.StringVar(&p.manifestsLocation, "manifests", "", "Directory, or tar.gz bundle, possibly at an HTTPS URL, holding cluster.yaml, machines.yaml and the configuration of the cluster, instead of --cluster and --machines")
+	p.Options.AddFlags(fs)
+	p.sshOptions.AddFlags(fs)
+	fs.BoolVar(&p.dryRun, "dry-run", false, "Print the plan which would be applied to the seed node, without applying it")
+	fs.StringVarP(&p.output, "output", "o", "dot", "Output format of the plan printed by --dry-run (dot|json)")
+	fs.BoolVar(&p.resume, "resume", false, "Resume a failed apply, skipping the steps it completed")
+	fs.StringVar(&p.outputEvents, "output-events", "", "Print the progress of the apply as events on the standard output, one per line (json)")
+	fs.BoolVar(&p.skipPreflight, "skip-preflight", false, "Skip the checks of the machines run before setting them up (they are skipped by --dry-run and --resume too)")
 
 	// Hide controller-image flag as it is a helper/debug flag.
-	Cmd.Flags().StringVar(&globalParams.controllerImage, "controller-image", "", "Controller image override")
-	_ = Cmd.Flags().MarkHidden("controller-image")
+	fs.StringVar(&p.ControllerImage, "controller-image", "", "Controller image override")
+	_ = fs.MarkHidden("controller-image")
 }
 
 type Applier struct {
 	Params *Params
+	// events, if set, reports the progress of the apply.
+	events *events.Writer
 }
 
 func (a *Applier) Apply(ctx context.Context) error {
-	var clusterPath, machinesPath string
-
-	// TODO: deduplicate clusterPath/machinesPath evaluation between here and other places
-	// https://github.com/weaveworks/wksctl/issues/58
-	if a.Params.gitURL == "" {
-		// Cluster and Machine manifests come from the local filesystem.
-		clusterPath, machinesPath = a.Params.clusterManifestPath, a.Params.machinesManifestPath
-	} else {
-		// Cluster and Machine manifests come from a Git repo that we'll clone for the duration of this command.
-		repo, err := manifests.CloneClusterAPIRepo(a.Params.gitURL, a.Params.gitBranch, a.Params.gitDeployKeyPath, a.Params.gitPath)
-		if err != nil {
-			return errors.Wrap(err, "CloneClusterAPIRepo")
+	if err := events.ValidateOutputFormat(a.Params.outputEvents); err != nil {
+		return err
+	}
+	if a.Params.outputEvents != "" {
+		if a.Params.dryRun {
+			return errors.New("--dry-run and --output-events cannot be used together")
 		}
-		defer repo.Close()
+		a.events = events.NewWriter(os.Stdout)
+	}
 
-		if clusterPath, err = repo.ClusterManifestPath(); err != nil {
-			return errors.Wrap(err, "ClusterManifestPath")
+	if a.Params.dryRun {
+		if err := seednode.ValidateOutputFormat(a.Params.output); err != nil {
+			return err
 		}
-		if machinesPath, err = repo.MachinesManifestPath(); err != nil {
-			return errors.Wrap(err, "MachinesManifestPath")
+		if a.Params.resume {
+			return errors.New("--dry-run and --resume cannot be used together")
 		}
 	}
 
-	return a.initiateCluster(ctx, clusterPath, machinesPath)
-}
+	source, err := manifests.OpenSource(ctx, manifests.SourceOptions{
+		Location:        a.Params.manifestsLocation,
+		ClusterPath:     a.Params.clusterManifestPath,
+		MachinesPath:    a.Params.machinesManifestPath,
+		ConfigDirectory: a.Params.ConfigDirectory,
+		Git:             a.Params.Git(),
+	})
+	if err != nil {
+		return errors.Wrap(err, "failed to fetch manifests")
+	}
+	defer source.Close()
+	clusterPath, machinesPath, configDir, err := manifests.Paths(source)
+	if err != nil {
+		return err
+	}
+	a.Params.ConfigDirectory = configDir
+	// Host keys are only pinned in the user's own machines manifest: copies
+	// of remote manifests are removed once applied.
+	a.Params.sshOptions.RecordHostKeys = !a.Params.dryRun && manifests.IsLocal(source)
 
-// parseCluster converts the manifest file into a Cluster
-func parseCluster(clusterManifest []byte) (c *clusterv1.Cluster, eic *existinginfrav1.ExistingInfraCluster, err error) {
-	return capeispecs.ParseCluster(ioutil.NopCloser(bytes.NewReader(clusterManifest)))
+	return a.initiateCluster(ctx, clusterPath, machinesPath)
 }
 
 func (a *Applier) initiateCluster(ctx context.Context, clusterManifestPath, machinesManifestPath string) error {
 	sp := specs.NewFromPaths(clusterManifestPath, machinesManifestPath)
-	sshClient, err := ssh.NewClientForMachine(sp.MasterSpec, sp.ClusterSpec.User, a.Params.sshKeyPath, log.GetLevel() > log.InfoLevel)
-
+	if a.Params.ControllerSSHKeyPath == "" && sp.GetMachineCount() > 1 {
+		// The seed node would be set up alone.
+		return errors.New("the controller needs a key to set up the machines other than the seed node, provide it with --controller-ssh-key")
+	}
+	a.Params.sshOptions.MachinesPath = machinesManifestPath
+	// A dry run leaves the machines manifest and the known hosts as they are.
+	a.Params.sshOptions.KeepKnownHosts = a.Params.dryRun
+	if !a.Params.dryRun && !a.Params.resume && !a.Params.skipPreflight {
+		if err := a.preflight(ctx, sp, machinesManifestPath); err != nil {
+			return err
+		}
+	}
+	// The outputs of commands would be mixed with the events.
+	printOutputs := logging.Verbose() && a.events == nil
+	phaseDone := a.phase(events.SSHConnect)
+	sshClient, err := a.Params.sshOptions.NewClientForMachine(sp.Cluster, sp.MasterSpec, sp.ClusterSpec.User, printOutputs)
+	phaseDone(err)
 	if err != nil {
 		return errors.Wrap(err, "failed to create SSH client")
 	}
 	defer sshClient.Close()
+	phaseDone = a.phase(events.OSIdentification)
 	installer, err := capeios.Identify(ctx, sshClient)
+	phaseDone(err)
 	if err != nil {
 		return errors.Wrapf(err, "failed to identify operating system for seed node (%s)", sp.GetMasterPublicAddress())
 	}
 
-	// N.B.: we generate this bootstrap token where wksctl apply is run hoping
-	// that this will be on a machine which has been running for a while, and
-	// therefore will generate a "more random" token, than we would on a
-	// potentially newly created VM which doesn't have much entropy yet.
-	token, err := kubeadm.GenerateBootstrapToken()
-	if err != nil {
-		return errors.Wrap(err, "failed to generate bootstrap token")
+	if a.Params.dryRun {
+		p, err := a.Plan(ctx, installer, sp, clusterManifestPath, machinesManifestPath)
+		if err != nil {
+			return errors.Wrapf(err, "failed to create plan for seed node (%s)", sp.GetMasterPublicAddress())
+		}
+		return seednode.WritePlan(os.Stdout, p, a.Params.output)
 	}
 
-	// Point config dir at sync repo if using github and the user didn't override it
-	configDir := a.Params.configDirectory
-	if configDir == "." && a.Params.gitURL != "" {
-		configDir = filepath.Dir(clusterManifestPath)
+	j, err := a.journal(ctx, installer, sp)
+	if err != nil {
+		return err
 	}
-
-	ns := ""
-	if !a.Params.useManifestNamespace {
-		ns = a.Params.namespace
+	phaseDone = a.phase(events.PlanBuild)
+	p, err := a.Plan(ctx, installer, sp, clusterManifestPath, machinesManifestPath)
+	phaseDone(err)
+	if err != nil {
+		return errors.Wrapf(err, "failed to create plan for seed node (%s)", sp.GetMasterPublicAddress())
 	}
-
-	addonNamespaces := map[string]string{}
-	if len(a.Params.addonNamespaces) > 0 {
-		for _, entry := range a.Params.addonNamespaces {
-			parts := strings.SplitN(entry, "=", 2)
-			if len(parts) == 2 {
-				addonNamespaces[parts[0]] = parts[1]
-			} else {
-				return errors.Errorf("failed to validate the addon namespace (%s)", entry)
-			}
-		}
+	var listener journal.Listener
+	if a.events != nil {
+		listener = a.events
 	}
-
-	sealedSecretKeyPath := a.Params.sealedSecretKeyPath
-	if sealedSecretKeyPath == "" {
-		// Default to using the git deploy key to decrypt sealed secrets
-		sealedSecretKeyPath = a.Params.gitDeployKeyPath
+	journaled, err := journal.Wrap(p, j, a.Params.resume, listener)
+	if err != nil {
+		return err
 	}
 
-	// TODO(damien): Transform the controller image into an addon.
-	controllerImage := a.Params.controllerImage
-	if controllerImage != "" {
-		controllerImage, err = addons.UpdateImage(a.Params.controllerImage, sp.ClusterSpec.ImageRepository)
-		if err != nil {
-			return errors.Wrap(err, "failed to apply the cluster's image repository to the WKS controller's image")
+	if !a.Params.resume {
+		// Clean up what previous applies may have left behind. The original
+		// plan is undone, as it holds the undo conditions of nested plans.
+		if err := p.Undo(ctx, installer.Runner, plan.EmptyState); err != nil {
+			log.Infof("Pre-plan cleanup failed:\n%s\n", err)
+			return errors.Wrapf(err, "failed to set up seed node (%s)", sp.GetMasterPublicAddress())
 		}
+		j.Save(ctx)
 	}
-
-	clusterManifest, err := ioutil.ReadFile(clusterManifestPath)
+	phaseDone = a.phase(events.PlanApply)
+	_, err = journaled.Apply(ctx, installer.Runner, plan.EmptyDiff())
+	phaseDone(err)
 	if err != nil {
-		return errors.Wrap(err, "failed to read cluster manifest: ")
+		log.Errorf("Apply of Plan failed:\n%s\n", err)
+		return errors.Wrapf(err, "failed to set up seed node (%s), run apply again with --resume to continue from the failed step", sp.GetMasterPublicAddress())
 	}
 
-	// Read manifests and pass in the contents
-	machinesManifest, err := ioutil.ReadFile(machinesManifestPath)
-	if err != nil {
-		return errors.Wrap(err, "failed to read machines manifest: ")
-	}
+	return nil
+}
 
-	cluster, eic, err := parseCluster(clusterManifest)
+// preflight checks the machines can be set up, for problems to be reported
+// before, rather than halfway through, the seed node plan.
+func (a *Applier) preflight(ctx context.Context, sp *capeispecs.Specs, machinesManifestPath string) error {
+	machines, eiMachines, err := capeimachine.ParseManifest(machinesManifestPath)
 	if err != nil {
-		return errors.Wrap(err, "failed to parse cluster manifest: ")
+		return errors.Wrap(err, "failed to parse machines manifest")
 	}
+	log.Info("Running pre-flight checks")
+	phaseDone := a.phase(events.Preflight)
+	report := preflight.Check(ctx, machines, eiMachines, preflight.SSHConnector(&a.Params.sshOptions, sp.Cluster, sp.ClusterSpec.User))
+	out := os.Stdout
+	if a.events != nil {
+		// Keep the standard output to the events.
+		out = os.Stderr
+	}
+	if err := report.Write(out, "table"); err != nil {
+		return err
+	}
+	if report.Failed() {
+		err = errors.New("pre-flight checks failed, fix the machines or run apply again with --skip-preflight")
+	}
+	phaseDone(err)
+	return err
+}
 
-	// Allow for versions to be on machines only (for now)
-	if eic.Spec.KubernetesVersion == "" {
-		machines, _, err := machine.Parse(ioutil.NopCloser(bytes.NewReader(machinesManifest)))
-		if err != nil {
-			return errors.Wrap(err, "failed to parse machine manifest: ")
-		}
-
-		eic.Spec.KubernetesVersion = *machines[0].Spec.Version
+// phase reports the start of the provided phase of the apply, if events are
+// requested, and returns the function reporting its end.
+func (a *Applier) phase(phase string) func(error) {
+	if a.events == nil {
+		return func(error) {}
 	}
+	return a.events.Phase(phase)
+}
 
-	eic.Spec.DeprecatedSSHKeyPath = a.Params.sshKeyPath
-	clusterManifest, err = wksos.UnparseCluster(cluster, eic)
-	if err != nil {
-		return errors.Wrap(err, "failed to annotate cluster manifest: ")
+// journal returns the journal recording the progress of the apply: a new one,
+// or when resuming, the one of the previous apply, whose bootstrap token is
+// then reused.
+func (a *Applier) journal(ctx context.Context, installer *capeios.OS, sp *capeispecs.Specs) (*journal.Journal, error) {
+	stores := []journal.Store{
+		&journal.FileStore{Path: path.ApplyJournal("", a.Params.Namespace, sp.GetClusterName())},
+		&journal.RemoteStore{Runner: installer.Runner, Path: journal.SeedNodePath},
 	}
 
-	// Read sealed secret cert and key
-	var cert []byte
-	var key []byte
-	if utilities.FileExists(a.Params.sealedSecretCertPath) && utilities.FileExists(sealedSecretKeyPath) {
-		cert, err = ioutil.ReadFile(a.Params.sealedSecretCertPath)
+	if a.Params.resume {
+		j, err := journal.Load(ctx, stores...)
 		if err != nil {
-			return errors.Wrap(err, "failed to read sealed secret certificate: ")
+			return nil, errors.Wrap(err, "failed to resume apply")
 		}
-
-		key, err = ioutil.ReadFile(sealedSecretKeyPath)
+		token, err := kubeadmapi.NewBootstrapTokenString(j.BootstrapToken)
 		if err != nil {
-			return errors.Wrap(err, "failed to read sealed secret key: ")
+			return nil, errors.Wrap(err, "failed to read the bootstrap token from the journal")
 		}
+		a.Params.BootstrapToken = token
+		return j, nil
 	}
 
-	if err := wksos.SetupSeedNode(installer, capeios.SeedNodeParams{
-		PublicIP:             sp.GetMasterPublicAddress(),
-		PrivateIP:            sp.GetMasterPrivateAddress(),
-		ServicesCIDRBlocks:   sp.Cluster.Spec.ClusterNetwork.Services.CIDRBlocks,
-		PodsCIDRBlocks:       sp.Cluster.Spec.ClusterNetwork.Pods.CIDRBlocks,
-		ExistingInfraCluster: *eic,
-		ClusterManifest:      string(clusterManifest),
-		MachinesManifest:     string(machinesManifest),
-		BootstrapToken:       token,
-		KubeletConfig: config.KubeletConfig{
-			NodeIP:         sp.GetMasterPrivateAddress(),
-			CloudProvider:  sp.GetCloudProvider(),
-			ExtraArguments: sp.GetKubeletArguments(),
-		},
-		Controller: capeios.ControllerParams{
-			ImageOverride: controllerImage,
-		},
-		GitData: capeios.GitParams{
-			GitURL:           a.Params.gitURL,
-			GitBranch:        a.Params.gitBranch,
-			GitPath:          a.Params.gitPath,
-			GitDeployKeyPath: a.Params.gitDeployKeyPath,
-		},
-		SealedSecretKey:      string(key),
-		SealedSecretCert:     string(cert),
-		ConfigDirectory:      configDir,
-		ImageRepository:      sp.ClusterSpec.ImageRepository,
-		ControlPlaneEndpoint: sp.ClusterSpec.ControlPlaneEndpoint,
-		AdditionalSANs:       sp.ClusterSpec.APIServer.AdditionalSANs,
-		Namespace:            ns,
-		AddonNamespaces:      addonNamespaces,
-		Flavor:               sp.ClusterSpec.Flavor,
-	}); err != nil {
-		return errors.Wrapf(err, "failed to set up seed node (%s)", sp.GetMasterPublicAddress())
+	if a.Params.BootstrapToken == nil {
+		// Generated here rather than by seednode.Params, to be recorded in
+		// the journal.
+		token, err := kubeadm.GenerateBootstrapToken()
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to generate bootstrap token")
+		}
+		a.Params.BootstrapToken = token
 	}
+	return journal.New(a.Params.BootstrapToken.String(), stores...), nil
+}
 
-	return nil
+// Plan builds the plan setting up the seed node, as applied by Apply.
+func (a *Applier) Plan(ctx context.Context, installer *capeios.OS, sp *capeispecs.Specs, clusterManifestPath, machinesManifestPath string) (*plan.Plan, error) {
+	return seednode.Plan(ctx, installer, sp, clusterManifestPath, machinesManifestPath, a.Params.Options)
 }
@@ -12,6 +12,7 @@ import (
 	capeispecs "github.com/weaveworks/cluster-api-provider-existinginfra/pkg/specs"
 	"github.com/weaveworks/launcher/pkg/kubectl"
 	"github.com/weaveworks/wksctl/pkg/addons"
+	"github.com/weaveworks/wksctl/pkg/manifests"
 	"github.com/weaveworks/wksctl/pkg/specs"
 	"github.com/weaveworks/wksctl/pkg/utilities/manifest"
 	"github.com/weaveworks/wksctl/pkg/utilities/path"
@@ -27,6 +28,7 @@ var Cmd = &cobra.Command{
 var applyAddonsOptions struct {
 	clusterManifestPath  string
 	machinesManifestPath string
+	manifestsLocation    string
 	artifactDirectory    string
 	namespace            string
 }
@@ -35,6 +37,7 @@ func init() {
 	opts := &applyAddonsOptions
 	Cmd.Flags().StringVar(&opts.clusterManifestPath, "cluster", "cluster.yaml", "Location of cluster manifest")
 	Cmd.Flags().StringVar(&opts.machinesManifestPath, "machines", "machines.yaml", "Location of machines manifest")
+	Cmd.Flags().StringVar(&opts.manifestsLocation, "manifests", "", "Directory, or tar.gz bundle, possibly at an HTTPS URL, holding cluster.yaml, machines.yaml and the configuration of the cluster, instead of --cluster and --machines")
 	Cmd.Flags().StringVar(
 		&opts.artifactDirectory, "artifact-directory", "", "Location of WKS artifacts ")
 	Cmd.Flags().StringVar(
@@ -89,7 +92,20 @@ func applyAddonsUsingConfig(sp *capeispecs.Specs, basePath, kubeconfig string) e
 
 func applyAddonsRun(cmd *cobra.Command, args []string) {
 	opts := &applyAddonsOptions
-	sp := specs.NewFromPaths(opts.clusterManifestPath, opts.machinesManifestPath)
+	source, err := manifests.OpenSource(cmd.Context(), manifests.SourceOptions{
+		Location:     opts.manifestsLocation,
+		ClusterPath:  opts.clusterManifestPath,
+		MachinesPath: opts.machinesManifestPath,
+	})
+	if err != nil {
+		log.Fatal("Error fetching manifests: ", err)
+	}
+	defer source.Close()
+	clusterPath, machinesPath, _, err := manifests.Paths(source)
+	if err != nil {
+		log.Fatal("Error fetching manifests: ", err)
+	}
+	sp := specs.NewFromPaths(clusterPath, machinesPath)
 	configPath := path.Kubeconfig(opts.artifactDirectory, applyAddonsOptions.namespace, sp.GetClusterName())
 
 	if !configExists(configPath) {
@@ -100,7 +116,7 @@ func applyAddonsRun(cmd *cobra.Command, args []string) {
 
 	}
 
-	if err := applyAddonsUsingConfig(sp, filepath.Dir(opts.clusterManifestPath), configPath); err != nil {
+	if err := applyAddonsUsingConfig(sp, filepath.Dir(clusterPath), configPath); err != nil {
 		log.Fatal("Error applying addons: ", err)
 	}
 }
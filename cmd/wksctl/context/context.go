@@ -0,0 +1,28 @@
+package context
+
+import (
+	"github.com/spf13/cobra"
+	"github.com/weaveworks/wksctl/cmd/wksctl/context/current"
+	"github.com/weaveworks/wksctl/cmd/wksctl/context/list"
+	"github.com/weaveworks/wksctl/cmd/wksctl/context/use"
+)
+
+// Cmd represents the context command
+var Cmd = &cobra.Command{
+	Use:   "context",
+	Short: "Manage the contexts of the wksctl configuration",
+	Long: `Manage the contexts of the wksctl configuration, ~/.wksctl/config.yaml unless
+set by WKSCTL_CONFIG. Contexts hold the values of the --cluster, --machines,
+--ssh-key, --git-url, --git-branch, --git-path, --git-deploy-key and
+--namespace flags, which commands use unless the flags are set on the command
+line, or by WKSCTL_* environment variables (e.g. WKSCTL_SSH_KEY for --ssh-key).
+The location of the manifests held by a context is ignored if --cluster,
+--machines, --manifests or --git-url is set.
+Commands use the current context, unless --context selects another one.`,
+}
+
+func init() {
+	Cmd.AddCommand(current.Cmd)
+	Cmd.AddCommand(list.Cmd)
+	Cmd.AddCommand(use.Cmd)
+}
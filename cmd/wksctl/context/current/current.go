@@ -0,0 +1,29 @@
+package current
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"github.com/weaveworks/wksctl/pkg/config"
+)
+
+// Cmd represents the context current command
+var Cmd = &cobra.Command{
+	Use:   "current",
+	Short: "Print the current context",
+	Args:  cobra.NoArgs,
+	RunE:  currentRun,
+}
+
+func currentRun(cmd *cobra.Command, args []string) error {
+	c, err := config.Load(config.Path())
+	if err != nil {
+		return err
+	}
+	if c.CurrentContext == "" {
+		return errors.New("no current context, set one with wksctl context use")
+	}
+	fmt.Println(c.CurrentContext)
+	return nil
+}
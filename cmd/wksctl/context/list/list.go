@@ -0,0 +1,37 @@
+package list
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	"github.com/weaveworks/wksctl/pkg/config"
+)
+
+// Cmd represents the context list command
+var Cmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the contexts, marking the current one",
+	Args:  cobra.NoArgs,
+	RunE:  listRun,
+}
+
+func listRun(cmd *cobra.Command, args []string) error {
+	c, err := config.Load(config.Path())
+	if err != nil {
+		return err
+	}
+	const tabWidth = 4
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, tabWidth, ' ', 0)
+	fmt.Fprintln(w, "CURRENT\tNAME\tGIT URL\tCLUSTER")
+	for _, name := range c.ContextNames() {
+		current := ""
+		if name == c.CurrentContext {
+			current = "*"
+		}
+		context := c.Contexts[name]
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", current, name, context.GitURL, context.Cluster)
+	}
+	return w.Flush()
+}
@@ -0,0 +1,32 @@
+package use
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/weaveworks/wksctl/pkg/config"
+)
+
+// Cmd represents the context use command
+var Cmd = &cobra.Command{
+	Use:   "use <name>",
+	Short: "Set the current context",
+	Args:  cobra.ExactArgs(1),
+	RunE:  useRun,
+}
+
+func useRun(cmd *cobra.Command, args []string) error {
+	path := config.Path()
+	c, err := config.Load(path)
+	if err != nil {
+		return err
+	}
+	if _, err := c.Context(args[0]); err != nil {
+		return err
+	}
+	if err := config.SetCurrentContext(path, args[0]); err != nil {
+		return err
+	}
+	fmt.Printf("Switched to context %q\n", args[0])
+	return nil
+}
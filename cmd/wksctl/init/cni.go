@@ -0,0 +1,177 @@
+package init
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"github.com/weaveworks/wksctl/pkg/specs"
+	"github.com/weaveworks/wksctl/pkg/utilities/manifest"
+	yaml "gopkg.in/yaml.v3"
+)
+
+// cniUpdater sets the pod CIDR block of the cluster in the manifests of a CNI
+// plugin.
+type cniUpdater struct {
+	name string
+	// detect returns the object of the manifests of the plugin holding its
+	// pod CIDR block, or nil if the manifests are not the plugin's.
+	detect func(*manifest.File) *yaml.Node
+	update func(f *manifest.File, object *yaml.Node, podCIDRBlock string) error
+}
+
+var cniUpdaters = []cniUpdater{
+	{name: "weave-net", detect: daemonSetWithContainer("weave"), update: containerEnv("weave", "IPALLOC_RANGE")},
+	{name: "calico", detect: daemonSetWithContainer("calico-node"), update: containerEnv("calico-node", "CALICO_IPV4POOL_CIDR")},
+	{name: "cilium", detect: configMap("cilium-config"), update: configMapData("cluster-pool-ipv4-cidr")},
+}
+
+func cniNames() string {
+	var names []string
+	for _, u := range cniUpdaters {
+		names = append(names, u.name)
+	}
+	return strings.Join(names, ", ")
+}
+
+// detectors
+func daemonSetWithContainer(name string) func(*manifest.File) *yaml.Node {
+	return func(f *manifest.File) *yaml.Node {
+		for _, object := range f.Objects {
+			if manifest.Scalar(object, "kind") == "DaemonSet" && container(object, name) != nil {
+				return object
+			}
+		}
+		return nil
+	}
+}
+
+func configMap(name string) func(*manifest.File) *yaml.Node {
+	return func(f *manifest.File) *yaml.Node {
+		for _, object := range f.Objects {
+			if manifest.Scalar(object, "kind") == "ConfigMap" && manifest.Scalar(object, "metadata", "name") == name {
+				return object
+			}
+		}
+		return nil
+	}
+}
+
+// container returns the container of the pod template of the object with the
+// provided name, or nil.
+func container(object *yaml.Node, name string) *yaml.Node {
+	containers := manifest.Field(object, "spec", "template", "spec", "containers")
+	if containers == nil {
+		return nil
+	}
+	for _, c := range containers.Content {
+		if manifest.Scalar(c, "name") == name {
+			return c
+		}
+	}
+	return nil
+}
+
+// updaters
+func containerEnv(containerName, envName string) func(*manifest.File, *yaml.Node, string) error {
+	return func(f *manifest.File, object *yaml.Node, value string) error {
+		env := manifest.Field(container(object, containerName), "env")
+		if env == nil || env.Kind != yaml.SequenceNode {
+			return errors.Errorf("container %s has no env to set %s in", containerName, envName)
+		}
+		for _, item := range env.Content {
+			if manifest.Scalar(item, "name") != envName {
+				continue
+			}
+			if current := manifest.Field(item, "value"); current != nil && current.Kind == yaml.ScalarNode {
+				f.Set(item, current, value)
+				return nil
+			}
+			return errors.Errorf("%s of container %s is not set by value", envName, containerName)
+		}
+		if len(env.Content) == 0 || env.Content[0].Kind != yaml.MappingNode || len(env.Content[0].Content) == 0 {
+			return errors.Errorf("cannot add %s to the env of container %s", envName, containerName)
+		}
+		return f.InsertBefore(env.Content[0].Content[0], "name: "+envName, "value: "+strconv.Quote(value))
+	}
+}
+
+func configMapData(key string) func(*manifest.File, *yaml.Node, string) error {
+	return func(f *manifest.File, object *yaml.Node, value string) error {
+		data := manifest.Field(object, "data")
+		if data == nil || data.Kind != yaml.MappingNode || len(data.Content) == 0 {
+			return errors.Errorf("ConfigMap %s has no data to set %s in", manifest.Scalar(object, "metadata", "name"), key)
+		}
+		if current := manifest.Field(data, key); current != nil {
+			f.Set(data, current, value)
+			return nil
+		}
+		return f.InsertBefore(data.Content[0], key+": "+strconv.Quote(value))
+	}
+}
+
+// podCIDRBlock returns the pod CIDR block of the cluster manifest, or "".
+func podCIDRBlock(options initOptionType) string {
+	clusterManifestPath := path.Join(options.localRepoDirectory, options.clusterManifestPath)
+	machinesManifestPath := path.Join(options.localRepoDirectory, options.machinesManifestPath)
+	sp := specs.NewFromPaths(clusterManifestPath, machinesManifestPath)
+
+	podsCIDRBlocks := sp.Cluster.Spec.ClusterNetwork.Pods.CIDRBlocks
+	if len(podsCIDRBlocks) > 0 {
+		return podsCIDRBlocks[0]
+	}
+	return ""
+}
+
+// updateCNIManifests sets the pod CIDR block in the manifests of the file if
+// they are the ones of a CNI plugin, and returns the names of the plugins.
+func updateCNIManifests(filePath string, info os.FileInfo, options initOptionType) ([]string, error) {
+	contents, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+	f, err := manifest.ParseFile(contents)
+	if err != nil {
+		// Not all YAML files of the repository are manifests.
+		log.Debugf("Skipping %s: %v", filePath, err)
+		return nil, nil
+	}
+	var found []cniUpdater
+	var objects []*yaml.Node
+	for _, u := range cniUpdaters {
+		if object := u.detect(f); object != nil {
+			log.Debugf("Found %s manifests in %s", u.name, filePath)
+			found = append(found, u)
+			objects = append(objects, object)
+		}
+	}
+	if len(found) == 0 {
+		return nil, nil
+	}
+	var names []string
+	for _, u := range found {
+		names = append(names, u.name)
+	}
+	cidr := podCIDRBlock(options)
+	if cidr == "" {
+		log.Debugf("No change to %s manifests", strings.Join(names, ", "))
+		return names, nil
+	}
+	for i, u := range found {
+		if err := u.update(f, objects[i], cidr); err != nil {
+			return nil, errors.Wrapf(err, "failed to set the pod CIDR block of %s in %s", u.name, filePath)
+		}
+	}
+	if !f.Changed() {
+		return names, nil
+	}
+	newContents, err := f.Bytes()
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to update %s", filePath)
+	}
+	return names, ioutil.WriteFile(filePath, newContents, info.Mode())
+}
@@ -0,0 +1,130 @@
+package init
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/weaveworks/wksctl/pkg/utilities/manifest"
+)
+
+const calicoNode = `---
+# Source: calico/templates/calico-node.yaml
+kind: DaemonSet
+apiVersion: apps/v1
+metadata:
+  name: calico-node
+  namespace: kube-system
+spec:
+  template:
+    spec:
+      containers:
+        - name: calico-node
+          image: calico/node:v3.17.1
+          env:
+            - name: DATASTORE_TYPE
+              value: "kubernetes"
+            # The default IPv4 pool to create on startup if none exists.
+            # - name: CALICO_IPV4POOL_CIDR
+            #   value: "192.168.0.0/16"
+            - name: CALICO_DISABLE_FILE_LOGGING
+              value: "true"
+`
+
+const ciliumConfig = `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: cilium-config
+  namespace: kube-system
+data:
+  # Pod CIDR of the cluster.
+  cluster-pool-ipv4-cidr: "10.0.0.0/8"
+  cluster-pool-ipv4-mask-size: "24"
+`
+
+const weaveNet = `apiVersion: v1
+kind: List
+items:
+  - apiVersion: apps/v1
+    kind: DaemonSet
+    metadata:
+      name: weave-net
+    spec:
+      template:
+        spec:
+          containers:
+            - name: weave
+              env:
+                - name: HOSTNAME
+                  valueFrom:
+                    fieldRef:
+                      fieldPath: spec.nodeName
+                - name: IPALLOC_RANGE
+                  value: 10.32.0.0/12
+`
+
+func TestCNIUpdaters(t *testing.T) {
+	for _, test := range []struct {
+		name, manifests, expected string
+	}{
+		{"calico", calicoNode, strings.Replace(calicoNode, "            - name: DATASTORE_TYPE", "            - name: CALICO_IPV4POOL_CIDR\n              value: \"172.16.0.0/16\"\n            - name: DATASTORE_TYPE", 1)},
+		{"calico", strings.Replace(calicoNode, "# - name: CALICO_IPV4POOL_CIDR\n            #   value:", "- name: CALICO_IPV4POOL_CIDR\n              value:", 1),
+			strings.Replace(calicoNode, "# - name: CALICO_IPV4POOL_CIDR\n            #   value: \"192.168.0.0/16\"", "- name: CALICO_IPV4POOL_CIDR\n              value: \"172.16.0.0/16\"", 1)},
+		{"cilium", ciliumConfig, strings.Replace(ciliumConfig, "10.0.0.0/8", "172.16.0.0/16", 1)},
+		{"cilium", strings.Replace(ciliumConfig, "  cluster-pool-ipv4-cidr: \"10.0.0.0/8\"\n", "", 1),
+			strings.Replace(ciliumConfig, "  # Pod CIDR of the cluster.\n  cluster-pool-ipv4-cidr: \"10.0.0.0/8\"\n", "  cluster-pool-ipv4-cidr: \"172.16.0.0/16\"\n  # Pod CIDR of the cluster.\n", 1)},
+		{"weave-net", weaveNet, strings.Replace(weaveNet, "10.32.0.0/12", "172.16.0.0/16", 1)},
+	} {
+		f, err := manifest.ParseFile([]byte(test.manifests))
+		require.NoError(t, err)
+		var detected []string
+		for _, u := range cniUpdaters {
+			if object := u.detect(f); object != nil {
+				detected = append(detected, u.name)
+				require.NoError(t, u.update(f, object, "172.16.0.0/16"))
+			}
+		}
+		assert.Equal(t, []string{test.name}, detected)
+		res, err := f.Bytes()
+		require.NoError(t, err)
+		assert.Equal(t, test.expected, string(res), test.name)
+	}
+}
+
+func TestUpdateManifests(t *testing.T) {
+	dir, err := ioutil.TempDir("", "wksctl-init")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	for _, name := range []string{"cluster.yaml", "machines.yaml"} {
+		contents, err := ioutil.ReadFile(filepath.Join("../../../examples/vagrant", name))
+		require.NoError(t, err)
+		require.NoError(t, ioutil.WriteFile(filepath.Join(dir, name), contents, 0644))
+	}
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "flux.yaml"), []byte(fluxInputs), 0644))
+	require.NoError(t, os.Mkdir(filepath.Join(dir, "templates"), 0755))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "templates", "values.yaml"), []byte("image: {{ .Image }}\n"), 0644))
+	options := initOptionType{
+		localRepoDirectory:   dir,
+		gitURL:               "git@github.com:weaveworks/foo.bar",
+		gitBranch:            "main",
+		gitPath:              ".",
+		namespace:            "weavek8sops",
+		clusterManifestPath:  "cluster.yaml",
+		machinesManifestPath: "machines.yaml",
+	}
+
+	err = updateManifests(options)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "no CNI manifest found")
+
+	calicoPath := filepath.Join(dir, "calico.yaml")
+	require.NoError(t, ioutil.WriteFile(calicoPath, []byte(calicoNode), 0644))
+	require.NoError(t, updateManifests(options))
+	contents, err := ioutil.ReadFile(calicoPath)
+	require.NoError(t, err)
+	assert.Contains(t, string(contents), "- name: CALICO_IPV4POOL_CIDR\n              value: \"192.168.0.0/16\"\n            - name: DATASTORE_TYPE")
+}
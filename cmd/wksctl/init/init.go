@@ -4,19 +4,18 @@ import (
 	"fmt"
 	"io/ioutil"
 	"os"
-	"path"
 	"path/filepath"
-	"regexp"
 	"strings"
 
 	"github.com/pkg/errors"
 
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
-	capeios "github.com/weaveworks/cluster-api-provider-existinginfra/pkg/apis/wksprovider/machine/os"
-	"github.com/weaveworks/wksctl/pkg/specs"
+	"github.com/weaveworks/cluster-api-provider-existinginfra/pkg/kubernetes"
 	"github.com/weaveworks/wksctl/pkg/utilities/manifest"
 	"github.com/weaveworks/wksctl/pkg/version"
+	"golang.org/x/crypto/ssh/terminal"
+	yaml "gopkg.in/yaml.v3"
 )
 
 // A command that initializes a user's cloned git repository with updated git information for flux manifests.
@@ -31,6 +30,9 @@ type initOptionType struct {
 	version              string
 	clusterManifestPath  string
 	machinesManifestPath string
+	scaffold             bool
+	os                   string
+	kubernetesVersion    string
 }
 
 type manifestUpdate struct {
@@ -44,7 +46,7 @@ var (
 	Cmd = &cobra.Command{
 		Use:          "init",
 		Short:        "Update stored kubernetes manifests to match the local cluster environment",
-		Long:         "'wksctl init' configures existing kubernetes 'flux.yaml', and 'weave-net.yaml' manifests in a repository with information about the local GitOps repository, the preferred weave system namespace, and current container image tags. The files can be anywhere in the repository. If either file is absent, 'wksctl init' will return an error.",
+		Long:         "'wksctl init' configures existing kubernetes 'flux.yaml' manifests in a repository with information about the local GitOps repository, the preferred weave system namespace, and current container image tags, and sets the pod CIDR block of the cluster in the manifests of its CNI plugin: weave-net, Calico or Cilium, detected from the manifests. The files can be anywhere in the repository. If the flux or the CNI manifests are absent, 'wksctl init' will return an error. With --scaffold, 'wksctl init' instead creates the cluster, machines, flux, weave-net and repo-config manifests of a new cluster for an operating system and a Kubernetes version, and validates them; existing files of the repository are never overwritten.",
 		Example:      "wksctl init --namespace=wksctl --git-url=git@github.com:haskellcurry/lambda.git --git-branch=development --git-path=src",
 		RunE:         initRun,
 		SilenceUsage: true,
@@ -52,22 +54,10 @@ var (
 
 	initOptions initOptionType
 
-	namespacePrefixPattern = "kind: Namespace\n  metadata:\n    name: "
-	namespaceNamePattern   = multiLineRegexp(namespacePrefixPattern + `\S+`)
-	namespacePattern       = multiLineRegexp(`namespace:\s*\S+`)
-	gitURLPattern          = multiLineRegexp(`(--git-url)=\S+`)
-	gitBranchPattern       = multiLineRegexp(`(--git-branch)=\S+`)
-	gitPathPattern         = multiLineRegexp(`(--git-path)=\S+`)
-
 	updates = []manifestUpdate{
-		{name: "weave-net", selector: equal("weave-net.yaml"), updater: updateWeaveNetManifests},
 		{name: "flux", selector: and(prefix("flux"), extension("yaml")), updater: updateFluxManifests}}
 )
 
-func multiLineRegexp(pattern string) *regexp.Regexp {
-	return regexp.MustCompile(`(?m)` + pattern)
-}
-
 func init() {
 	Cmd.Flags().StringVar(
 		&initOptions.localRepoDirectory, "gitk8s-clone", ".", "Local location of cloned git repository")
@@ -82,16 +72,16 @@ func init() {
 	Cmd.Flags().StringVar(&initOptions.machinesManifestPath, "machines", "machines.yaml", "Location of machines manifest")
 	Cmd.Flags().StringVar(
 		&initOptions.dependencyPath, "dependency-file", "./dependencies.toml", "path to file containing version information for all dependencies")
+	Cmd.Flags().BoolVar(&initOptions.scaffold, "scaffold", false,
+		"Create the cluster, machines, flux, weave-net and repo-config manifests of a new cluster in the repository")
+	Cmd.Flags().StringVar(&initOptions.os, "os", "centos7",
+		fmt.Sprintf("Operating system of the machines of the new cluster (%s), asked for if not set on a terminal", strings.Join(operatingSystems(), ", ")))
+	Cmd.Flags().StringVar(&initOptions.kubernetesVersion, "kubernetes-version", kubernetes.DefaultVersion,
+		"Kubernetes version of the new cluster, asked for if not set on a terminal")
 	_ = Cmd.MarkPersistentFlagRequired("git-url")
 }
 
 // selectors
-func equal(name string) func([]byte) bool {
-	return func(fname []byte) bool {
-		return name == string(fname)
-	}
-}
-
 func prefix(pre string) func([]byte) bool {
 	return func(fname []byte) bool {
 		return strings.HasPrefix(string(fname), pre)
@@ -114,41 +104,77 @@ func and(checks ...func([]byte) bool) func([]byte) bool {
 	}
 }
 
-func updatedArg(item string) []byte {
-	return []byte(fmt.Sprintf("$1=%s", item))
-}
+// updateFluxManifests moves the objects of flux to the namespace of the
+// options, and points flux to their Git repository. Only the Namespace
+// objects, the objects in those namespaces or in the one of the flux
+// Deployment, and the arguments of the flux container are changed.
+func updateFluxManifests(contents []byte, options initOptionType) ([]byte, error) {
+	f, err := manifest.ParseFile(contents)
+	if err != nil {
+		return nil, err
+	}
 
-func updateWeaveNetManifests(contents []byte, options initOptionType) ([]byte, error) {
-	clusterManifestPath := (path.Join(options.localRepoDirectory, options.clusterManifestPath))
-	machinesManifestPath := (path.Join(options.localRepoDirectory, options.machinesManifestPath))
-	sp := specs.NewFromPaths(clusterManifestPath, machinesManifestPath)
-
-	podsCIDRBlocks := sp.Cluster.Spec.ClusterNetwork.Pods.CIDRBlocks
-	if len(podsCIDRBlocks) > 0 && podsCIDRBlocks[0] != "" {
-		// setting the pod CIDR block is currently only supported for the weave-net CNI
-		log.Debug("Updating weave-net manifest.")
-		manifests, err := capeios.SetWeaveNetPodCIDRBlock([][]byte{contents}, podsCIDRBlocks[0])
-		if err != nil {
-			return nil, errors.Wrap(err, "failed to inject ipalloc_range")
+	moved := map[string]bool{}
+	for _, object := range f.Objects {
+		switch manifest.Scalar(object, "kind") {
+		case "Namespace":
+			moved[manifest.Scalar(object, "metadata", "name")] = true
+			f.SetField(object, options.namespace, "metadata", "name")
+		case "Deployment":
+			if flux := container(object, "flux"); flux != nil {
+				moved[manifest.Scalar(object, "metadata", "namespace")] = true
+				updateFluxArgs(f, flux, options)
+			}
 		}
-		return manifests[0], nil
 	}
+	delete(moved, "")
 
-	log.Debug("No change to weave-net manifest")
-	return contents, nil
+	for _, object := range f.Objects {
+		if moved[manifest.Scalar(object, "metadata", "namespace")] {
+			f.SetField(object, options.namespace, "metadata", "namespace")
+		}
+		// Bindings refer to the service account of flux by namespace.
+		if subjects := manifest.Field(object, "subjects"); subjects != nil {
+			for _, subject := range subjects.Content {
+				if moved[manifest.Scalar(subject, "namespace")] {
+					f.SetField(subject, options.namespace, "namespace")
+				}
+			}
+		}
+	}
+	return f.Bytes()
 }
 
-func updateFluxManifests(contents []byte, options initOptionType) ([]byte, error) {
-	withNamespaceName := namespaceNamePattern.ReplaceAll(contents, []byte(namespacePrefixPattern+options.namespace))
-	withNamespace := namespacePattern.ReplaceAll(withNamespaceName, []byte(`namespace: `+options.namespace))
-	withGitURL := gitURLPattern.ReplaceAll(withNamespace, updatedArg(options.gitURL))
-	withGitBranch := gitBranchPattern.ReplaceAll(withGitURL, updatedArg(options.gitBranch))
-	withGitPath := gitPathPattern.ReplaceAll(withGitBranch, updatedArg(options.gitPath))
-	return withGitPath, nil
+func updateFluxArgs(f *manifest.File, container *yaml.Node, options initOptionType) {
+	args := manifest.Field(container, "args")
+	if args == nil {
+		return
+	}
+	values := map[string]string{
+		"--git-url":    options.gitURL,
+		"--git-branch": options.gitBranch,
+		"--git-path":   options.gitPath,
+	}
+	for _, arg := range args.Content {
+		if arg.Kind != yaml.ScalarNode {
+			continue
+		}
+		name := strings.SplitN(arg.Value, "=", 2)[0]
+		if value, ok := values[name]; ok {
+			f.Set(args, arg, name+"="+value)
+			delete(values, name)
+		}
+	}
+	for _, name := range []string{"--git-url", "--git-branch", "--git-path"} {
+		if _, ok := values[name]; ok {
+			log.Warnf("The flux container has no %s argument", name)
+		}
+	}
 }
 
 func updateManifests(options initOptionType) error {
 	found := map[string]bool{}
+	var cnis []string
 	err := filepath.Walk(options.localRepoDirectory,
 		func(path string, info os.FileInfo, err error) error {
 			if err != nil {
@@ -158,10 +184,12 @@ func updateManifests(options initOptionType) error {
 				return filepath.SkipDir
 			}
 			fname := []byte(info.Name())
+			matched := false
 			for _, u := range updates {
 				if u.selector(fname) {
 					log.Debugf("Matched %s", fname)
 					found[u.name] = true
+					matched = true
 					contents, err := ioutil.ReadFile(path)
 					if err != nil {
 						return err
@@ -176,17 +204,40 @@ func updateManifests(options initOptionType) error {
 					// Don't break; if multiple files "match", make sure we update all of them
 				}
 			}
+			if !matched && (extension("yaml")(fname) || extension("yml")(fname)) {
+				names, err := updateCNIManifests(path, info, options)
+				if err != nil {
+					return err
+				}
+				cnis = append(cnis, names...)
+			}
 			return nil
 		})
+	if err != nil {
+		return err
+	}
 	if !found["flux"] {
 		return errors.New("'flux.yaml' must be present in the repository")
 	}
-	return err
+	if len(cnis) == 0 {
+		return errors.Errorf("no CNI manifest found in %s, 'wksctl init' supports %s", options.localRepoDirectory, cniNames())
+	}
+	log.Debugf("Found the manifests of %s", strings.Join(cnis, ", "))
+	return nil
 }
 
 func initRun(cmd *cobra.Command, args []string) error {
 	if initOptions.version == "" {
 		initOptions.version = version.Version // from main command
 	}
+	if initOptions.scaffold {
+		osSet, versionSet := cmd.Flags().Changed("os"), cmd.Flags().Changed("kubernetes-version")
+		if (!osSet || !versionSet) && terminal.IsTerminal(int(os.Stdin.Fd())) {
+			if err := promptScaffoldOptions(&initOptions, os.Stdin, os.Stderr, osSet, versionSet); err != nil {
+				return err
+			}
+		}
+		return scaffoldRepository(initOptions)
+	}
 	return updateManifests(initOptions)
 }
@@ -173,3 +173,122 @@ func TestFluxTranslate(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, string(res), fluxOutputs)
 }
+
+const fluxDocuments = `# Flux, syncing the cluster with its repository.
+---
+apiVersion: v1
+kind: Namespace
+metadata:
+  name: weavek8sops # The namespace of WKS.
+---
+apiVersion: v1
+kind: ServiceAccount
+metadata:
+  name: flux
+  namespace: weavek8sops
+---
+apiVersion: rbac.authorization.k8s.io/v1
+kind: ClusterRoleBinding
+metadata:
+  name: flux
+roleRef:
+  apiGroup: rbac.authorization.k8s.io
+  kind: ClusterRole
+  name: flux
+subjects:
+  - kind: ServiceAccount
+    name: flux
+    namespace: weavek8sops
+---
+# Not part of flux.
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: coredns-custom
+  namespace: kube-system
+data:
+  override: |
+    namespace: weavek8sops
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: flux
+  namespace: weavek8sops
+spec:
+  template:
+    spec:
+      containers:
+      - name: memcached
+        args: [--git-url=unrelated]
+      - name: flux
+        # Repository synced with the cluster.
+        args: ["--git-url=git@github.com:weaveworks/wkp-test.git", '--git-branch=master', --git-path=., --git-poll-interval=30s]
+`
+
+const fluxDocumentsOutputs = `# Flux, syncing the cluster with its repository.
+---
+apiVersion: v1
+kind: Namespace
+metadata:
+  name: blonskar # The namespace of WKS.
+---
+apiVersion: v1
+kind: ServiceAccount
+metadata:
+  name: flux
+  namespace: blonskar
+---
+apiVersion: rbac.authorization.k8s.io/v1
+kind: ClusterRoleBinding
+metadata:
+  name: flux
+roleRef:
+  apiGroup: rbac.authorization.k8s.io
+  kind: ClusterRole
+  name: flux
+subjects:
+  - kind: ServiceAccount
+    name: flux
+    namespace: blonskar
+---
+# Not part of flux.
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: coredns-custom
+  namespace: kube-system
+data:
+  override: |
+    namespace: weavek8sops
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: flux
+  namespace: blonskar
+spec:
+  template:
+    spec:
+      containers:
+      - name: memcached
+        args: [--git-url=unrelated]
+      - name: flux
+        # Repository synced with the cluster.
+        args: ["--git-url=https://github.com/weaveworks/foo.git", '--git-branch=rickey''s', "--git-path=clusters/a,b", --git-poll-interval=30s]
+`
+
+func TestFluxDocuments(t *testing.T) {
+	res, err := updateFluxManifests([]byte(fluxDocuments),
+		initOptionType{
+			namespace: "blonskar",
+			gitURL:    "https://github.com/weaveworks/foo.git",
+			gitBranch: "rickey's",
+			gitPath:   "clusters/a,b",
+		})
+	assert.NoError(t, err)
+	assert.Equal(t, fluxDocumentsOutputs, string(res))
+
+	_, err = updateFluxManifests([]byte("kind: [Namespace"), initOptionType{namespace: "blonskar"})
+	assert.Error(t, err)
+}
@@ -0,0 +1,255 @@
+package init
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"github.com/weaveworks/wksctl/pkg/addons"
+	"github.com/weaveworks/wksctl/pkg/scaffold"
+	"github.com/weaveworks/wksctl/pkg/specs"
+)
+
+// 'wksctl init --scaffold' creates the manifests of a new cluster, instead of
+// updating the ones of a repository.
+
+const scaffoldClusterName = "example"
+
+// osFile is a file installed on the machines, from the repo ConfigMap.
+type osFile struct {
+	Key         string
+	Destination string
+	Contents    string
+}
+
+// osFiles are the files installed on the machines of each supported operating
+// system, read from the os directory of the templates.
+var osFiles = map[string][]osFile{
+	"centos7": {
+		{Key: "kubernetes.repo", Destination: "/etc/yum.repos.d/kubernetes.repo"},
+		{Key: "docker-ce.repo", Destination: "/etc/yum.repos.d/docker-ce.repo"},
+	},
+	// The Kubernetes APT repository is set up by wksctl, with this key.
+	"ubuntu18.04": {
+		{Key: "cloud-google-com.gpg.b64", Destination: "/tmp/cloud-google-com.gpg.b64"},
+	},
+}
+
+func operatingSystems() []string {
+	var names []string
+	for name := range osFiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+type scaffoldValues struct {
+	ClusterName       string
+	KubernetesVersion string
+	Namespace         string
+	GitURL            string
+	GitBranch         string
+	GitPath           string
+	Files             []osFile
+}
+
+var scaffoldTemplates = []string{"flux.yaml", "repo-config.yaml"}
+
+// indent indents the lines of the text by n spaces, to embed it in a block
+// scalar.
+func indent(n int, text string) string {
+	lines := strings.Split(strings.TrimRight(text, "\n"), "\n")
+	for i, line := range lines {
+		if line != "" {
+			lines[i] = strings.Repeat(" ", n) + line
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+func readTemplate(name string) (string, error) {
+	f, err := scaffold.Templates.Open(name)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	contents, err := ioutil.ReadAll(f)
+	if err != nil {
+		return "", err
+	}
+	return string(contents), nil
+}
+
+func renderTemplate(name string, values scaffoldValues, path string) error {
+	text, err := readTemplate(name)
+	if err != nil {
+		return errors.Wrapf(err, "failed to read template %s", name)
+	}
+	t, err := template.New(name).Funcs(template.FuncMap{"indent": indent}).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return errors.Wrapf(err, "failed to parse template %s", name)
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := t.Execute(f, values); err != nil {
+		f.Close()
+		return errors.Wrapf(err, "failed to render template %s", name)
+	}
+	return f.Close()
+}
+
+// generateManifests writes the manifests of a new cluster to the directory,
+// and returns the paths of the files written, relative to the directory.
+func generateManifests(dir string, options initOptionType) ([]string, error) {
+	files, ok := osFiles[options.os]
+	if !ok {
+		return nil, errors.Errorf("unsupported operating system %q, 'wksctl init --scaffold' supports %s", options.os, strings.Join(operatingSystems(), ", "))
+	}
+	values := scaffoldValues{
+		ClusterName:       scaffoldClusterName,
+		KubernetesVersion: options.kubernetesVersion,
+		Namespace:         options.namespace,
+		GitURL:            options.gitURL,
+		GitBranch:         options.gitBranch,
+		GitPath:           options.gitPath,
+	}
+	for _, file := range files {
+		contents, err := readTemplate("/os/" + options.os + "/" + file.Key)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to read %s for %s", file.Key, options.os)
+		}
+		file.Contents = contents
+		values.Files = append(values.Files, file)
+	}
+
+	templates := map[string]string{
+		options.clusterManifestPath:  "cluster.yaml",
+		options.machinesManifestPath: "machines.yaml",
+	}
+	for _, name := range scaffoldTemplates {
+		templates[name] = name
+	}
+	var paths []string
+	for path, name := range templates {
+		if err := os.MkdirAll(filepath.Dir(filepath.Join(dir, path)), 0755); err != nil {
+			return nil, err
+		}
+		if err := renderTemplate(name, values, filepath.Join(dir, path)); err != nil {
+			return nil, err
+		}
+		paths = append(paths, path)
+	}
+
+	weaveNet, err := addons.Get("weave-net")
+	if err != nil {
+		return nil, err
+	}
+	manifests, err := weaveNet.Build(addons.BuildOptions{OutputDirectory: dir, YAML: true})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to build the weave-net manifests")
+	}
+	for _, m := range manifests {
+		path, err := filepath.Rel(dir, m)
+		if err != nil {
+			return nil, err
+		}
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return paths, nil
+}
+
+// scaffoldRepository generates the manifests of a new cluster in a temporary
+// directory, validates and updates them as 'wksctl init' does, and copies them
+// to the repository, whose files are never overwritten.
+func scaffoldRepository(options initOptionType) error {
+	if options.gitURL == "" {
+		return errors.New("--git-url is required to scaffold a repository")
+	}
+	dir, err := ioutil.TempDir("", "wksctl-scaffold")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	paths, err := generateManifests(dir, options)
+	if err != nil {
+		return err
+	}
+	for _, path := range paths {
+		if _, err := os.Stat(filepath.Join(options.localRepoDirectory, path)); err == nil {
+			return errors.Errorf("%s already exists in %s", path, options.localRepoDirectory)
+		} else if !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	if err := specs.Validate(filepath.Join(dir, options.clusterManifestPath), filepath.Join(dir, options.machinesManifestPath)); err != nil {
+		return errors.Wrap(err, "the generated manifests are invalid")
+	}
+	generated := options
+	generated.localRepoDirectory = dir
+	if err := updateManifests(generated); err != nil {
+		return err
+	}
+
+	for _, path := range paths {
+		contents, err := ioutil.ReadFile(filepath.Join(dir, path))
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(options.localRepoDirectory, path)
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return err
+		}
+		if err := ioutil.WriteFile(target, contents, 0644); err != nil {
+			return err
+		}
+		log.Infof("Created %s", target)
+	}
+	return nil
+}
+
+// prompt asks the question and returns the answer, or the default value if
+// the answer is empty.
+func prompt(in *bufio.Reader, out io.Writer, question, defaultValue string) (string, error) {
+	fmt.Fprintf(out, "%s [%s]: ", question, defaultValue)
+	answer, err := in.ReadString('\n')
+	if err != nil && (err != io.EOF || answer == "") {
+		return "", errors.Wrap(err, "failed to read answer")
+	}
+	if answer = strings.TrimSpace(answer); answer != "" {
+		return answer, nil
+	}
+	return defaultValue, nil
+}
+
+// promptScaffoldOptions asks for the operating system and the Kubernetes
+// version, unless set by flags.
+func promptScaffoldOptions(options *initOptionType, in io.Reader, out io.Writer, osSet, versionSet bool) error {
+	r := bufio.NewReader(in)
+	var err error
+	if !osSet {
+		question := fmt.Sprintf("Operating system of the machines (%s)", strings.Join(operatingSystems(), ", "))
+		if options.os, err = prompt(r, out, question, options.os); err != nil {
+			return err
+		}
+	}
+	if !versionSet {
+		if options.kubernetesVersion, err = prompt(r, out, "Kubernetes version", options.kubernetesVersion); err != nil {
+			return err
+		}
+	}
+	return nil
+}
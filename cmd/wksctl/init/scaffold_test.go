@@ -0,0 +1,113 @@
+package init
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	existinginfrav1 "github.com/weaveworks/cluster-api-provider-existinginfra/apis/cluster.weave.works/v1alpha3"
+	"github.com/weaveworks/wksctl/pkg/specs"
+	v1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/yaml"
+)
+
+func scaffoldOptions(dir string) initOptionType {
+	return initOptionType{
+		localRepoDirectory:   dir,
+		gitURL:               "git@github.com:weaveworks/foo.bar",
+		gitBranch:            "main",
+		gitPath:              ".",
+		namespace:            "weavek8sops",
+		clusterManifestPath:  "cluster.yaml",
+		machinesManifestPath: "machines.yaml",
+		os:                   "centos7",
+		kubernetesVersion:    "1.18.9",
+	}
+}
+
+func TestScaffold(t *testing.T) {
+	for _, name := range operatingSystems() {
+		dir, err := ioutil.TempDir("", "wksctl-scaffold-test")
+		require.NoError(t, err)
+		defer os.RemoveAll(dir)
+		options := scaffoldOptions(dir)
+		options.os = name
+		require.NoError(t, scaffoldRepository(options), name)
+
+		clusterPath, machinesPath := filepath.Join(dir, "cluster.yaml"), filepath.Join(dir, "machines.yaml")
+		require.NoError(t, specs.Validate(clusterPath, machinesPath))
+		_, eic, err := specs.ParseClusterManifest(clusterPath)
+		require.NoError(t, err)
+		assert.Equal(t, "1.18.9", eic.Spec.KubernetesVersion)
+
+		// The files of the cluster are the ones of the repo ConfigMap.
+		contents, err := ioutil.ReadFile(filepath.Join(dir, "repo-config.yaml"))
+		require.NoError(t, err)
+		var repo v1.ConfigMap
+		require.NoError(t, yaml.Unmarshal(contents, &repo))
+		assert.Equal(t, "weavek8sops", repo.Namespace)
+		assert.Len(t, eic.Spec.OS.Files, len(osFiles[name]))
+		for i, file := range eic.Spec.OS.Files {
+			assert.Equal(t, existinginfrav1.FileSpec{
+				Destination: osFiles[name][i].Destination,
+				Source: existinginfrav1.SourceSpec{
+					ConfigMap: "repo",
+					Key:       osFiles[name][i].Key,
+					Contents:  repo.Data[osFiles[name][i].Key],
+				},
+			}, file)
+			assert.NotEmpty(t, repo.Data[osFiles[name][i].Key])
+		}
+
+		contents, err = ioutil.ReadFile(filepath.Join(dir, "flux.yaml"))
+		require.NoError(t, err)
+		assert.Contains(t, string(contents), "- --git-url=git@github.com:weaveworks/foo.bar\n")
+		assert.Contains(t, string(contents), "- --git-branch=main\n")
+		contents, err = ioutil.ReadFile(filepath.Join(dir, "weave-net.yaml"))
+		require.NoError(t, err)
+		assert.Contains(t, string(contents), "- name: IPALLOC_RANGE\n                  value: \"192.168.0.0/16\"\n")
+
+		// Existing files are not overwritten.
+		err = scaffoldRepository(options)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "already exists")
+	}
+}
+
+func TestScaffoldErrors(t *testing.T) {
+	dir, err := ioutil.TempDir("", "wksctl-scaffold-test")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	for _, update := range []func(*initOptionType){
+		func(o *initOptionType) { o.os = "debian" },
+		func(o *initOptionType) { o.kubernetesVersion = "1.12.0" },
+		func(o *initOptionType) { o.gitURL = "" },
+	} {
+		options := scaffoldOptions(dir)
+		update(&options)
+		assert.Error(t, scaffoldRepository(options))
+	}
+	files, err := ioutil.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Empty(t, files)
+}
+
+func TestPromptScaffoldOptions(t *testing.T) {
+	options := scaffoldOptions(".")
+	var out bytes.Buffer
+	require.NoError(t, promptScaffoldOptions(&options, strings.NewReader("ubuntu18.04\n\n"), &out, false, false))
+	assert.Equal(t, "ubuntu18.04", options.os)
+	assert.Equal(t, "1.18.9", options.kubernetesVersion)
+	assert.Equal(t, "Operating system of the machines (centos7, ubuntu18.04) [centos7]: Kubernetes version [1.18.9]: ", out.String())
+
+	out.Reset()
+	require.NoError(t, promptScaffoldOptions(&options, strings.NewReader("1.19.7"), &out, true, false))
+	assert.Equal(t, "ubuntu18.04", options.os)
+	assert.Equal(t, "1.19.7", options.kubernetesVersion)
+	assert.Equal(t, "Kubernetes version [1.18.9]: ", out.String())
+}
@@ -10,7 +10,9 @@ import (
 	capeipath "github.com/weaveworks/cluster-api-provider-existinginfra/pkg/utilities/path"
 	"github.com/weaveworks/wksctl/pkg/kubernetes/config"
 	"github.com/weaveworks/wksctl/pkg/manifests"
+	"github.com/weaveworks/wksctl/pkg/plan/runners/ssh"
 	"github.com/weaveworks/wksctl/pkg/specs"
+	"github.com/weaveworks/wksctl/pkg/utilities/logging"
 	"github.com/weaveworks/wksctl/pkg/utilities/manifest"
 	"github.com/weaveworks/wksctl/pkg/utilities/path"
 	"k8s.io/client-go/tools/clientcmd"
@@ -30,18 +32,19 @@ var Cmd = &cobra.Command{
 var kubeconfigOptions struct {
 	clusterManifestPath  string
 	machinesManifestPath string
+	manifestsLocation    string
 	gitURL               string
 	gitBranch            string
 	gitPath              string
 	gitDeployKeyPath     string
+	gitClone             manifests.GitOptions
 	artifactDirectory    string
 	namespace            string
-	sshKeyPath           string
+	sshOptions           ssh.Options
 	useContext           bool
 	skipTLSVerify        bool
 	useLocalhost         bool
 	usePublicAddress     bool
-	verbose              bool
 }
 
 func init() {
@@ -49,13 +52,15 @@ func init() {
 		&kubeconfigOptions.clusterManifestPath, "cluster", "cluster.yaml", "Location of cluster manifest")
 	Cmd.Flags().StringVar(
 		&kubeconfigOptions.machinesManifestPath, "machines", "machines.yaml", "Location of machines manifest")
+	Cmd.Flags().StringVar(&kubeconfigOptions.manifestsLocation, "manifests", "", "Directory, or tar.gz bundle, possibly at an HTTPS URL, holding cluster.yaml, machines.yaml and the configuration of the cluster, instead of --cluster and --machines")
 	Cmd.Flags().StringVar(&kubeconfigOptions.gitURL, "git-url", "",
 		"Git repo containing your cluster and machine information")
 	Cmd.Flags().StringVar(&kubeconfigOptions.gitBranch, "git-branch", "master",
 		"Branch within git repo containing your cluster and machine information")
 	Cmd.Flags().StringVar(&kubeconfigOptions.gitPath, "git-path", ".", "Relative path to files in Git")
 	Cmd.Flags().StringVar(&kubeconfigOptions.gitDeployKeyPath, "git-deploy-key", "", "Path to the Git deploy key")
-	Cmd.Flags().StringVar(&kubeconfigOptions.sshKeyPath, "ssh-key", "./cluster-key", "Path to a key authorized to log in to machines by SSH")
+	manifests.AddGitCloneFlags(Cmd.Flags(), &kubeconfigOptions.gitClone)
+	kubeconfigOptions.sshOptions.AddFlags(Cmd.Flags())
 	Cmd.Flags().StringVar(
 		&kubeconfigOptions.artifactDirectory, "artifact-directory", "", "Write output files in the specified directory")
 	Cmd.Flags().StringVar(
@@ -67,33 +72,27 @@ func init() {
 		&kubeconfigOptions.skipTLSVerify, "insecure-skip-tls-verify", false,
 		"Enables kubectl to communicate with the API w/o verifying the certificate")
 	_ = Cmd.Flags().MarkHidden("insecure-skip-tls-verify")
-
-	// Intentionally shadows the globally defined --verbose flag.
-	Cmd.Flags().BoolVarP(&kubeconfigOptions.verbose, "verbose", "v", false, "Enable verbose output")
 }
 
 func kubeconfigRun(cmd *cobra.Command, args []string) error {
-	var clusterPath, machinesPath string
-
-	// TODO: deduplicate clusterPath/machinesPath evaluation between here and cmd/wksctl/apply
-	// https://github.com/weaveworks/wksctl/issues/58
-	if kubeconfigOptions.gitURL == "" {
-		// Cluster and Machine manifests come from the local filesystem.
-		clusterPath, machinesPath = kubeconfigOptions.clusterManifestPath, kubeconfigOptions.machinesManifestPath
-	} else {
-		// Cluster and Machine manifests come from a Git repo that we'll clone for the duration of this command.
-		repo, err := manifests.CloneClusterAPIRepo(kubeconfigOptions.gitURL, kubeconfigOptions.gitBranch, kubeconfigOptions.gitDeployKeyPath, kubeconfigOptions.gitPath)
-		if err != nil {
-			return errors.Wrap(err, "CloneClusterAPIRepo")
-		}
-		defer repo.Close()
-
-		if clusterPath, err = repo.ClusterManifestPath(); err != nil {
-			return errors.Wrap(err, "ClusterManifestPath")
-		}
-		if machinesPath, err = repo.MachinesManifestPath(); err != nil {
-			return errors.Wrap(err, "MachinesManifestPath")
-		}
+	git := kubeconfigOptions.gitClone
+	git.URL = kubeconfigOptions.gitURL
+	git.Branch = kubeconfigOptions.gitBranch
+	git.Path = kubeconfigOptions.gitPath
+	git.DeployKeyPath = kubeconfigOptions.gitDeployKeyPath
+	source, err := manifests.OpenSource(cmd.Context(), manifests.SourceOptions{
+		Location:     kubeconfigOptions.manifestsLocation,
+		ClusterPath:  kubeconfigOptions.clusterManifestPath,
+		MachinesPath: kubeconfigOptions.machinesManifestPath,
+		Git:          git,
+	})
+	if err != nil {
+		return errors.Wrap(err, "failed to fetch manifests")
+	}
+	defer source.Close()
+	clusterPath, machinesPath, _, err := manifests.Paths(source)
+	if err != nil {
+		return err
 	}
 
 	return writeKubeconfig(cmd.Context(), clusterPath, machinesPath)
@@ -121,7 +120,8 @@ func writeKubeconfig(ctx context.Context, cpath, mpath string) error {
 		configPath = clientcmd.RecommendedHomeFile
 	}
 
-	configStr, err := config.GetRemoteKubeconfig(ctx, sp, kubeconfigOptions.sshKeyPath, kubeconfigOptions.verbose, kubeconfigOptions.skipTLSVerify)
+	kubeconfigOptions.sshOptions.MachinesPath = mpath
+	configStr, err := config.GetRemoteKubeconfig(ctx, sp, &kubeconfigOptions.sshOptions, logging.Verbose(), kubeconfigOptions.skipTLSVerify)
 	if err != nil {
 		return errors.Wrapf(err, "failed to get remote kubeconfig")
 	}
@@ -0,0 +1,72 @@
+package add
+
+import (
+	"fmt"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/weaveworks/wksctl/pkg/cluster/machine"
+	"github.com/weaveworks/wksctl/pkg/git"
+)
+
+// Cmd represents the machines add command
+var Cmd = &cobra.Command{
+	Use:   "add",
+	Short: "Add a machine to the machines manifest",
+	Long: `Add a Machine and its ExistingInfraMachine to the end of the machines manifest,
+in the cluster and with the Kubernetes version of its other machines. The rest
+of the manifest is left as it was. Machines are named after their role, unless
+--name is set.`,
+	Example:      "wksctl machines add --role worker --public 1.2.3.4 --private 10.0.0.4",
+	Args:         cobra.NoArgs,
+	RunE:         addRun,
+	SilenceUsage: true,
+}
+
+var addOptions struct {
+	machinesManifestPath string
+	role                 string
+	name                 string
+	publicAddress        string
+	publicPort           string
+	privateAddress       string
+	privatePort          string
+	commit               bool
+}
+
+func init() {
+	Cmd.Flags().StringVar(&addOptions.machinesManifestPath, "machines", "machines.yaml", "Location of machines manifest")
+	Cmd.Flags().StringVar(&addOptions.role, "role", "worker", "Role of the machine, master or worker")
+	Cmd.Flags().StringVar(&addOptions.name, "name", "", "Name of the machine (defaults to a unique name starting with its role)")
+	Cmd.Flags().StringVar(&addOptions.publicAddress, "public", "", "Address wksctl connects to the machine at")
+	Cmd.Flags().StringVar(&addOptions.publicPort, "public-port", "22", "SSH port of the public address")
+	Cmd.Flags().StringVar(&addOptions.privateAddress, "private", "", "Address of the machine on the network of the cluster (defaults to --public)")
+	Cmd.Flags().StringVar(&addOptions.privatePort, "private-port", "", "SSH port of the private address (defaults to 22, or to --public-port without --private)")
+	Cmd.Flags().BoolVar(&addOptions.commit, "commit", false, "Commit the machines manifest to the Git repository of the current directory")
+	_ = Cmd.MarkFlagRequired("public")
+}
+
+func addRun(cmd *cobra.Command, args []string) error {
+	o := &addOptions
+	host, err := machine.NewHost(o.name, o.publicAddress, o.publicPort, o.privateAddress, o.privatePort)
+	if err != nil {
+		return err
+	}
+	if o.commit {
+		if err := git.HasNoStagedChanges(); err != nil {
+			return err
+		}
+	}
+	name, err := machine.AddMachine(o.machinesManifestPath, o.role, o.name, host)
+	if err != nil {
+		return err
+	}
+	log.Infof("Added %s machine %s to %s", o.role, name, o.machinesManifestPath)
+	if !o.commit {
+		return nil
+	}
+	if err := git.AddAll(o.machinesManifestPath); err != nil {
+		return err
+	}
+	return git.Commit(fmt.Sprintf("Add %s machine %s", o.role, name))
+}
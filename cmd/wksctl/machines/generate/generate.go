@@ -0,0 +1,84 @@
+package generate
+
+import (
+	"io"
+	"os"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"github.com/weaveworks/wksctl/pkg/cluster/machine"
+	"github.com/weaveworks/wksctl/pkg/specs"
+)
+
+// Cmd represents the machines generate command
+var Cmd = &cobra.Command{
+	Use:   "generate",
+	Short: "Generate a machines manifest from an inventory",
+	Long: `Generate the machines manifest of the hosts of an inventory, the first --masters
+of which are masters, in the cluster of the --cluster manifest, with its
+Kubernetes version. Inventories are:
+- CSV files, with a header naming the name, public_address, public_port,
+  private_address and private_port columns, all optional but public_address,
+- Ansible inventories, in INI or YAML (.yaml or .yml) format, whose hosts are
+  connected to at ansible_host and ansible_port, and have a private address
+  if ip or private_ip is set,
+- the output of 'terraform output -json' (.json), whose public_ips, and
+  optional private_ips and names outputs, list the addresses and names of
+  the hosts.
+Addresses default to the public ones, and ports to 22.`,
+	Example: `wksctl machines generate --from inventory.ini --masters 3 > machines.yaml
+wksctl machines generate --from tf-output.json --output machines.yaml`,
+	Args:         cobra.NoArgs,
+	RunE:         generateRun,
+	SilenceUsage: true,
+}
+
+var generateOptions struct {
+	inventoryPath       string
+	masters             int
+	clusterManifestPath string
+	outputPath          string
+}
+
+func init() {
+	Cmd.Flags().StringVar(&generateOptions.inventoryPath, "from", "", "Inventory listing the hosts of the cluster")
+	Cmd.Flags().IntVar(&generateOptions.masters, "masters", 1, "Number of masters, the first hosts of the inventory")
+	Cmd.Flags().StringVar(&generateOptions.clusterManifestPath, "cluster", "cluster.yaml", "Location of cluster manifest")
+	Cmd.Flags().StringVarP(&generateOptions.outputPath, "output", "o", "", "Location of the machines manifest to write (defaults to the standard output)")
+	_ = Cmd.MarkFlagRequired("from")
+}
+
+func generateRun(cmd *cobra.Command, args []string) error {
+	hosts, err := machine.ReadInventory(generateOptions.inventoryPath)
+	if err != nil {
+		return err
+	}
+	cluster, eic, err := specs.ParseClusterManifest(generateOptions.clusterManifestPath)
+	if err != nil {
+		return errors.Wrapf(err, "failed to read cluster manifest %s", generateOptions.clusterManifestPath)
+	}
+	machines, bml, err := machine.GenerateMachines(hosts, generateOptions.masters, cluster.Name, eic.Spec.KubernetesVersion)
+	if err != nil {
+		return err
+	}
+
+	var w io.Writer = os.Stdout
+	var f *os.File
+	if generateOptions.outputPath != "" {
+		f, err = os.Create(generateOptions.outputPath)
+		if err != nil {
+			return err
+		}
+		w = f
+	}
+	if err := machine.WriteMachines(w, machines, bml); err != nil {
+		if f != nil {
+			f.Close()
+		}
+		return errors.Wrap(err, "failed to write machines manifest")
+	}
+	if f != nil {
+		return f.Close()
+	}
+	return nil
+}
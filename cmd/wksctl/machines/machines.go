@@ -0,0 +1,21 @@
+package machines
+
+import (
+	"github.com/spf13/cobra"
+	"github.com/weaveworks/wksctl/cmd/wksctl/machines/add"
+	"github.com/weaveworks/wksctl/cmd/wksctl/machines/generate"
+	"github.com/weaveworks/wksctl/cmd/wksctl/machines/remove"
+)
+
+// Cmd represents the machines command
+var Cmd = &cobra.Command{
+	Use:     "machines",
+	Aliases: []string{"machine"},
+	Short:   "Manage the machines manifest",
+}
+
+func init() {
+	Cmd.AddCommand(add.Cmd)
+	Cmd.AddCommand(generate.Cmd)
+	Cmd.AddCommand(remove.Cmd)
+}
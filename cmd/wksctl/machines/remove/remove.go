@@ -0,0 +1,51 @@
+package remove
+
+import (
+	"fmt"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/weaveworks/wksctl/pkg/cluster/machine"
+	"github.com/weaveworks/wksctl/pkg/git"
+)
+
+// Cmd represents the machines remove command
+var Cmd = &cobra.Command{
+	Use:   "remove <name>",
+	Short: "Remove a machine from the machines manifest",
+	Long: `Remove a Machine and its ExistingInfraMachine from the machines manifest. The
+rest of the manifest is left as it was.`,
+	Args:         cobra.ExactArgs(1),
+	RunE:         removeRun,
+	SilenceUsage: true,
+}
+
+var removeOptions struct {
+	machinesManifestPath string
+	commit               bool
+}
+
+func init() {
+	Cmd.Flags().StringVar(&removeOptions.machinesManifestPath, "machines", "machines.yaml", "Location of machines manifest")
+	Cmd.Flags().BoolVar(&removeOptions.commit, "commit", false, "Commit the machines manifest to the Git repository of the current directory")
+}
+
+func removeRun(cmd *cobra.Command, args []string) error {
+	o := &removeOptions
+	if o.commit {
+		if err := git.HasNoStagedChanges(); err != nil {
+			return err
+		}
+	}
+	if err := machine.RemoveMachine(o.machinesManifestPath, args[0]); err != nil {
+		return err
+	}
+	log.Infof("Removed machine %s from %s", args[0], o.machinesManifestPath)
+	if !o.commit {
+		return nil
+	}
+	if err := git.AddAll(o.machinesManifestPath); err != nil {
+		return err
+	}
+	return git.Commit(fmt.Sprintf("Remove machine %s", args[0]))
+}
@@ -3,21 +3,27 @@ package main
 import (
 	"os"
 
-	log "github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
-	"github.com/weaveworks/go-checkpoint"
 
 	"github.com/weaveworks/wksctl/cmd/wksctl/addon"
 	"github.com/weaveworks/wksctl/cmd/wksctl/apply"
 	"github.com/weaveworks/wksctl/cmd/wksctl/applyaddons"
 	"github.com/weaveworks/wksctl/cmd/wksctl/bashcompletions"
+	contextpkg "github.com/weaveworks/wksctl/cmd/wksctl/context"
 	initpkg "github.com/weaveworks/wksctl/cmd/wksctl/init"
 	"github.com/weaveworks/wksctl/cmd/wksctl/kubeconfig"
+	"github.com/weaveworks/wksctl/cmd/wksctl/machines"
 	"github.com/weaveworks/wksctl/cmd/wksctl/plan"
+	"github.com/weaveworks/wksctl/cmd/wksctl/preflight"
 	"github.com/weaveworks/wksctl/cmd/wksctl/profile"
 	"github.com/weaveworks/wksctl/cmd/wksctl/registrysynccommands"
+	"github.com/weaveworks/wksctl/cmd/wksctl/reset"
+	"github.com/weaveworks/wksctl/cmd/wksctl/upgrade"
 	"github.com/weaveworks/wksctl/cmd/wksctl/version"
 	"github.com/weaveworks/wksctl/cmd/wksctl/zshcompletions"
+	"github.com/weaveworks/wksctl/pkg/config"
+	"github.com/weaveworks/wksctl/pkg/utilities/logging"
+	"github.com/weaveworks/wksctl/pkg/utilities/path"
 	v "github.com/weaveworks/wksctl/pkg/version"
 )
 
@@ -25,47 +31,97 @@ var rootCmd = &cobra.Command{
 	Use:   "wksctl",
 	Short: "Weave Kubernetes System CLI",
 
-	PersistentPreRun: configureLogger,
+	PersistentPreRunE: preRun,
 }
 
 var options struct {
-	verbose bool
+	logging        logging.Options
+	noVersionCheck bool
+	context        string
 }
 
-func configureLogger(cmd *cobra.Command, args []string) {
-	log.SetFormatter(&log.TextFormatter{
-		FullTimestamp: true,
-	})
-	if options.verbose {
-		log.SetLevel(log.DebugLevel)
+// reportVersionCheck waits for the check for newer versions of wksctl, if one
+// was started, and reports its result.
+var reportVersionCheck = func() {}
+
+func preRun(cmd *cobra.Command, args []string) error {
+	if err := config.BindEnv(cmd.Flags(), os.LookupEnv); err != nil {
+		return err
+	}
+	// Contexts are managed without being applied, to be able to fix the
+	// current one.
+	if !isContextCommand(cmd) {
+		if err := applyContext(cmd); err != nil {
+			return err
+		}
+	}
+	if err := options.logging.Configure(); err != nil {
+		return err
+	}
+	if !options.noVersionCheck && !v.CheckDisabled() {
+		reportVersionCheck = v.StartCheck(path.VersionCheckCache(""))
 	}
+	return nil
+}
+
+// isContextCommand returns whether the provided command is "wksctl context" or
+// one of its subcommands.
+func isContextCommand(cmd *cobra.Command) bool {
+	for c := cmd; c != nil; c = c.Parent() {
+		if c == contextpkg.Cmd {
+			return true
+		}
+	}
+	return false
+}
+
+// applyContext sets the flags of the command not set on the command line, or by
+// environment variables, to the values of the selected or current context.
+func applyContext(cmd *cobra.Command) error {
+	c, err := config.Load(config.Path())
+	if err != nil {
+		return err
+	}
+	name := options.context
+	if name == "" {
+		name = c.CurrentContext
+	}
+	if name == "" {
+		return nil
+	}
+	context, err := c.Context(name)
+	if err != nil {
+		return err
+	}
+	return context.Apply(cmd.Flags())
 }
 
 func main() {
-	rootCmd.PersistentFlags().BoolVarP(&options.verbose, "verbose", "v", false, "Enable verbose output")
+	options.logging.AddFlags(rootCmd.PersistentFlags())
+	rootCmd.PersistentFlags().StringVar(&options.context, "context", "", "Context of the wksctl configuration to use (defaults to the current context)")
+	rootCmd.PersistentFlags().BoolVar(&options.noVersionCheck, "no-version-check", false, "Don't check for newer versions of wksctl (also disabled by setting "+v.DisableCheckEnv+")")
 
 	rootCmd.AddCommand(addon.Cmd)
 	rootCmd.AddCommand(apply.Cmd)
 	rootCmd.AddCommand(applyaddons.Cmd)
+	rootCmd.AddCommand(contextpkg.Cmd)
 	rootCmd.AddCommand(initpkg.Cmd)
 	rootCmd.AddCommand(kubeconfig.Cmd)
+	rootCmd.AddCommand(machines.Cmd)
 	rootCmd.AddCommand(plan.Cmd)
+	rootCmd.AddCommand(preflight.Cmd)
 	rootCmd.AddCommand(profile.Cmd)
 	rootCmd.AddCommand(registrysynccommands.Cmd)
+	rootCmd.AddCommand(reset.Cmd)
+	rootCmd.AddCommand(upgrade.Cmd)
 	rootCmd.AddCommand(version.Cmd)
 
 	rootCmd.AddCommand(bashcompletions.Cmd)
 	rootCmd.AddCommand(zshcompletions.Cmd)
 
-	if checkResponse, err := checkpoint.Check(&checkpoint.CheckParams{
-		Product: "wksctl",
-		Version: v.Version,
-	}); err == nil && checkResponse.Outdated {
-		log.Infof("wksctl version %s is available; please update at %s",
-			checkResponse.CurrentVersion, checkResponse.CurrentDownloadURL)
-	}
-
-	if err := rootCmd.Execute(); err != nil {
+	err := rootCmd.Execute()
+	reportVersionCheck()
+	if err != nil {
 		os.Exit(1)
 	}
 
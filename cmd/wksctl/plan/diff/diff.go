@@ -0,0 +1,188 @@
+package diff
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	capeios "github.com/weaveworks/cluster-api-provider-existinginfra/pkg/apis/wksprovider/machine/os"
+	"github.com/weaveworks/cluster-api-provider-existinginfra/pkg/plan"
+	"github.com/weaveworks/cluster-api-provider-existinginfra/pkg/utilities/kubeadm"
+	"github.com/weaveworks/wksctl/pkg/git"
+	plandiff "github.com/weaveworks/wksctl/pkg/plan/diff"
+	"github.com/weaveworks/wksctl/pkg/plan/runners/offline"
+	"github.com/weaveworks/wksctl/pkg/plan/runners/ssh"
+	"github.com/weaveworks/wksctl/pkg/seednode"
+	"github.com/weaveworks/wksctl/pkg/specs"
+	"github.com/weaveworks/wksctl/pkg/utilities"
+)
+
+// Cmd represents the plan diff command
+var Cmd = &cobra.Command{
+	Use:   "diff",
+	Short: "Compare the cluster plans of two versions of the cluster and machines manifests.",
+	Long: `Compare the cluster plans of two versions of the cluster and machines manifests.
+
+--from and --to each take either a directory, or a revision of the Git
+repository containing the current directory. The manifests and the
+configuration directory are read at the same paths, relative to that directory
+or to the current directory in that revision.`,
+	Example: `  wksctl plan diff --from HEAD~1 --to . --offline --os centos7`,
+	Args:    cobra.NoArgs,
+	RunE:    diffRun,
+}
+
+var diffOptions struct {
+	seednode.Options
+	sshOptions           ssh.Options
+	from                 string
+	to                   string
+	output               string
+	clusterManifestPath  string
+	machinesManifestPath string
+	offline              bool
+	os                   string
+}
+
+func init() {
+	Cmd.Flags().StringVar(&diffOptions.from, "from", "", "Directory or Git revision containing the original manifests")
+	Cmd.Flags().StringVar(&diffOptions.to, "to", ".", "Directory or Git revision containing the updated manifests")
+	Cmd.Flags().StringVarP(&diffOptions.output, "output", "o", "text", "Output format (text|json)")
+	Cmd.Flags().StringVar(&diffOptions.clusterManifestPath, "cluster", "cluster.yaml", "Location of cluster manifest")
+	Cmd.Flags().StringVar(&diffOptions.machinesManifestPath, "machines", "machines.yaml", "Location of machines manifest")
+	Cmd.Flags().StringVar(&diffOptions.ControllerImage, "controller-image", "", "Controller image override")
+	// The manifests are read from directories or revisions, never cloned.
+	diffOptions.AddPlanFlags(Cmd.Flags())
+	diffOptions.sshOptions.AddFlags(Cmd.Flags())
+	Cmd.Flags().BoolVar(&diffOptions.offline, "offline", false, "Render the plans without connecting to the seed node, which is assumed to run the operating system set by --os")
+	Cmd.Flags().StringVar(&diffOptions.os, "os", "", fmt.Sprintf("Operating system of the seed node when rendering the plans offline (%s)", strings.Join(offline.SupportedOSes(), "|")))
+	_ = Cmd.MarkFlagRequired("from")
+}
+
+func diffRun(cmd *cobra.Command, args []string) error {
+	if diffOptions.output != "text" && diffOptions.output != "json" {
+		return errors.Errorf("invalid output format %q, expected text or json", diffOptions.output)
+	}
+	if diffOptions.offline && diffOptions.os == "" {
+		return errors.New("--offline requires the operating system of the seed node to be set with --os")
+	}
+	if !diffOptions.offline && diffOptions.os != "" {
+		return errors.New("--os can only be used together with --offline")
+	}
+
+	from, err := resolveSource(diffOptions.from)
+	if err != nil {
+		return err
+	}
+	defer from.Close()
+	to, err := resolveSource(diffOptions.to)
+	if err != nil {
+		return err
+	}
+	defer to.Close()
+
+	d, err := diffPlans(cmd.Context(), from, to)
+	if err != nil {
+		return err
+	}
+	if diffOptions.output == "json" {
+		return d.WriteJSON(os.Stdout)
+	}
+	return d.WriteText(os.Stdout)
+}
+
+// source is a directory holding a version of the manifests.
+type source struct {
+	root    string
+	tempDir string
+}
+
+// resolveSource returns the directory holding the manifests of ref, which is
+// either a directory, or a Git revision extracted to a temporary directory.
+func resolveSource(ref string) (*source, error) {
+	if info, err := os.Stat(ref); err == nil && info.IsDir() {
+		return &source{root: ref}, nil
+	}
+	dir, err := ioutil.TempDir("", "wksctl-plan-diff")
+	if err != nil {
+		return nil, err
+	}
+	if err := git.ExtractRevision(ref, dir); err != nil {
+		os.RemoveAll(dir)
+		return nil, errors.Wrapf(err, "%q is neither a directory nor a Git revision", ref)
+	}
+	return &source{root: dir, tempDir: dir}, nil
+}
+
+func (s *source) path(p string) string {
+	if filepath.IsAbs(p) {
+		return p
+	}
+	return filepath.Join(s.root, p)
+}
+
+func (s *source) Close() {
+	if s.tempDir != "" {
+		os.RemoveAll(s.tempDir)
+	}
+}
+
+func diffPlans(ctx context.Context, from, to *source) (*plandiff.Diff, error) {
+	// Both plans are built with the same bootstrap token, and against the same
+	// machine, for the differences to only come from the manifests.
+	token, err := kubeadm.GenerateBootstrapToken()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to generate bootstrap token")
+	}
+	opts := diffOptions.Options
+	opts.BootstrapToken = token
+
+	toSpecs := specs.NewFromPaths(to.path(diffOptions.clusterManifestPath), to.path(diffOptions.machinesManifestPath))
+	var runner plan.Runner
+	if diffOptions.offline {
+		offlineRunner, err := offline.NewRunner(diffOptions.os)
+		if err != nil {
+			return nil, err
+		}
+		runner = offlineRunner
+		if !utilities.FileExists(opts.ControllerSSHKeyPath) {
+			opts.ControllerSSHKeyPath = ""
+		}
+	} else {
+		// Keys accepted on first contact aren't pinned, which would make the
+		// machines manifest of the revisions differ.
+		diffOptions.sshOptions.MachinesPath = to.path(diffOptions.machinesManifestPath)
+		sshClient, err := diffOptions.sshOptions.NewClientForMachine(toSpecs.Cluster, toSpecs.MasterSpec, toSpecs.ClusterSpec.User, false)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to create SSH client")
+		}
+		defer sshClient.Close()
+		runner = sshClient
+	}
+	installer, err := capeios.Identify(ctx, runner)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to identify operating system for seed node (%s)", toSpecs.GetMasterPublicAddress())
+	}
+
+	fromPlan, err := buildPlan(ctx, installer, from, opts)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not generate plan for %q", diffOptions.from)
+	}
+	toPlan, err := buildPlan(ctx, installer, to, opts)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not generate plan for %q", diffOptions.to)
+	}
+	return plandiff.Plans(fromPlan, toPlan)
+}
+
+func buildPlan(ctx context.Context, installer *capeios.OS, s *source, opts seednode.Options) (*plan.Plan, error) {
+	clusterPath, machinesPath := s.path(diffOptions.clusterManifestPath), s.path(diffOptions.machinesManifestPath)
+	opts.ConfigDirectory = s.path(opts.ConfigDirectory)
+	sp := specs.NewFromPaths(clusterPath, machinesPath)
+	return seednode.Plan(ctx, installer, sp, clusterPath, machinesPath, opts)
+}
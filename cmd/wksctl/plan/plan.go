@@ -2,6 +2,7 @@ package plan
 
 import (
 	"github.com/spf13/cobra"
+	"github.com/weaveworks/wksctl/cmd/wksctl/plan/diff"
 	"github.com/weaveworks/wksctl/cmd/wksctl/plan/view"
 )
 
@@ -13,5 +14,6 @@ var Cmd = &cobra.Command{
 }
 
 func init() {
+	Cmd.AddCommand(diff.Cmd)
 	Cmd.AddCommand(view.Cmd)
 }
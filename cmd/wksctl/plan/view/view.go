@@ -3,18 +3,21 @@ package view
 import (
 	"context"
 	"fmt"
-	"io/ioutil"
-	"path/filepath"
+	"os"
+	"strings"
 
 	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
-	"github.com/weaveworks/cluster-api-provider-existinginfra/pkg/apis/wksprovider/machine/config"
+	"github.com/spf13/pflag"
 	capeios "github.com/weaveworks/cluster-api-provider-existinginfra/pkg/apis/wksprovider/machine/os"
+	"github.com/weaveworks/cluster-api-provider-existinginfra/pkg/plan"
 	"github.com/weaveworks/wksctl/pkg/manifests"
+	"github.com/weaveworks/wksctl/pkg/plan/runners/offline"
 	"github.com/weaveworks/wksctl/pkg/plan/runners/ssh"
+	"github.com/weaveworks/wksctl/pkg/seednode"
 	"github.com/weaveworks/wksctl/pkg/specs"
 	"github.com/weaveworks/wksctl/pkg/utilities"
-	"github.com/weaveworks/wksctl/pkg/utilities/manifest"
+	"github.com/weaveworks/wksctl/pkg/utilities/logging"
 )
 
 // Cmd represents the plan view command
@@ -25,151 +28,100 @@ var Cmd = &cobra.Command{
 	RunE:   planRun,
 }
 
-var viewOptions struct {
+// options are the settings of the plan view command.
+type options struct {
+	seednode.Options
+	sshOptions           ssh.Options
 	output               string
 	clusterManifestPath  string
 	machinesManifestPath string
-	controllerImage      string
-	gitURL               string
-	gitBranch            string
-	gitPath              string
-	gitDeployKeyPath     string
-	sshKeyPath           string
-	sealedSecretCertPath string
-	sealedSecretKeyPath  string
-	configDirectory      string
-	verbose              bool
+	manifestsLocation    string
+	offline              bool
+	os                   string
 }
 
+var viewOptions options
+
 func init() {
-	Cmd.Flags().StringVarP(&viewOptions.output, "output", "o", "dot", "Output format (dot|json)")
-	Cmd.Flags().StringVar(&viewOptions.clusterManifestPath, "cluster", "cluster.yaml", "Location of cluster manifest")
-	Cmd.Flags().StringVar(&viewOptions.machinesManifestPath, "machines", "machines.yaml", "Location of machines manifest")
-	Cmd.Flags().StringVar(&viewOptions.controllerImage, "controller-image", "", "Controller image override")
-	Cmd.Flags().StringVar(&viewOptions.gitURL, "git-url", "", "Git repo containing your cluster and machine information")
-	Cmd.Flags().StringVar(&viewOptions.gitBranch, "git-branch", "master", "Git branch WKS should use to read your cluster")
-	Cmd.Flags().StringVar(&viewOptions.gitPath, "git-path", ".", "Relative path to files in Git")
-	Cmd.Flags().StringVar(&viewOptions.gitDeployKeyPath, "git-deploy-key", "", "Path to the Git deploy key")
-	Cmd.Flags().StringVar(&viewOptions.sshKeyPath, "ssh-key", "./cluster-key", "Path to a key authorized to log in to machines by SSH")
-	Cmd.Flags().StringVar(&viewOptions.sealedSecretCertPath, "sealed-secret-cert", "", "Path to a certificate used to encrypt sealed secrets")
-	Cmd.Flags().StringVar(&viewOptions.sealedSecretKeyPath, "sealed-secret-key", "", "Path to a key used to encrypt sealed secrets")
-	Cmd.Flags().StringVar(&viewOptions.configDirectory, "config-directory", ".", "Directory containing configuration information for the cluster")
+	viewOptions.addFlags(Cmd.Flags())
+}
 
-	// Intentionally shadows the globally defined --verbose flag.
-	Cmd.Flags().BoolVarP(&viewOptions.verbose, "verbose", "v", false, "Enable verbose output")
+// addFlags registers the flags of the plan view command, bound to o.
+func (o *options) addFlags(fs *pflag.FlagSet) {
+	fs.StringVarP(&o.output, "output", "o", "dot", "Output format (dot|json)")
+	fs.StringVar(&o.clusterManifestPath, "cluster", "cluster.yaml", "Location of cluster manifest")
+	fs.StringVar(&o.machinesManifestPath, "machines", "machines.yaml", "Location of machines manifest")
+	fs.StringVar(&o.manifestsLocation, "manifests", "", "Directory, or tar.gz bundle, possibly at an HTTPS URL, holding cluster.yaml, machines.yaml and the configuration of the cluster, instead of --cluster and --machines")
+	fs.StringVar(&o.ControllerImage, "controller-image", "", "Controller image override")
+	o.Options.AddFlags(fs)
+	o.sshOptions.AddFlags(fs)
+	fs.BoolVar(&o.offline, "offline", false, "Render the plan without connecting to the seed node, which is assumed to run the operating system set by --os")
+	fs.StringVar(&o.os, "os", "", fmt.Sprintf("Operating system of the seed node when rendering the plan offline (%s)", strings.Join(offline.SupportedOSes(), "|")))
 }
 
 func planRun(cmd *cobra.Command, args []string) error {
-	var clusterPath, machinesPath string
-
-	// TODO: deduplicate clusterPath/machinesPath evaluation between here and cmd/wksctl/apply
-	// https://github.com/weaveworks/wksctl/issues/58
-	if viewOptions.gitURL == "" {
-		// Cluster and Machine manifests come from the local filesystem.
-		clusterPath, machinesPath = viewOptions.clusterManifestPath, viewOptions.machinesManifestPath
-	} else {
-		// Cluster and Machine manifests come from a Git repo that we'll clone for the duration of this command.
-		repo, err := manifests.CloneClusterAPIRepo(viewOptions.gitURL, viewOptions.gitBranch, viewOptions.gitDeployKeyPath, viewOptions.gitPath)
-		if err != nil {
-			return errors.Wrap(err, "CloneClusterAPIRepo")
-		}
-		defer repo.Close()
-
-		if clusterPath, err = repo.ClusterManifestPath(); err != nil {
-			return errors.Wrap(err, "ClusterManifestPath")
-		}
-		if machinesPath, err = repo.MachinesManifestPath(); err != nil {
-			return errors.Wrap(err, "MachinesManifestPath")
-		}
+	if err := seednode.ValidateOutputFormat(viewOptions.output); err != nil {
+		return err
 	}
-
-	return displayPlan(cmd.Context(), clusterPath, machinesPath)
-}
-
-func displayPlan(ctx context.Context, clusterManifestPath, machinesManifestPath string) error {
-	// TODO: reuse the actual plan created by `wksctl apply`, rather than trying to construct a similar plan and printing it.
-	sp := specs.NewFromPaths(clusterManifestPath, machinesManifestPath)
-	sshClient, err := ssh.NewClientForMachine(sp.MasterSpec, sp.ClusterSpec.User, viewOptions.sshKeyPath, viewOptions.verbose)
-	if err != nil {
-		return errors.Wrap(err, "failed to create SSH client: ")
+	if viewOptions.offline && viewOptions.os == "" {
+		return errors.New("--offline requires the operating system of the seed node to be set with --os")
 	}
-	defer sshClient.Close()
-	installer, err := capeios.Identify(context.Background(), sshClient)
-	if err != nil {
-		return errors.Wrapf(err, "failed to identify operating system for seed node (%s)", sp.GetMasterPublicAddress())
+	if !viewOptions.offline && viewOptions.os != "" {
+		return errors.New("--os can only be used together with --offline")
 	}
 
-	// Point config dir at sync repo if using github and the user didn't override it
-	configDir := viewOptions.configDirectory
-	if configDir == "." && viewOptions.gitURL != "" {
-		configDir = filepath.Dir(clusterManifestPath)
-	}
-
-	// Read manifests and pass in the contents
-	clusterManifest, err := ioutil.ReadFile(clusterManifestPath)
+	source, err := manifests.OpenSource(cmd.Context(), manifests.SourceOptions{
+		Location:        viewOptions.manifestsLocation,
+		ClusterPath:     viewOptions.clusterManifestPath,
+		MachinesPath:    viewOptions.machinesManifestPath,
+		ConfigDirectory: viewOptions.ConfigDirectory,
+		Git:             viewOptions.Git(),
+	})
 	if err != nil {
-		return errors.Wrap(err, "failed to read cluster manifest: ")
+		return errors.Wrap(err, "failed to fetch manifests")
 	}
-
-	machinesManifest, err := ioutil.ReadFile(machinesManifestPath)
+	defer source.Close()
+	clusterPath, machinesPath, configDir, err := manifests.Paths(source)
 	if err != nil {
-		return errors.Wrap(err, "failed to read machines manifest: ")
+		return err
 	}
+	viewOptions.ConfigDirectory = configDir
 
-	sealedSecretKeyPath := viewOptions.sealedSecretKeyPath
-	if sealedSecretKeyPath == "" {
-		// Default to using the git deploy key to decrypt sealed secrets
-		sealedSecretKeyPath = viewOptions.gitDeployKeyPath
-	}
+	return displayPlan(cmd.Context(), clusterPath, machinesPath)
+}
+
+func displayPlan(ctx context.Context, clusterManifestPath, machinesManifestPath string) error {
+	sp := specs.NewFromPaths(clusterManifestPath, machinesManifestPath)
+	opts := viewOptions.Options
 
-	// Read sealed secret cert and key
-	var cert []byte
-	var key []byte
-	if utilities.FileExists(viewOptions.sealedSecretCertPath) && utilities.FileExists(sealedSecretKeyPath) {
-		cert, err = ioutil.ReadFile(viewOptions.sealedSecretCertPath)
+	var runner plan.Runner
+	if viewOptions.offline {
+		offlineRunner, err := offline.NewRunner(viewOptions.os)
 		if err != nil {
-			return errors.Wrap(err, "failed to read sealed secret certificate: ")
+			return err
 		}
-
-		key, err = ioutil.ReadFile(sealedSecretKeyPath)
+		runner = offlineRunner
+		// Reviewers rendering plans offline usually don't hold the cluster's SSH key.
+		if !utilities.FileExists(opts.ControllerSSHKeyPath) {
+			opts.ControllerSSHKeyPath = ""
+		}
+	} else {
+		viewOptions.sshOptions.MachinesPath = machinesManifestPath
+		sshClient, err := viewOptions.sshOptions.NewClientForMachine(sp.Cluster, sp.MasterSpec, sp.ClusterSpec.User, logging.Verbose())
 		if err != nil {
-			return errors.Wrap(err, "failed to read sealed secret key: ")
+			return errors.Wrap(err, "failed to create SSH client: ")
 		}
+		defer sshClient.Close()
+		runner = sshClient
 	}
-
-	params := capeios.SeedNodeParams{
-		PublicIP:         sp.GetMasterPublicAddress(),
-		PrivateIP:        sp.GetMasterPrivateAddress(),
-		ClusterManifest:  string(clusterManifest),
-		MachinesManifest: string(machinesManifest),
-		KubeletConfig: config.KubeletConfig{
-			NodeIP:        sp.GetMasterPrivateAddress(),
-			CloudProvider: sp.GetCloudProvider(),
-		},
-		Controller: capeios.ControllerParams{
-			ImageOverride: viewOptions.controllerImage,
-		},
-		GitData: capeios.GitParams{
-			GitURL:           viewOptions.gitURL,
-			GitBranch:        viewOptions.gitBranch,
-			GitPath:          viewOptions.gitPath,
-			GitDeployKeyPath: viewOptions.gitDeployKeyPath,
-		},
-		SealedSecretCert: string(cert),
-		SealedSecretKey:  string(key),
-		Namespace:        manifest.DefaultNamespace,
-		AddonNamespaces:  manifest.DefaultAddonNamespaces,
-		ConfigDirectory:  configDir,
+	installer, err := capeios.Identify(ctx, runner)
+	if err != nil {
+		return errors.Wrapf(err, "failed to identify operating system for seed node (%s)", sp.GetMasterPublicAddress())
 	}
-	plan, err := capeios.CreateSeedNodeSetupPlan(ctx, installer, params)
+
+	p, err := seednode.Plan(ctx, installer, sp, clusterManifestPath, machinesManifestPath, opts)
 	if err != nil {
 		return errors.Wrap(err, "could not generate plan")
 	}
-	switch viewOptions.output {
-	case "dot":
-		fmt.Println(plan.ToDOT())
-	case "json":
-		fmt.Println(plan.ToHumanReadableJSON())
-	}
-	return nil
+	return seednode.WritePlan(os.Stdout, p, viewOptions.output)
 }
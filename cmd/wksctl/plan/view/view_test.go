@@ -0,0 +1,113 @@
+package view
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/pflag"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	capeios "github.com/weaveworks/cluster-api-provider-existinginfra/pkg/apis/wksprovider/machine/os"
+	"github.com/weaveworks/wksctl/cmd/wksctl/apply"
+	"github.com/weaveworks/wksctl/pkg/plan/runners/offline"
+	"github.com/weaveworks/wksctl/pkg/seednode"
+	"github.com/weaveworks/wksctl/pkg/specs"
+)
+
+const examplesDir = "../../../../examples/footloose"
+
+// seedNodeFlags returns the flags setting the options of the seed node plan,
+// bound to the returned options.
+func seedNodeFlags() (*seednode.Options, *pflag.FlagSet) {
+	var o seednode.Options
+	fs := pflag.NewFlagSet("seednode", pflag.ContinueOnError)
+	o.AddFlags(fs)
+	fs.StringVar(&o.ControllerImage, "controller-image", "", "Controller image override")
+	return &o, fs
+}
+
+func TestViewAndApplyShareSeedNodeOptions(t *testing.T) {
+	var applyParams apply.Params
+	applyFlags := pflag.NewFlagSet("apply", pflag.ContinueOnError)
+	applyParams.AddFlags(applyFlags)
+	var viewOpts options
+	viewFlags := pflag.NewFlagSet("plan view", pflag.ContinueOnError)
+	viewOpts.addFlags(viewFlags)
+	commands := map[string]struct {
+		flags   *pflag.FlagSet
+		options *seednode.Options
+	}{
+		"apply":     {applyFlags, &applyParams.Options},
+		"plan view": {viewFlags, &viewOpts.Options},
+	}
+
+	_, expected := seedNodeFlags()
+	expected.VisitAll(func(f *pflag.Flag) {
+		for name, cmd := range commands {
+			actual := cmd.flags.Lookup(f.Name)
+			if !assert.NotNil(t, actual, "%s has no --%s flag", name, f.Name) {
+				continue
+			}
+			assert.Equal(t, f.Value.Type(), actual.Value.Type(), "type of the --%s flag of %s", f.Name, name)
+			assert.Equal(t, f.DefValue, actual.DefValue, "default of the --%s flag of %s", f.Name, name)
+			assert.Equal(t, f.Usage, actual.Usage, "usage of the --%s flag of %s", f.Name, name)
+		}
+	})
+
+	argv := []string{
+		"--git-url=git@github.com:example/cluster.git",
+		"--git-branch=main",
+		"--git-path=clusters/example",
+		"--git-deploy-key=deploy-key",
+		"--git-ref=v1.0.0",
+		"--git-user=wks",
+		"--git-token-file=token",
+		"--git-depth=1",
+		"--no-git-cache",
+		"--require-signed-commit",
+		"--trusted-keys=trusted-keys.asc",
+		"--controller-ssh-key=cluster-key",
+		"--sealed-secret-key=ss.key",
+		"--sealed-secret-cert=ss.crt",
+		"--config-directory=config",
+		"--namespace=wks",
+		"--use-manifest-namespace",
+		"--addon-namespace=weave-net=kube-system,flux=flux",
+		"--controller-image=quay.io/wksctl/controller:test",
+	}
+	options, fs := seedNodeFlags()
+	require.NoError(t, fs.Parse(argv))
+	fs.VisitAll(func(f *pflag.Flag) {
+		assert.True(t, f.Changed, "the --%s flag is missing from the arguments", f.Name)
+	})
+	for name, cmd := range commands {
+		require.NoError(t, cmd.flags.Parse(argv), name)
+		assert.Equal(t, *options, *cmd.options, name)
+	}
+}
+
+func TestOfflinePlan(t *testing.T) {
+	clusterPath := filepath.Join(examplesDir, "cluster.yaml")
+	machinesPath := filepath.Join(examplesDir, "machines.yaml")
+	opts := seednode.Options{
+		GitBranch:       "master",
+		GitPath:         ".",
+		ConfigDirectory: examplesDir,
+		Namespace:       "weavek8sops",
+	}
+	ctx := context.Background()
+	sp := specs.NewFromPaths(clusterPath, machinesPath)
+
+	for _, osName := range offline.SupportedOSes() {
+		t.Run(osName, func(t *testing.T) {
+			r, err := offline.NewRunner(osName)
+			require.NoError(t, err)
+			installer, err := capeios.Identify(ctx, r)
+			require.NoError(t, err)
+			p, err := seednode.Plan(ctx, installer, sp, clusterPath, machinesPath, opts)
+			require.NoError(t, err)
+			assert.NotNil(t, p.GetResource("install:cni"))
+		})
+	}
+}
@@ -0,0 +1,76 @@
+package preflight
+
+import (
+	"os"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	capeimachine "github.com/weaveworks/cluster-api-provider-existinginfra/pkg/cluster/machine"
+	"github.com/weaveworks/wksctl/pkg/manifests"
+	"github.com/weaveworks/wksctl/pkg/plan/runners/ssh"
+	"github.com/weaveworks/wksctl/pkg/preflight"
+	"github.com/weaveworks/wksctl/pkg/specs"
+)
+
+// Cmd represents the preflight command
+var Cmd = &cobra.Command{
+	Use:   "preflight",
+	Short: "Check the machines can be set up",
+	Long: `Check the machines of the machines manifest can be set up, before running
+wksctl apply: they are reachable by SSH, their user can run commands as root,
+their operating system is supported, they have enough resources, the ports
+Kubernetes needs are free, their hostnames and product UUIDs are unique, their
+clocks agree, and they can reach each other at their private address.`,
+	Args: cobra.NoArgs,
+	RunE: preflightRun,
+}
+
+var preflightOptions struct {
+	clusterManifestPath  string
+	machinesManifestPath string
+	manifestsLocation    string
+	sshOptions           ssh.Options
+	output               string
+}
+
+func init() {
+	Cmd.Flags().StringVar(&preflightOptions.clusterManifestPath, "cluster", "cluster.yaml", "Location of cluster manifest")
+	Cmd.Flags().StringVar(&preflightOptions.machinesManifestPath, "machines", "machines.yaml", "Location of machines manifest")
+	Cmd.Flags().StringVar(&preflightOptions.manifestsLocation, "manifests", "", "Directory, or tar.gz bundle, possibly at an HTTPS URL, holding cluster.yaml, machines.yaml and the configuration of the cluster, instead of --cluster and --machines")
+	preflightOptions.sshOptions.AddFlags(Cmd.Flags())
+	Cmd.Flags().StringVarP(&preflightOptions.output, "output", "o", "table", "Output format of the results (table|json)")
+}
+
+func preflightRun(cmd *cobra.Command, args []string) error {
+	if err := preflight.ValidateOutputFormat(preflightOptions.output); err != nil {
+		return err
+	}
+	source, err := manifests.OpenSource(cmd.Context(), manifests.SourceOptions{
+		Location:     preflightOptions.manifestsLocation,
+		ClusterPath:  preflightOptions.clusterManifestPath,
+		MachinesPath: preflightOptions.machinesManifestPath,
+	})
+	if err != nil {
+		return errors.Wrap(err, "failed to fetch manifests")
+	}
+	defer source.Close()
+	clusterPath, machinesPath, _, err := manifests.Paths(source)
+	if err != nil {
+		return err
+	}
+	sp := specs.NewFromPaths(clusterPath, machinesPath)
+	machines, eiMachines, err := capeimachine.ParseManifest(machinesPath)
+	if err != nil {
+		return errors.Wrap(err, "failed to parse machines manifest")
+	}
+	preflightOptions.sshOptions.MachinesPath = machinesPath
+
+	report := preflight.Check(cmd.Context(), machines, eiMachines, preflight.SSHConnector(&preflightOptions.sshOptions, sp.Cluster, sp.ClusterSpec.User))
+	if err := report.Write(os.Stdout, preflightOptions.output); err != nil {
+		return err
+	}
+	if report.Failed() {
+		return errors.New("pre-flight checks failed")
+	}
+	return nil
+}
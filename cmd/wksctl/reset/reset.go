@@ -0,0 +1,173 @@
+package reset
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	existinginfrav1 "github.com/weaveworks/cluster-api-provider-existinginfra/apis/cluster.weave.works/v1alpha3"
+	capeimachine "github.com/weaveworks/cluster-api-provider-existinginfra/pkg/cluster/machine"
+	"github.com/weaveworks/cluster-api-provider-existinginfra/pkg/plan"
+	"github.com/weaveworks/cluster-api-provider-existinginfra/pkg/plan/runners/sudo"
+	"github.com/weaveworks/wksctl/pkg/manifests"
+	"github.com/weaveworks/wksctl/pkg/plan/recipe"
+	"github.com/weaveworks/wksctl/pkg/plan/runners/ssh"
+	"github.com/weaveworks/wksctl/pkg/specs"
+	"github.com/weaveworks/wksctl/pkg/utilities/logging"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha3"
+)
+
+// Cmd represents the reset command
+var Cmd = &cobra.Command{
+	Use:   "reset",
+	Short: "Tear down the Kubernetes cluster set up on the machines",
+	Long: `Tear down the Kubernetes cluster set up on the machines of the machines
+manifest, leaving them ready to be set up again: nodes are reset by kubeadm,
+and the CNI state and the files installed by wksctl are removed.`,
+	Args: cobra.NoArgs,
+	RunE: resetRun,
+}
+
+var resetOptions struct {
+	clusterManifestPath  string
+	machinesManifestPath string
+	manifestsLocation    string
+	sshOptions           ssh.Options
+	machines             []string
+	yes                  bool
+}
+
+func init() {
+	Cmd.Flags().StringVar(&resetOptions.clusterManifestPath, "cluster", "cluster.yaml", "Location of cluster manifest")
+	Cmd.Flags().StringVar(&resetOptions.machinesManifestPath, "machines", "machines.yaml", "Location of machines manifest")
+	Cmd.Flags().StringVar(&resetOptions.manifestsLocation, "manifests", "", "Directory, or tar.gz bundle, possibly at an HTTPS URL, holding cluster.yaml, machines.yaml and the configuration of the cluster, instead of --cluster and --machines")
+	resetOptions.sshOptions.AddFlags(Cmd.Flags())
+	Cmd.Flags().StringSliceVar(&resetOptions.machines, "machine", nil, "Name of a machine to reset, instead of all machines (can be repeated)")
+	Cmd.Flags().BoolVarP(&resetOptions.yes, "yes", "y", false, "Reset the machines without asking for confirmation")
+}
+
+// target is a machine to reset.
+type target struct {
+	machine   *clusterv1.Machine
+	eiMachine *existinginfrav1.ExistingInfraMachine
+}
+
+func resetRun(cmd *cobra.Command, args []string) error {
+	source, err := manifests.OpenSource(cmd.Context(), manifests.SourceOptions{
+		Location:     resetOptions.manifestsLocation,
+		ClusterPath:  resetOptions.clusterManifestPath,
+		MachinesPath: resetOptions.machinesManifestPath,
+	})
+	if err != nil {
+		return errors.Wrap(err, "failed to fetch manifests")
+	}
+	defer source.Close()
+	clusterPath, machinesPath, _, err := manifests.Paths(source)
+	if err != nil {
+		return err
+	}
+	sp := specs.NewFromPaths(clusterPath, machinesPath)
+	resetOptions.sshOptions.MachinesPath = machinesPath
+	machines, eiMachines, err := capeimachine.ParseManifest(machinesPath)
+	if err != nil {
+		return errors.Wrap(err, "failed to parse machines manifest")
+	}
+	targets, err := selectTargets(machines, eiMachines, resetOptions.machines)
+	if err != nil {
+		return err
+	}
+
+	if !resetOptions.yes {
+		ok, err := confirm(os.Stdin, os.Stdout, targets)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return errors.New("reset aborted")
+		}
+	}
+
+	var failed []string
+	for _, t := range targets {
+		logger := log.WithField("machine", t.machine.Name)
+		logger.Info("Resetting")
+		if err := resetMachine(cmd.Context(), t, sp.Cluster, sp.ClusterSpec.User); err != nil {
+			logger.Errorf("Reset failed: %v", err)
+			failed = append(failed, t.machine.Name)
+			continue
+		}
+		logger.Info("Reset")
+	}
+	if len(failed) > 0 {
+		return errors.Errorf("failed to reset machines: %s", strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+// selectTargets pairs machines with their ExistingInfraMachine, keeping the
+// named ones if any, and orders them so that workers are reset before masters.
+func selectTargets(machines []*clusterv1.Machine, eiMachines []*existinginfrav1.ExistingInfraMachine, names []string) ([]target, error) {
+	if len(machines) != len(eiMachines) {
+		return nil, errors.Errorf("the machines manifest holds %d Machine and %d ExistingInfraMachine objects", len(machines), len(eiMachines))
+	}
+	selected := map[string]bool{}
+	for _, name := range names {
+		selected[name] = false
+	}
+
+	var targets []target
+	for i, m := range machines {
+		if _, ok := selected[m.Name]; len(names) > 0 && !ok {
+			continue
+		}
+		selected[m.Name] = true
+		targets = append(targets, target{machine: m, eiMachine: eiMachines[i]})
+	}
+
+	var unknown []string
+	for name, found := range selected {
+		if !found {
+			unknown = append(unknown, name)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return nil, errors.Errorf("no such machine in the machines manifest: %s", strings.Join(unknown, ", "))
+	}
+
+	sort.SliceStable(targets, func(i, j int) bool {
+		return !capeimachine.IsMaster(targets[i].machine) && capeimachine.IsMaster(targets[j].machine)
+	})
+	return targets, nil
+}
+
+func confirm(in io.Reader, out io.Writer, targets []target) (bool, error) {
+	fmt.Fprintln(out, "The following machines will be reset, destroying the Kubernetes cluster running on them:")
+	for _, t := range targets {
+		fmt.Fprintf(out, "  %s (%s)\n", t.machine.Name, t.eiMachine.Spec.Public.Address)
+	}
+	fmt.Fprint(out, "Continue? [y/N] ")
+	answer, err := bufio.NewReader(in).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return false, errors.Wrap(err, "failed to read answer")
+	}
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes", nil
+}
+
+func resetMachine(ctx context.Context, t target, cluster *clusterv1.Cluster, user string) error {
+	sshClient, err := resetOptions.sshOptions.NewClientForMachine(cluster, &t.eiMachine.Spec, user, logging.Verbose())
+	if err != nil {
+		return errors.Wrap(err, "failed to create SSH client")
+	}
+	defer sshClient.Close()
+	runner := &sudo.Runner{Runner: sshClient}
+	return recipe.BuildResetPlan(capeimachine.GetKubernetesVersion(t.machine)).Undo(ctx, runner, plan.EmptyState)
+}
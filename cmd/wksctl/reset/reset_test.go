@@ -0,0 +1,108 @@
+package reset
+
+import (
+	"bytes"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	existinginfrav1 "github.com/weaveworks/cluster-api-provider-existinginfra/apis/cluster.weave.works/v1alpha3"
+	capeimachine "github.com/weaveworks/cluster-api-provider-existinginfra/pkg/cluster/machine"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha3"
+)
+
+const machinesManifest = `apiVersion: cluster.x-k8s.io/v1alpha3
+kind: Machine
+metadata:
+  labels:
+    set: master
+  name: master-1
+spec:
+  clusterName: example
+  infrastructureRef:
+    apiVersion: cluster.weave.works/v1alpha3
+    kind: ExistingInfraMachine
+    name: master-1
+---
+apiVersion: cluster.weave.works/v1alpha3
+kind: ExistingInfraMachine
+metadata:
+  name: master-1
+spec:
+  public:
+    address: 10.0.0.1
+    port: 22
+---
+apiVersion: cluster.x-k8s.io/v1alpha3
+kind: Machine
+metadata:
+  labels:
+    set: worker
+  name: worker-1
+spec:
+  clusterName: example
+  infrastructureRef:
+    apiVersion: cluster.weave.works/v1alpha3
+    kind: ExistingInfraMachine
+    name: worker-1
+---
+apiVersion: cluster.weave.works/v1alpha3
+kind: ExistingInfraMachine
+metadata:
+  name: worker-1
+spec:
+  public:
+    address: 10.0.0.2
+    port: 22
+`
+
+func parseMachines(t *testing.T) ([]*clusterv1.Machine, []*existinginfrav1.ExistingInfraMachine) {
+	machines, eiMachines, err := capeimachine.Parse(ioutil.NopCloser(strings.NewReader(machinesManifest)))
+	require.NoError(t, err)
+	return machines, eiMachines
+}
+
+func names(targets []target) []string {
+	var names []string
+	for _, t := range targets {
+		names = append(names, t.machine.Name)
+	}
+	return names
+}
+
+func TestSelectAllTargetsWorkersFirst(t *testing.T) {
+	machines, eiMachines := parseMachines(t)
+	targets, err := selectTargets(machines, eiMachines, nil)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"worker-1", "master-1"}, names(targets))
+	assert.Equal(t, "10.0.0.2", targets[0].eiMachine.Spec.Public.Address)
+}
+
+func TestSelectNamedTargets(t *testing.T) {
+	machines, eiMachines := parseMachines(t)
+	targets, err := selectTargets(machines, eiMachines, []string{"master-1"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"master-1"}, names(targets))
+}
+
+func TestSelectUnknownTarget(t *testing.T) {
+	machines, eiMachines := parseMachines(t)
+	_, err := selectTargets(machines, eiMachines, []string{"master-1", "master-2"})
+	assert.EqualError(t, err, "no such machine in the machines manifest: master-2")
+}
+
+func TestConfirm(t *testing.T) {
+	machines, eiMachines := parseMachines(t)
+	targets, err := selectTargets(machines, eiMachines, nil)
+	require.NoError(t, err)
+
+	for answer, expected := range map[string]bool{"y\n": true, "Yes\n": true, "n\n": false, "\n": false, "": false} {
+		var out bytes.Buffer
+		ok, err := confirm(strings.NewReader(answer), &out, targets)
+		require.NoError(t, err)
+		assert.Equal(t, expected, ok, "answer %q", answer)
+		assert.Contains(t, out.String(), "worker-1 (10.0.0.2)")
+	}
+}
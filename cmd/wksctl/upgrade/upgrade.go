@@ -0,0 +1,237 @@
+package upgrade
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	existinginfrav1 "github.com/weaveworks/cluster-api-provider-existinginfra/apis/cluster.weave.works/v1alpha3"
+	capeios "github.com/weaveworks/cluster-api-provider-existinginfra/pkg/apis/wksprovider/machine/os"
+	capeimachine "github.com/weaveworks/cluster-api-provider-existinginfra/pkg/cluster/machine"
+	"github.com/weaveworks/cluster-api-provider-existinginfra/pkg/plan"
+	capeirecipe "github.com/weaveworks/cluster-api-provider-existinginfra/pkg/plan/recipe"
+	capeispecs "github.com/weaveworks/cluster-api-provider-existinginfra/pkg/specs"
+	"github.com/weaveworks/wksctl/pkg/kubernetes"
+	"github.com/weaveworks/wksctl/pkg/plan/runners/ssh"
+	"github.com/weaveworks/wksctl/pkg/specs"
+	"github.com/weaveworks/wksctl/pkg/utilities/logging"
+	"github.com/weaveworks/wksctl/pkg/utilities/manifest"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha3"
+)
+
+// Cmd represents the upgrade command
+var Cmd = &cobra.Command{
+	Use:   "upgrade",
+	Short: "Upgrade the version of Kubernetes the cluster runs",
+	Long: `Upgrade the version of Kubernetes the cluster runs, one node at a time:
+the first master is upgraded by "kubeadm upgrade apply", then the other masters
+and the workers by "kubeadm upgrade node". Each node is drained before being
+upgraded, and uncordoned once it is ready again.
+
+The Kubernetes version of the cluster and machines manifests is updated once
+all nodes are upgraded, so that the controller, which upgrades nodes whose
+version differs from their manifest's, finds them upgraded already.`,
+	Args:         cobra.NoArgs,
+	RunE:         upgradeRun,
+	SilenceUsage: true,
+}
+
+var upgradeOptions struct {
+	clusterManifestPath  string
+	machinesManifestPath string
+	sshOptions           ssh.Options
+	version              string
+}
+
+func init() {
+	Cmd.Flags().StringVar(&upgradeOptions.clusterManifestPath, "cluster", "cluster.yaml", "Location of cluster manifest")
+	Cmd.Flags().StringVar(&upgradeOptions.machinesManifestPath, "machines", "machines.yaml", "Location of machines manifest")
+	upgradeOptions.sshOptions.AddFlags(Cmd.Flags())
+	Cmd.Flags().StringVar(&upgradeOptions.version, "to", "", "Kubernetes version to upgrade to, e.g. 1.19.7")
+	_ = Cmd.MarkFlagRequired("to")
+}
+
+// kubectl runs on the first master, as the cluster's administrator.
+const kubectl = "kubectl --kubeconfig=/etc/kubernetes/admin.conf"
+
+// node is a machine to upgrade.
+type node struct {
+	machine   *clusterv1.Machine
+	eiMachine *existinginfrav1.ExistingInfraMachine
+	nodeType  capeirecipe.NodeType
+	// name is the name of the machine's node in the cluster.
+	name string
+}
+
+func upgradeRun(cmd *cobra.Command, args []string) error {
+	ctx := cmd.Context()
+	version := strings.TrimPrefix(upgradeOptions.version, "v")
+	sp := specs.NewFromPaths(upgradeOptions.clusterManifestPath, upgradeOptions.machinesManifestPath)
+	upgradeOptions.sshOptions.MachinesPath = upgradeOptions.machinesManifestPath
+	machines, eiMachines, err := capeimachine.ParseManifest(upgradeOptions.machinesManifestPath)
+	if err != nil {
+		return errors.Wrap(err, "failed to parse machines manifest")
+	}
+	current, _, err := capeimachine.GetKubernetesVersionFromMasterIn(machines, eiMachines)
+	if err != nil {
+		return errors.Wrap(err, "invalid machines manifest")
+	}
+	if err := kubernetes.ValidateUpgrade(current, version); err != nil {
+		return err
+	}
+
+	nodes := upgradeOrder(machines, eiMachines)
+	master, closeMaster, err := connect(ctx, sp, nodes[0])
+	if err != nil {
+		return err
+	}
+	defer closeMaster()
+	names, err := nodeNames(ctx, master.Runner)
+	if err != nil {
+		return err
+	}
+	// All nodes are found before upgrading any, not to leave the cluster
+	// half-upgraded.
+	if err := nameNodes(nodes, names); err != nil {
+		return err
+	}
+
+	log.Infof("Upgrading Kubernetes from %s to %s", current, version)
+	for _, n := range nodes {
+		installer, closeNode, err := connect(ctx, sp, n)
+		if err != nil {
+			return err
+		}
+		err = upgradeNode(ctx, master.Runner, installer, n.name, n.nodeType, version)
+		closeNode()
+		if err != nil {
+			return errors.Wrapf(err, "failed to upgrade machine %s, whose node %s is left cordoned", n.machine.Name, n.name)
+		}
+	}
+
+	for _, path := range []string{upgradeOptions.clusterManifestPath, upgradeOptions.machinesManifestPath} {
+		if err := setManifestVersion(path, version); err != nil {
+			return err
+		}
+	}
+	fmt.Printf("Kubernetes was upgraded to %s, commit the updated manifests to your cluster's repository\n", version)
+	return nil
+}
+
+// upgradeOrder returns the machines in the order they have to be upgraded in:
+// the first master, the other masters, then the workers.
+func upgradeOrder(machines []*clusterv1.Machine, eiMachines []*existinginfrav1.ExistingInfraMachine) []node {
+	var masters, workers []node
+	for i, m := range machines {
+		if capeimachine.IsMaster(m) {
+			nodeType := capeirecipe.SecondaryMaster
+			if len(masters) == 0 {
+				nodeType = capeirecipe.OriginalMaster
+			}
+			masters = append(masters, node{machine: m, eiMachine: eiMachines[i], nodeType: nodeType})
+		} else {
+			// Since Kubernetes 1.16, "kubeadm upgrade node", which secondary
+			// masters run, upgrades workers too.
+			workers = append(workers, node{machine: m, eiMachine: eiMachines[i], nodeType: capeirecipe.SecondaryMaster})
+		}
+	}
+	return append(masters, workers...)
+}
+
+func connect(ctx context.Context, sp *capeispecs.Specs, n node) (*capeios.OS, func(), error) {
+	sshClient, err := upgradeOptions.sshOptions.NewClientForMachine(sp.Cluster, &n.eiMachine.Spec, sp.ClusterSpec.User, logging.Verbose())
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "failed to create SSH client for machine %s", n.machine.Name)
+	}
+	installer, err := capeios.Identify(ctx, sshClient)
+	if err != nil {
+		sshClient.Close()
+		return nil, nil, errors.Wrapf(err, "failed to identify operating system of machine %s", n.machine.Name)
+	}
+	return installer, func() { sshClient.Close() }, nil
+}
+
+// nodeNames returns the names of the nodes of the cluster by private address.
+func nodeNames(ctx context.Context, runner plan.Runner) (map[string]string, error) {
+	out, err := runner.RunCommand(ctx, kubectl+` get nodes -o jsonpath='{range .items[*]}{.metadata.name}{" "}{range .status.addresses[?(@.type=="InternalIP")]}{.address}{end}{"\n"}{end}'`, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list nodes")
+	}
+	names := map[string]string{}
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 2 {
+			names[fields[1]] = fields[0]
+		}
+	}
+	return names, nil
+}
+
+// nameNodes sets the names of the nodes of the machines, from the names of the
+// nodes of the cluster by private address. It fails if one of the machines has
+// no node.
+func nameNodes(nodes []node, names map[string]string) error {
+	var missing []string
+	for i := range nodes {
+		address := nodes[i].eiMachine.Spec.Private.Address
+		name, ok := names[address]
+		if !ok {
+			missing = append(missing, fmt.Sprintf("%s of machine %s", address, nodes[i].machine.Name))
+			continue
+		}
+		nodes[i].name = name
+	}
+	if len(missing) > 0 {
+		return errors.Errorf("no node of the cluster has the private address %s, no node was upgraded", strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// upgradeNode drains the named node, upgrades it and uncordons it once ready.
+// kubectl is run by the provided runner.
+func upgradeNode(ctx context.Context, kubectlRunner plan.Runner, installer *capeios.OS, name string, nodeType capeirecipe.NodeType, version string) error {
+	logger := log.WithField("node", name)
+	logger.Info("Draining")
+	if _, err := kubectlRunner.RunCommand(ctx, fmt.Sprintf("%s drain %s --ignore-daemonsets --delete-local-data --timeout=5m", kubectl, name), nil); err != nil {
+		return errors.Wrap(err, "failed to drain node")
+	}
+
+	logger.Info("Upgrading")
+	p, err := capeirecipe.BuildUpgradePlan(installer.PkgType, version, nodeType)
+	if err != nil {
+		return errors.Wrap(err, "failed to build upgrade plan")
+	}
+	if _, err := p.Apply(ctx, installer.Runner, plan.EmptyDiff()); err != nil {
+		return err
+	}
+
+	if _, err := kubectlRunner.RunCommand(ctx, fmt.Sprintf("%s wait --for=condition=Ready node/%s --timeout=5m", kubectl, name), nil); err != nil {
+		return errors.Wrap(err, "node didn't get ready after upgrade")
+	}
+	logger.Info("Uncordoning")
+	if _, err := kubectlRunner.RunCommand(ctx, fmt.Sprintf("%s uncordon %s", kubectl, name), nil); err != nil {
+		return errors.Wrap(err, "failed to uncordon node")
+	}
+	return nil
+}
+
+func setManifestVersion(path, version string) error {
+	contents, err := ioutil.ReadFile(path)
+	if err != nil {
+		return errors.Wrapf(err, "failed to read %s", path)
+	}
+	updated, err := manifest.SetKubernetesVersion(contents, version)
+	if err != nil {
+		return errors.Wrapf(err, "failed to update %s", path)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, updated, info.Mode())
+}
@@ -0,0 +1,135 @@
+package upgrade
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	capeios "github.com/weaveworks/cluster-api-provider-existinginfra/pkg/apis/wksprovider/machine/os"
+	capeimachine "github.com/weaveworks/cluster-api-provider-existinginfra/pkg/cluster/machine"
+	capeirecipe "github.com/weaveworks/cluster-api-provider-existinginfra/pkg/plan/recipe"
+	"github.com/weaveworks/cluster-api-provider-existinginfra/pkg/plan/resource"
+)
+
+const machinesManifest = `apiVersion: cluster.x-k8s.io/v1alpha3
+kind: Machine
+metadata:
+  name: node-1
+spec:
+  clusterName: example
+---
+apiVersion: cluster.x-k8s.io/v1alpha3
+kind: Machine
+metadata:
+  labels:
+    set: master
+  name: master-1
+spec:
+  clusterName: example
+---
+apiVersion: cluster.x-k8s.io/v1alpha3
+kind: Machine
+metadata:
+  labels:
+    set: master
+  name: master-2
+spec:
+  clusterName: example
+---
+apiVersion: cluster.weave.works/v1alpha3
+kind: ExistingInfraMachine
+metadata:
+  name: node-1
+---
+apiVersion: cluster.weave.works/v1alpha3
+kind: ExistingInfraMachine
+metadata:
+  name: master-1
+---
+apiVersion: cluster.weave.works/v1alpha3
+kind: ExistingInfraMachine
+metadata:
+  name: master-2
+`
+
+func TestUpgradeOrder(t *testing.T) {
+	machines, eiMachines, err := capeimachine.Parse(ioutil.NopCloser(strings.NewReader(machinesManifest)))
+	require.NoError(t, err)
+
+	nodes := upgradeOrder(machines, eiMachines)
+	var names []string
+	var types []capeirecipe.NodeType
+	for _, n := range nodes {
+		names = append(names, n.machine.Name)
+		assert.Equal(t, n.machine.Name, n.eiMachine.Name)
+		types = append(types, n.nodeType)
+	}
+	assert.Equal(t, []string{"master-1", "master-2", "node-1"}, names)
+	assert.Equal(t, []capeirecipe.NodeType{capeirecipe.OriginalMaster, capeirecipe.SecondaryMaster, capeirecipe.SecondaryMaster}, types)
+}
+
+type recordingRunner struct {
+	commands []string
+	output   string
+}
+
+func (r *recordingRunner) RunCommand(_ context.Context, cmd string, _ io.Reader) (string, error) {
+	r.commands = append(r.commands, cmd)
+	return r.output, nil
+}
+
+func TestNodeNames(t *testing.T) {
+	r := &recordingRunner{output: "master-1 172.17.0.2\nnode-1 172.17.0.3\nnot-ready\n"}
+	names, err := nodeNames(context.Background(), r)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"172.17.0.2": "master-1", "172.17.0.3": "node-1"}, names)
+}
+
+func TestNameNodes(t *testing.T) {
+	machines, eiMachines, err := capeimachine.Parse(ioutil.NopCloser(strings.NewReader(machinesManifest)))
+	require.NoError(t, err)
+	for i, address := range []string{"172.17.0.3", "172.17.0.2", "172.17.0.4"} {
+		eiMachines[i].Spec.Private.Address = address
+	}
+	nodes := upgradeOrder(machines, eiMachines)
+
+	err = nameNodes(nodes, map[string]string{"172.17.0.2": "master-1"})
+	require.Error(t, err)
+	assert.Equal(t, "no node of the cluster has the private address 172.17.0.4 of machine master-2, 172.17.0.3 of machine node-1, no node was upgraded", err.Error())
+
+	require.NoError(t, nameNodes(nodes, map[string]string{"172.17.0.2": "master-1", "172.17.0.3": "node-1", "172.17.0.4": "master-2"}))
+	for _, n := range nodes {
+		assert.Equal(t, n.machine.Name, n.name)
+	}
+}
+
+func indexOf(commands []string, substr string) int {
+	for i, cmd := range commands {
+		if strings.Contains(cmd, substr) {
+			return i
+		}
+	}
+	return -1
+}
+
+func TestUpgradeNode(t *testing.T) {
+	kubectlRunner := &recordingRunner{}
+	nodeRunner := &recordingRunner{}
+	installer := &capeios.OS{Name: capeios.Ubuntu, Runner: nodeRunner, PkgType: resource.PkgTypeDeb}
+	require.NoError(t, upgradeNode(context.Background(), kubectlRunner, installer, "master-1", capeirecipe.OriginalMaster, "1.19.7"))
+
+	require.Len(t, kubectlRunner.commands, 3)
+	assert.Contains(t, kubectlRunner.commands[0], "drain master-1")
+	assert.Contains(t, kubectlRunner.commands[1], "wait --for=condition=Ready node/master-1")
+	assert.Contains(t, kubectlRunner.commands[2], "uncordon master-1")
+
+	kubelet := indexOf(nodeRunner.commands, "kubelet=1.19.7-00")
+	upgrade := indexOf(nodeRunner.commands, "kubeadm upgrade plan && kubeadm upgrade apply -y 1.19.7")
+	assert.NotEqual(t, -1, kubelet, "missing command in %v", nodeRunner.commands)
+	assert.NotEqual(t, -1, upgrade, "missing command in %v", nodeRunner.commands)
+	assert.Less(t, kubelet, upgrade)
+}
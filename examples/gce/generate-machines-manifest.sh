@@ -4,5 +4,9 @@ set -o errexit
 set -o pipefail
 set -o nounset
 
-gcloud compute --project="${project}" instances list --filter="zone:(${zone}) AND name~'^${user}-wks-\d+$'" --format=json > instances.json
-jk run -p user=$user generate-machines-manifest.js
+{
+    echo "name,public_address,private_address"
+    gcloud compute --project="${project}" instances list --filter="zone:(${zone}) AND name~'^${user}-wks-\d+$'" --sort-by=name \
+        --format="csv[no-heading](name,networkInterfaces[0].accessConfigs[0].natIP,networkInterfaces[0].networkIP)"
+} > hosts.csv
+wksctl machines generate --from hosts.csv --masters 1 --cluster cluster.yaml --output machines.yaml
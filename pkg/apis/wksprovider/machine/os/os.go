@@ -23,6 +23,7 @@ import (
 	"github.com/weaveworks/cluster-api-provider-existinginfra/pkg/specs"
 	"github.com/weaveworks/cluster-api-provider-existinginfra/pkg/utilities/object"
 	"github.com/weaveworks/libgitops/pkg/serializer"
+	"golang.org/x/crypto/ssh"
 	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/tools/clientcmd"
@@ -36,22 +37,24 @@ var (
 	pemKeys = []string{"certificate-authority", "client-certificate", "client-key"}
 )
 
-// SetupSeedNode installs Kubernetes on this machine, and store the provided
-// manifests in the API server, so that the rest of the cluster can then be
-// set up by the WKS controller.
-func SetupSeedNode(o *capeios.OS, params capeios.SeedNodeParams) error {
-	ctx := context.Background()
+// CreateSeedNodePlan builds the plan which installs Kubernetes on the seed
+// node, and stores the provided manifests in the API server, so that the rest
+// of the cluster can then be set up by the WKS controller. It includes the
+// resources installing the secrets referenced by the cluster's authentication
+// and authorization specifications. Building the plan doesn't change anything
+// on the seed node.
+func CreateSeedNodePlan(ctx context.Context, o *capeios.OS, params capeios.SeedNodeParams) (*plan.Plan, error) {
 	sp, updatedParams, err := createSecretPlan(o, params)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	updatedParams, err = createMachinePoolInfo(updatedParams)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	p, err := capeios.CreateSeedNodeSetupPlan(ctx, o, updatedParams)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	if sp != nil {
 		b := plan.NewBuilder()
@@ -59,11 +62,11 @@ func SetupSeedNode(o *capeios.OS, params capeios.SeedNodeParams) error {
 		b.AddResource("install:seed-node", p)
 		plan, err := b.Plan()
 		if err != nil {
-			return err
+			return nil, err
 		}
 		p = &plan
 	}
-	return capeios.ApplyPlan(ctx, o, p)
+	return p, nil
 }
 
 func UnparseCluster(c *clusterv1.Cluster, eic *existinginfrav1.ExistingInfraCluster) ([]byte, error) {
@@ -99,11 +102,21 @@ func createMachinePoolInfo(params capeios.SeedNodeParams) (capeios.SeedNodeParam
 	// we want this to match future plans to avoid spurious repaves; normal node plan creation
 	// doesn't place a value in DeprecatedSSHKeyPath
 	params.ExistingInfraCluster.Spec.DeprecatedSSHKeyPath = ""
-	sshKey, err := ioutil.ReadFile(keyPath)
-	if err != nil {
-		return capeios.SeedNodeParams{}, err
+	// No key is provided when rendering plans offline, without access to the machines.
+	var encodedKey string
+	if keyPath != "" {
+		sshKey, err := ioutil.ReadFile(keyPath)
+		if err != nil {
+			return capeios.SeedNodeParams{}, err
+		}
+		// The controller has no way to decrypt the key.
+		if _, err := ssh.ParseRawPrivateKey(sshKey); err != nil {
+			if _, ok := err.(*ssh.PassphraseMissingError); ok {
+				return capeios.SeedNodeParams{}, errors.Errorf("the controller's SSH key %s must not be protected by a passphrase", keyPath)
+			}
+		}
+		encodedKey = base64.StdEncoding.EncodeToString(sshKey)
 	}
-	encodedKey := base64.StdEncoding.EncodeToString(sshKey)
 	return augmentParamsWithPool(eic.Spec.User, encodedKey, eims, params), nil
 }
 
@@ -0,0 +1,403 @@
+package machine
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	existinginfra1 "github.com/weaveworks/cluster-api-provider-existinginfra/apis/cluster.weave.works/v1alpha3"
+	yaml "gopkg.in/yaml.v3"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha3"
+)
+
+// Inventories list the hosts of a cluster, as:
+// - CSV files, whose header names the name, public_address, public_port,
+//   private_address and private_port columns, all optional but public_address,
+// - Ansible inventories, in INI or YAML, whose hosts are connected to at
+//   ansible_host and ansible_port, and have a private address if ip or
+//   private_ip is set,
+// - the JSON output of Terraform, whose public_ips, private_ips and names
+//   outputs are lists of the public addresses of the hosts, and of their
+//   private addresses and names, if any.
+// Addresses default to the public ones, and ports to 22.
+
+const defaultSSHPort = "22"
+
+// Host is a host of an inventory.
+type Host struct {
+	// Name is the name of the host in the inventory, if any.
+	Name    string
+	Public  existinginfra1.EndPoint
+	Private existinginfra1.EndPoint
+}
+
+// NewHost returns the host with the addresses and ports, the private ones
+// defaulting to the public ones, and ports to 22.
+func NewHost(name, publicAddress, publicPort, privateAddress, privatePort string) (Host, error) {
+	if publicAddress == "" {
+		return Host{}, errors.Errorf("host %q has no address", name)
+	}
+	if publicPort == "" {
+		publicPort = defaultSSHPort
+	}
+	if privateAddress == "" {
+		privateAddress = publicAddress
+		if privatePort == "" {
+			privatePort = publicPort
+		}
+	}
+	if privatePort == "" {
+		privatePort = defaultSSHPort
+	}
+	host := Host{Name: name}
+	for _, e := range []struct {
+		endPoint      *existinginfra1.EndPoint
+		address, port string
+	}{{&host.Public, publicAddress, publicPort}, {&host.Private, privateAddress, privatePort}} {
+		port, err := strconv.ParseUint(e.port, 10, 16)
+		if err != nil {
+			return Host{}, errors.Errorf("invalid port %q of host %q", e.port, name)
+		}
+		*e.endPoint = existinginfra1.EndPoint{Address: e.address, Port: uint16(port)}
+	}
+	return host, nil
+}
+
+// ReadInventory reads the hosts of the inventory, in the format of its
+// extension: .csv, .json for Terraform, .yaml or .yml for Ansible, or else
+// Ansible INI.
+func ReadInventory(path string) ([]Host, error) {
+	contents, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var hosts []Host
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".csv":
+		hosts, err = parseCSVInventory(contents)
+	case ".json":
+		hosts, err = parseTerraformOutput(contents)
+	case ".yaml", ".yml":
+		hosts, err = parseAnsibleYAMLInventory(contents)
+	default:
+		hosts, err = parseAnsibleINIInventory(contents)
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read inventory %s", path)
+	}
+	if len(hosts) == 0 {
+		return nil, errors.Errorf("no hosts in inventory %s", path)
+	}
+	return hosts, nil
+}
+
+func parseCSVInventory(contents []byte) ([]Host, error) {
+	r := csv.NewReader(bytes.NewReader(contents))
+	r.Comment = '#'
+	r.TrimLeadingSpace = true
+	header, err := r.Read()
+	if err != nil {
+		return nil, err
+	}
+	columns := map[string]int{}
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	if _, ok := columns["public_address"]; !ok {
+		return nil, errors.New("no public_address column")
+	}
+	var hosts []Host
+	for {
+		record, err := r.Read()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		value := func(column string) string {
+			if i, ok := columns[column]; ok {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+		host, err := NewHost(value("name"), value("public_address"), value("public_port"), value("private_address"), value("private_port"))
+		if err != nil {
+			return nil, err
+		}
+		hosts = append(hosts, host)
+	}
+	return hosts, nil
+}
+
+func parseTerraformOutput(contents []byte) ([]Host, error) {
+	var outputs map[string]struct {
+		Value json.RawMessage `json:"value"`
+	}
+	if err := json.Unmarshal(contents, &outputs); err != nil {
+		return nil, err
+	}
+	lists := map[string][]string{}
+	for _, name := range []string{"public_ips", "private_ips", "names"} {
+		output, ok := outputs[name]
+		if !ok {
+			continue
+		}
+		var list []string
+		if err := json.Unmarshal(output.Value, &list); err != nil {
+			return nil, errors.Wrapf(err, "output %s is not a list of strings", name)
+		}
+		lists[name] = list
+	}
+	public, ok := lists["public_ips"]
+	if !ok {
+		return nil, errors.New("no public_ips output")
+	}
+	for _, name := range []string{"private_ips", "names"} {
+		if list, ok := lists[name]; ok && len(list) != len(public) {
+			return nil, errors.Errorf("outputs public_ips and %s have different lengths", name)
+		}
+	}
+	var hosts []Host
+	for i, address := range public {
+		var name, private string
+		if lists["names"] != nil {
+			name = lists["names"][i]
+		}
+		if lists["private_ips"] != nil {
+			private = lists["private_ips"][i]
+		}
+		host, err := NewHost(name, address, "", private, "")
+		if err != nil {
+			return nil, err
+		}
+		hosts = append(hosts, host)
+	}
+	return hosts, nil
+}
+
+// ansibleHosts collects the hosts of an Ansible inventory, in the order they
+// first appear in, merging the variables of hosts listed in several groups.
+type ansibleHosts struct {
+	names []string
+	vars  map[string]map[string]string
+}
+
+func (h *ansibleHosts) add(name string, vars map[string]string) {
+	if h.vars == nil {
+		h.vars = map[string]map[string]string{}
+	}
+	if _, ok := h.vars[name]; !ok {
+		h.names = append(h.names, name)
+		h.vars[name] = map[string]string{}
+	}
+	for k, v := range vars {
+		h.vars[name][k] = v
+	}
+}
+
+func (h *ansibleHosts) hosts() ([]Host, error) {
+	var hosts []Host
+	for _, name := range h.names {
+		vars := h.vars[name]
+		public := vars["ansible_host"]
+		if public == "" {
+			public = name
+		}
+		private := vars["private_ip"]
+		if private == "" {
+			private = vars["ip"]
+		}
+		host, err := NewHost(name, public, vars["ansible_port"], private, "")
+		if err != nil {
+			return nil, err
+		}
+		hosts = append(hosts, host)
+	}
+	return hosts, nil
+}
+
+func parseAnsibleINIInventory(contents []byte) ([]Host, error) {
+	var h ansibleHosts
+	// Hosts are listed out of any section, or in the sections of groups, but
+	// not in the ones of their variables or children.
+	hostSection := true
+	scanner := bufio.NewScanner(bytes.NewReader(contents))
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") || strings.HasPrefix(text, ";") {
+			continue
+		}
+		if strings.HasPrefix(text, "[") {
+			if !strings.HasSuffix(text, "]") {
+				return nil, errors.Errorf("invalid section at line %d", line)
+			}
+			hostSection = !strings.Contains(text, ":")
+			continue
+		}
+		if !hostSection {
+			continue
+		}
+		fields := strings.Fields(text)
+		if strings.ContainsAny(fields[0], "[]") {
+			return nil, errors.Errorf("host patterns are not supported, at line %d", line)
+		}
+		vars := map[string]string{}
+		for _, field := range fields[1:] {
+			kv := strings.SplitN(field, "=", 2)
+			if len(kv) != 2 {
+				return nil, errors.Errorf("invalid variable %q at line %d", field, line)
+			}
+			vars[kv[0]] = strings.Trim(kv[1], `"'`)
+		}
+		h.add(fields[0], vars)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return h.hosts()
+}
+
+func parseAnsibleYAMLInventory(contents []byte) ([]Host, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(contents, &doc); err != nil {
+		return nil, err
+	}
+	var h ansibleHosts
+	if len(doc.Content) == 0 {
+		return nil, nil
+	}
+	if err := addAnsibleGroups(&h, doc.Content[0]); err != nil {
+		return nil, err
+	}
+	return h.hosts()
+}
+
+// addAnsibleGroups adds the hosts of the mapping of group names to groups.
+func addAnsibleGroups(h *ansibleHosts, groups *yaml.Node) error {
+	if groups.Kind == yaml.ScalarNode && groups.Tag == "!!null" {
+		return nil
+	}
+	if groups.Kind != yaml.MappingNode {
+		return errors.Errorf("invalid groups at line %d", groups.Line)
+	}
+	for i := 0; i+1 < len(groups.Content); i += 2 {
+		group := groups.Content[i+1]
+		if group.Kind == yaml.ScalarNode && group.Tag == "!!null" {
+			continue
+		}
+		if group.Kind != yaml.MappingNode {
+			return errors.Errorf("invalid group %s at line %d", groups.Content[i].Value, group.Line)
+		}
+		for j := 0; j+1 < len(group.Content); j += 2 {
+			switch value := group.Content[j+1]; group.Content[j].Value {
+			case "hosts":
+				if err := addAnsibleHosts(h, value); err != nil {
+					return err
+				}
+			case "children":
+				if err := addAnsibleGroups(h, value); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func addAnsibleHosts(h *ansibleHosts, hosts *yaml.Node) error {
+	if hosts.Kind == yaml.ScalarNode && hosts.Tag == "!!null" {
+		return nil
+	}
+	if hosts.Kind != yaml.MappingNode {
+		return errors.Errorf("invalid hosts at line %d", hosts.Line)
+	}
+	for i := 0; i+1 < len(hosts.Content); i += 2 {
+		vars := map[string]string{}
+		if v := hosts.Content[i+1]; v.Kind == yaml.MappingNode {
+			for j := 0; j+1 < len(v.Content); j += 2 {
+				if v.Content[j+1].Kind == yaml.ScalarNode {
+					vars[v.Content[j].Value] = v.Content[j+1].Value
+				}
+			}
+		}
+		h.add(hosts.Content[i].Value, vars)
+	}
+	return nil
+}
+
+// GenerateMachines returns the Machine and ExistingInfraMachine objects of the
+// hosts of the cluster, the first ones of which are masters. Machines are named
+// after their hosts, or after their role if the names of the hosts aren't
+// valid names of objects.
+func GenerateMachines(hosts []Host, masters int, clusterName, kubernetesVersion string) ([]*clusterv1.Machine, []*existinginfra1.ExistingInfraMachine, error) {
+	if masters < 1 || masters > len(hosts) {
+		return nil, nil, errors.Errorf("cannot have %d masters out of %d hosts", masters, len(hosts))
+	}
+	namesTaken := map[string]struct{}{}
+	for _, host := range hosts {
+		if host.Name == "" || len(validation.IsDNS1123Subdomain(host.Name)) > 0 {
+			continue
+		}
+		if _, taken := namesTaken[host.Name]; taken {
+			return nil, nil, errors.Errorf("duplicate host %s", host.Name)
+		}
+		namesTaken[host.Name] = struct{}{}
+	}
+
+	var machines []*clusterv1.Machine
+	var bml []*existinginfra1.ExistingInfraMachine
+	for i, host := range hosts {
+		role := "worker"
+		if i < masters {
+			role = "master"
+		}
+		name := host.Name
+		if name == "" || len(validation.IsDNS1123Subdomain(name)) > 0 {
+			name = uniqueNameFrom(role+"-", namesTaken)
+		}
+		machine, eim := newMachine(name, role, clusterName, kubernetesVersion, host)
+		machines = append(machines, machine)
+		bml = append(bml, eim)
+	}
+	return machines, bml, nil
+}
+
+// newMachine returns the Machine and ExistingInfraMachine objects of the host.
+func newMachine(name, role, clusterName, kubernetesVersion string, host Host) (*clusterv1.Machine, *existinginfra1.ExistingInfraMachine) {
+	machine := &clusterv1.Machine{
+		TypeMeta: metav1.TypeMeta{APIVersion: clusterv1.GroupVersion.String(), Kind: "Machine"},
+		ObjectMeta: metav1.ObjectMeta{
+			Name: name,
+			Labels: map[string]string{
+				"set":                      role,
+				clusterv1.ClusterLabelName: clusterName,
+			},
+		},
+		Spec: clusterv1.MachineSpec{
+			ClusterName: clusterName,
+			InfrastructureRef: corev1.ObjectReference{
+				APIVersion: existinginfra1.GroupVersion.String(),
+				Kind:       "ExistingInfraMachine",
+				Name:       name + "-provider",
+			},
+		},
+	}
+	if kubernetesVersion != "" {
+		machine.Spec.Version = &kubernetesVersion
+	}
+	return machine, &existinginfra1.ExistingInfraMachine{
+		TypeMeta:   metav1.TypeMeta{APIVersion: existinginfra1.GroupVersion.String(), Kind: "ExistingInfraMachine"},
+		ObjectMeta: metav1.ObjectMeta{Name: name + "-provider"},
+		Spec:       existinginfra1.MachineSpec{Public: host.Public, Private: host.Private},
+	}
+}
@@ -0,0 +1,156 @@
+package machine_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	existinginfrav1 "github.com/weaveworks/cluster-api-provider-existinginfra/apis/cluster.weave.works/v1alpha3"
+	capeimachine "github.com/weaveworks/cluster-api-provider-existinginfra/pkg/cluster/machine"
+	"github.com/weaveworks/wksctl/pkg/cluster/machine"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha3"
+)
+
+var inventoryHosts = []machine.Host{
+	{Name: "node1", Public: existinginfrav1.EndPoint{Address: "34.1.1.1", Port: 22}, Private: existinginfrav1.EndPoint{Address: "10.0.0.1", Port: 22}},
+	{Name: "node2", Public: existinginfrav1.EndPoint{Address: "34.1.1.2", Port: 2222}, Private: existinginfrav1.EndPoint{Address: "10.0.0.2", Port: 22}},
+	{Name: "node3", Public: existinginfrav1.EndPoint{Address: "34.1.1.3", Port: 22}, Private: existinginfrav1.EndPoint{Address: "34.1.1.3", Port: 22}},
+}
+
+var inventories = map[string]string{
+	"inventory.ini": `# Cluster hosts
+[masters]
+node1 ansible_host=34.1.1.1 ip=10.0.0.1
+
+[workers]
+node2 ansible_host=34.1.1.2 ansible_port=2222 private_ip="10.0.0.2"
+node3 ansible_host=34.1.1.3
+
+[all:vars]
+ansible_user=root
+
+[k8s:children]
+masters
+workers
+`,
+	"hosts": `node1 ansible_host=34.1.1.1 ip=10.0.0.1
+node2 ansible_host=34.1.1.2 ansible_port=2222 ip=10.0.0.2
+[workers]
+node2
+node3 ansible_host=34.1.1.3
+`,
+	"inventory.yaml": `all:
+  vars:
+    ansible_user: root
+  children:
+    masters:
+      hosts:
+        node1:
+          ansible_host: 34.1.1.1
+          ip: 10.0.0.1
+    workers:
+      hosts:
+        node2:
+          ansible_host: 34.1.1.2
+          ansible_port: 2222
+          ip: 10.0.0.2
+        node3:
+          ansible_host: 34.1.1.3
+    k8s:
+      children:
+        masters:
+        workers:
+`,
+	"hosts.csv": `name,public_address,public_port,private_address
+node1,34.1.1.1,,10.0.0.1
+node2, 34.1.1.2,2222,10.0.0.2
+# No private network.
+node3,34.1.1.3,,
+`,
+	"tf-output.json": `{
+  "names": {"sensitive": false, "type": ["list", "string"], "value": ["node1", "node2", "node3"]},
+  "private_ips": {"sensitive": false, "type": ["list", "string"], "value": ["10.0.0.1", "10.0.0.2", "34.1.1.3"]},
+  "public_ips": {"sensitive": false, "type": ["list", "string"], "value": ["34.1.1.1", "34.1.1.2", "34.1.1.3"]}
+}`,
+}
+
+func TestReadInventory(t *testing.T) {
+	dir, err := ioutil.TempDir("", "wksctl-inventory")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	for name, contents := range inventories {
+		path := filepath.Join(dir, name)
+		require.NoError(t, ioutil.WriteFile(path, []byte(contents), 0644))
+		hosts, err := machine.ReadInventory(path)
+		require.NoError(t, err, name)
+		expected := inventoryHosts
+		if name == "tf-output.json" {
+			// Ports aren't part of the output of Terraform.
+			expected = append([]machine.Host(nil), inventoryHosts...)
+			expected[1].Public.Port = 22
+		}
+		assert.Equal(t, expected, hosts, name)
+	}
+
+	for name, contents := range map[string]string{
+		"empty.ini":     "[masters]\n",
+		"pattern.ini":   "node[1:3] ansible_host=34.1.1.1\n",
+		"port.csv":      "public_address,public_port\n34.1.1.1,ssh\n",
+		"columns.csv":   "address\n34.1.1.1\n",
+		"lengths.json":  `{"public_ips": {"value": ["34.1.1.1"]}, "private_ips": {"value": []}}`,
+		"outputs.json":  `{"ips": {"value": ["34.1.1.1"]}}`,
+		"invalid.yaml":  "all:\n  hosts: [node1]\n",
+		"no-hosts.yaml": "all:\n  vars:\n    ansible_user: root\n",
+	} {
+		path := filepath.Join(dir, name)
+		require.NoError(t, ioutil.WriteFile(path, []byte(contents), 0644))
+		_, err := machine.ReadInventory(path)
+		assert.Error(t, err, name)
+	}
+}
+
+func TestGenerateMachines(t *testing.T) {
+	hosts := append(inventoryHosts, machine.Host{Name: "Node_4", Public: existinginfrav1.EndPoint{Address: "34.1.1.4", Port: 22}, Private: existinginfrav1.EndPoint{Address: "10.0.0.4", Port: 22}})
+	machines, bml, err := machine.GenerateMachines(hosts, 2, "example", "1.18.9")
+	require.NoError(t, err)
+	require.Len(t, machines, 4)
+	require.Len(t, bml, 4)
+	for i, m := range machines {
+		role := "worker"
+		if i < 2 {
+			role = "master"
+		}
+		if i < 3 {
+			assert.Equal(t, hosts[i].Name, m.Name)
+		} else {
+			assert.True(t, strings.HasPrefix(m.Name, "worker-"), m.Name)
+		}
+		assert.Equal(t, map[string]string{"set": role, clusterv1.ClusterLabelName: "example"}, m.Labels)
+		assert.Equal(t, "example", m.Spec.ClusterName)
+		assert.Equal(t, "1.18.9", *m.Spec.Version)
+		assert.Equal(t, "ExistingInfraMachine", m.Spec.InfrastructureRef.Kind)
+		assert.Equal(t, bml[i].Name, m.Spec.InfrastructureRef.Name)
+		assert.Equal(t, hosts[i].Public, bml[i].Spec.Public)
+		assert.Equal(t, hosts[i].Private, bml[i].Spec.Private)
+	}
+
+	// The machines are written as a valid manifest.
+	var buf strings.Builder
+	require.NoError(t, machine.WriteMachines(&buf, machines, bml))
+	parsed, parsedBML, err := capeimachine.Parse(ioutil.NopCloser(strings.NewReader(buf.String())))
+	require.NoError(t, err)
+	assert.Len(t, parsed, 4)
+	assert.Len(t, parsedBML, 4)
+	assert.Empty(t, capeimachine.Validate(parsed, parsedBML))
+
+	for _, masters := range []int{0, 5} {
+		_, _, err := machine.GenerateMachines(hosts, masters, "example", "1.18.9")
+		assert.Error(t, err)
+	}
+	_, _, err = machine.GenerateMachines(append(hosts, hosts[0]), 1, "example", "1.18.9")
+	assert.Error(t, err)
+}
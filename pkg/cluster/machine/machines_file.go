@@ -0,0 +1,224 @@
+package machine
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+	capeimachine "github.com/weaveworks/cluster-api-provider-existinginfra/pkg/cluster/machine"
+	yaml "gopkg.in/yaml.v3"
+	"k8s.io/apimachinery/pkg/util/validation"
+	sigsyaml "sigs.k8s.io/yaml"
+)
+
+// Machines are added to and removed from machines manifests as YAML documents,
+// leaving the other documents of the manifests, their comments and formatting
+// as they were.
+
+type machinesDocument struct {
+	// separator is the "---" line starting the document, if any.
+	separator string
+	text      string
+	object    struct {
+		Kind     string
+		Metadata struct {
+			Name string
+		}
+		Spec struct {
+			InfrastructureRef struct {
+				Name string
+			} `yaml:"infrastructureRef"`
+		}
+	}
+}
+
+type machinesFile struct {
+	path      string
+	mode      os.FileMode
+	documents []*machinesDocument
+}
+
+func isDocumentSeparator(line string) bool {
+	return strings.HasPrefix(line, "---") && (len(line) == 3 || strings.ContainsAny(line[3:4], " \t\r\n"))
+}
+
+func readMachinesFile(path string) (*machinesFile, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	contents, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	f := &machinesFile{path: path, mode: info.Mode()}
+	document := &machinesDocument{}
+	for _, line := range strings.SplitAfter(string(contents), "\n") {
+		if isDocumentSeparator(line) {
+			f.documents = append(f.documents, document)
+			document = &machinesDocument{separator: line}
+			continue
+		}
+		document.text += line
+	}
+	f.documents = append(f.documents, document)
+	for _, d := range f.documents {
+		if err := yaml.Unmarshal([]byte(d.text), &d.object); err != nil {
+			return nil, errors.Wrapf(err, "failed to parse %s", path)
+		}
+	}
+	return f, nil
+}
+
+func (f *machinesFile) bytes() []byte {
+	var b strings.Builder
+	for _, d := range f.documents {
+		b.WriteString(d.separator)
+		b.WriteString(d.text)
+	}
+	return []byte(b.String())
+}
+
+func (f *machinesFile) find(kind, name string) int {
+	for i, d := range f.documents {
+		if d.object.Kind == kind && d.object.Metadata.Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// write validates the machines of the file before writing it.
+func (f *machinesFile) write() error {
+	contents := f.bytes()
+	machines, bml, err := capeimachine.Parse(ioutil.NopCloser(strings.NewReader(string(contents))))
+	if err != nil {
+		return errors.Wrapf(err, "failed to parse the updated %s", f.path)
+	}
+	if errs := capeimachine.Validate(machines, bml); len(errs) > 0 {
+		return errors.Wrapf(errs.ToAggregate(), "the updated %s is invalid", f.path)
+	}
+	return ioutil.WriteFile(f.path, contents, f.mode)
+}
+
+// marshalObject returns the YAML of the object, without its empty status and
+// creation timestamp.
+func marshalObject(object interface{}) (string, error) {
+	data, err := json.Marshal(object)
+	if err != nil {
+		return "", err
+	}
+	var fields map[string]interface{}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return "", err
+	}
+	delete(fields, "status")
+	if metadata, ok := fields["metadata"].(map[string]interface{}); ok {
+		delete(metadata, "creationTimestamp")
+	}
+	text, err := sigsyaml.Marshal(fields)
+	return string(text), err
+}
+
+// AddMachine adds a Machine with the role, master or worker, and its
+// ExistingInfraMachine for the host, to the end of the machines manifest, in
+// the cluster and with the Kubernetes version of its other machines. The
+// machine is named after its role unless a name is provided. It returns the
+// name of the machine.
+func AddMachine(path, role, name string, host Host) (string, error) {
+	if role != "master" && role != "worker" {
+		return "", errors.Errorf("invalid role %q, machines are masters or workers", role)
+	}
+	f, err := readMachinesFile(path)
+	if err != nil {
+		return "", err
+	}
+	machines, bml, err := capeimachine.ParseManifest(path)
+	if err != nil {
+		return "", err
+	}
+	if len(machines) == 0 {
+		return "", errors.Errorf("no machines in %s to add a machine to the cluster of", path)
+	}
+
+	namesTaken := readNames(machines)
+	for _, eim := range bml {
+		namesTaken[eim.Name] = struct{}{}
+	}
+	if name == "" {
+		name = uniqueNameFrom(role+"-", namesTaken)
+	} else if errs := validation.IsDNS1123Subdomain(name); len(errs) > 0 {
+		return "", errors.Errorf("invalid name %q: %s", name, strings.Join(errs, ", "))
+	} else if f.find("Machine", name) >= 0 || f.find("ExistingInfraMachine", name+"-provider") >= 0 {
+		return "", errors.Errorf("machine %s already exists in %s", name, path)
+	}
+
+	var version string
+	if v := machines[0].Spec.Version; v != nil {
+		version = *v
+	}
+	machine, eim := newMachine(name, role, machines[0].Spec.ClusterName, version, host)
+	if last := f.documents[len(f.documents)-1]; last.text != "" && !strings.HasSuffix(last.text, "\n") {
+		last.text += "\n"
+	}
+	for _, object := range []interface{}{machine, eim} {
+		text, err := marshalObject(object)
+		if err != nil {
+			return "", err
+		}
+		f.documents = append(f.documents, &machinesDocument{separator: "---\n", text: text})
+	}
+	return name, f.write()
+}
+
+// RemoveMachine removes the Machine with the name, and its
+// ExistingInfraMachine, from the machines manifest.
+func RemoveMachine(path, name string) error {
+	f, err := readMachinesFile(path)
+	if err != nil {
+		return err
+	}
+	i := f.find("Machine", name)
+	if i < 0 {
+		return errors.Errorf("no machine %s in %s", name, path)
+	}
+	ref := f.documents[i].object.Spec.InfrastructureRef.Name
+	j := f.find("ExistingInfraMachine", ref)
+	if j < 0 {
+		return errors.Errorf("no ExistingInfraMachine %s for machine %s in %s", ref, name, path)
+	}
+
+	var header string
+	var documents []*machinesDocument
+	for k, d := range f.documents {
+		if k == i || k == j {
+			if k == 0 {
+				header = leadingComments(d.text)
+			}
+			continue
+		}
+		documents = append(documents, d)
+	}
+	// The comments heading the file are kept, heading the new first document
+	// as a comment-only document isn't a valid object.
+	if header != "" && len(documents) > 0 {
+		documents[0].text = header + documents[0].text
+		documents[0].separator = ""
+	}
+	f.documents = documents
+	return f.write()
+}
+
+// leadingComments returns the comment and blank lines the text starts with.
+func leadingComments(text string) string {
+	var comments strings.Builder
+	for _, line := range strings.SplitAfter(text, "\n") {
+		if trimmed := strings.TrimSpace(line); trimmed != "" && !strings.HasPrefix(trimmed, "#") {
+			break
+		}
+		comments.WriteString(line)
+	}
+	return comments.String()
+}
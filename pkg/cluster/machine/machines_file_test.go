@@ -0,0 +1,147 @@
+package machine_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	existinginfrav1 "github.com/weaveworks/cluster-api-provider-existinginfra/apis/cluster.weave.works/v1alpha3"
+	capeimachine "github.com/weaveworks/cluster-api-provider-existinginfra/pkg/cluster/machine"
+	"github.com/weaveworks/wksctl/pkg/cluster/machine"
+)
+
+const machinesHeader = `# Machines of the example cluster.
+`
+
+const masterDocuments = `apiVersion: cluster.x-k8s.io/v1alpha3
+kind: Machine
+metadata:
+  labels:
+    set: master
+  name: master-0
+spec:
+  clusterName: example
+  bootstrap: {}
+  version: 1.18.9
+  infrastructureRef:
+    apiVersion: cluster.weave.works/v1alpha3
+    kind: ExistingInfraMachine
+    name: master-0-provider
+---
+apiVersion: cluster.weave.works/v1alpha3
+kind: ExistingInfraMachine
+metadata:
+  name: master-0-provider
+spec:
+  private: {address: 172.17.8.101, port: 22}
+  public: {address: 127.0.0.1, port: 2222}
+`
+
+const workerDocuments = `---
+# The first worker.
+apiVersion: cluster.x-k8s.io/v1alpha3
+kind: Machine
+metadata:
+  labels:
+    set: worker
+  name: worker-0
+spec:
+  clusterName: example
+  bootstrap: {}
+  version: 1.18.9
+  infrastructureRef:
+    apiVersion: cluster.weave.works/v1alpha3
+    kind: ExistingInfraMachine
+    name: worker-0-provider
+---
+apiVersion: cluster.weave.works/v1alpha3
+kind: ExistingInfraMachine
+metadata:
+  name: worker-0-provider
+spec:
+  private:
+    address: 172.17.8.102
+    port: 22
+  public:
+    address: 127.0.0.1
+    port: 2223
+`
+
+func writeMachines(t *testing.T, contents string) (string, func()) {
+	dir, err := ioutil.TempDir("", "wksctl-machines")
+	require.NoError(t, err)
+	path := filepath.Join(dir, "machines.yaml")
+	require.NoError(t, ioutil.WriteFile(path, []byte(contents), 0644))
+	return path, func() { os.RemoveAll(dir) }
+}
+
+func assertFile(t *testing.T, path, expected string) {
+	contents, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, expected, string(contents))
+}
+
+func TestAddMachine(t *testing.T) {
+	original := machinesHeader + masterDocuments + workerDocuments
+	path, cleanup := writeMachines(t, original)
+	defer cleanup()
+
+	host, err := machine.NewHost("", "1.2.3.4", "", "10.0.0.4", "")
+	require.NoError(t, err)
+	name, err := machine.AddMachine(path, "worker", "", host)
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(name, "worker-"), name)
+	named, err := machine.AddMachine(path, "master", "master-1", host)
+	require.NoError(t, err)
+	assert.Equal(t, "master-1", named)
+
+	contents, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(string(contents), original), "the manifest is only appended to")
+	machines, bml, err := capeimachine.ParseManifest(path)
+	require.NoError(t, err)
+	assert.Empty(t, capeimachine.Validate(machines, bml))
+	require.Len(t, machines, 4)
+	for i, expected := range []struct{ name, role string }{{name, "worker"}, {"master-1", "master"}} {
+		m, eim := machines[2+i], bml[2+i]
+		assert.Equal(t, expected.name, m.Name)
+		assert.Equal(t, expected.role, m.Labels["set"])
+		assert.Equal(t, "example", m.Spec.ClusterName)
+		assert.Equal(t, "1.18.9", *m.Spec.Version)
+		assert.Equal(t, expected.name+"-provider", eim.Name)
+		assert.Equal(t, existinginfrav1.EndPoint{Address: "1.2.3.4", Port: 22}, eim.Spec.Public)
+		assert.Equal(t, existinginfrav1.EndPoint{Address: "10.0.0.4", Port: 22}, eim.Spec.Private)
+	}
+
+	for _, args := range []struct{ role, name string }{
+		{"worker", "worker-0"},
+		{"worker", "Worker_1"},
+		{"etcd", ""},
+	} {
+		_, err := machine.AddMachine(path, args.role, args.name, host)
+		assert.Error(t, err, args)
+	}
+	assertFile(t, path, string(contents))
+}
+
+func TestRemoveMachine(t *testing.T) {
+	path, cleanup := writeMachines(t, machinesHeader+masterDocuments+workerDocuments)
+	defer cleanup()
+
+	require.NoError(t, machine.RemoveMachine(path, "worker-0"))
+	assertFile(t, path, machinesHeader+masterDocuments)
+
+	// Clusters keep at least one master.
+	assert.Error(t, machine.RemoveMachine(path, "master-0"))
+	assert.Error(t, machine.RemoveMachine(path, "worker-0"))
+	assertFile(t, path, machinesHeader+masterDocuments)
+
+	// The comments heading the manifest are kept.
+	require.NoError(t, ioutil.WriteFile(path, []byte(machinesHeader+masterDocuments+strings.Replace(workerDocuments, "set: worker", "set: master", 1)), 0644))
+	require.NoError(t, machine.RemoveMachine(path, "master-0"))
+	assertFile(t, path, machinesHeader+strings.TrimPrefix(strings.Replace(workerDocuments, "set: worker", "set: master", 1), "---\n"))
+}
@@ -1,8 +1,12 @@
 package machine
 
 import (
+	"crypto/sha256"
+	"encoding/base32"
+	"fmt"
 	"io"
 	"os"
+	"strconv"
 	"strings"
 
 	"github.com/pkg/errors"
@@ -22,11 +26,31 @@ func GetMachinesManifest(path string) (string, error) {
 	return UpdateWithGeneratedNames(f)
 }
 
+// UsesGenerateName returns whether some of the provided machines are named
+// with Kubernetes "generateName".
+func UsesGenerateName(machines []*clusterv1.Machine, bml []*existinginfra1.ExistingInfraMachine) bool {
+	for _, m := range machines {
+		if m.ObjectMeta.GenerateName != "" {
+			return true
+		}
+	}
+	for _, m := range bml {
+		if m.ObjectMeta.GenerateName != "" {
+			return true
+		}
+	}
+	return false
+}
+
 // UpdateWithGeneratedNames generates names for machines, rather than using
 // Kubernetes "generateName". This is necessary as:
 // - one can only "kubectl create" manifests with "generateName" fields, not
 //   "kubectl apply" them,
 // - WKS needs to be as idempotent as possible.
+// ExistingInfraMachines with "generateName" are named the same way, and the
+// infrastructure reference of their machine is updated to match.
+// Names are derived from the public endpoint of the machines, rather than
+// random, for every call on the same manifest to generate the same names.
 // Note that if the customer updates the manifest with their own names, we'll
 // honor those.
 func UpdateWithGeneratedNames(r io.ReadCloser) (string, error) {
@@ -40,17 +64,33 @@ func UpdateWithGeneratedNames(r io.ReadCloser) (string, error) {
 	namesTaken := readNames(machines)
 	for i := range machines {
 		if machines[i].ObjectMeta.GenerateName != "" {
-			// TODO: update ExistingInfraMachine list here too
-			if len(bml) > i && bml[i].ObjectMeta.GenerateName != "" {
-				return "", errors.New("generateName not implemented for v1alpha3")
-			}
-			name := uniqueNameFrom(machines[i].ObjectMeta.GenerateName, namesTaken)
+			name := derivedNameFrom(machines[i].ObjectMeta.GenerateName, machineKey(bml, i), namesTaken)
 			machines[i].SetName(name)
 			// Blank generateName out, now that a name has been generated.
 			machines[i].SetGenerateName("")
 		}
 	}
 
+	// ExistingInfraMachines are paired with machines by their position in the
+	// manifest, so the infrastructure reference of the machine at the same
+	// position is updated with the generated name, unless it already refers
+	// to another ExistingInfraMachine.
+	eimNamesTaken := readEIMNames(bml)
+	for i := range bml {
+		if generateName := bml[i].ObjectMeta.GenerateName; generateName != "" {
+			if i >= len(machines) {
+				return "", errors.Errorf("no machine for ExistingInfraMachine %d with generateName %q", i, generateName)
+			}
+			if ref := machines[i].Spec.InfrastructureRef.Name; ref != "" && ref != generateName {
+				return "", errors.Errorf("machine %d refers to ExistingInfraMachine %q rather than to ExistingInfraMachine %d with generateName %q, at the same position", i, ref, i, generateName)
+			}
+			name := derivedNameFrom(generateName, machineKey(bml, i), eimNamesTaken)
+			bml[i].SetName(name)
+			bml[i].SetGenerateName("")
+			machines[i].Spec.InfrastructureRef.Name = name
+		}
+	}
+
 	var buf strings.Builder
 	err = WriteMachines(&buf, machines, bml)
 	return buf.String(), err
@@ -99,6 +139,39 @@ func readNames(machines []*clusterv1.Machine) map[string]struct{} {
 	return namesTaken
 }
 
+func readEIMNames(bml []*existinginfra1.ExistingInfraMachine) map[string]struct{} {
+	namesTaken := map[string]struct{}{}
+	for _, eim := range bml {
+		if eim.ObjectMeta.Name != "" {
+			namesTaken[eim.ObjectMeta.Name] = struct{}{}
+		}
+	}
+	return namesTaken
+}
+
+// machineKey returns what identifies the i-th machine of a manifest: the
+// public endpoint of its ExistingInfraMachine, or else its position.
+func machineKey(bml []*existinginfra1.ExistingInfraMachine, i int) string {
+	if i < len(bml) && bml[i].Spec.Public.Address != "" {
+		return fmt.Sprintf("%s:%d", bml[i].Spec.Public.Address, bml[i].Spec.Public.Port)
+	}
+	return strconv.Itoa(i)
+}
+
+// derivedNameFrom returns a name made of the prefix and of a suffix derived
+// from the key, in the format of uniqueNameFrom, which isn't taken yet.
+func derivedNameFrom(prefix, key string, namesTaken map[string]struct{}) string {
+	for i := 0; ; i++ {
+		sum := sha256.Sum256([]byte(fmt.Sprintf("%s%s/%d", prefix, key, i)))
+		suffix := strings.ToLower(base32.StdEncoding.EncodeToString(sum[:]))
+		name := prefix + suffix[:5] + "-" + suffix[5:10]
+		if _, taken := namesTaken[name]; !taken {
+			namesTaken[name] = struct{}{}
+			return name
+		}
+	}
+}
+
 func uniqueNameFrom(prefix string, namesTaken map[string]struct{}) string {
 	for {
 		suffix := strings.Join(randomStrings(5, 2), "-")
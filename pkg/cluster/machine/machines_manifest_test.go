@@ -9,6 +9,8 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	capeimachine "github.com/weaveworks/cluster-api-provider-existinginfra/pkg/cluster/machine"
 	"github.com/weaveworks/wksctl/pkg/cluster/machine"
 )
 
@@ -97,3 +99,127 @@ func TestUpdateWithGeneratedNamesWithCustomNameAndGenerateNameFieldsShouldOnlyCh
 	assert.NoError(t, err)
 	assert.Equal(t, updatedManifest, updatedManifest2, "processing the same manifest twice shouldn't modify it")
 }
+
+const manifestWithGenerateNameFieldsOnBothKinds = `
+apiVersion: cluster.x-k8s.io/v1alpha3
+kind: Machine
+metadata:
+  generateName: master-
+  labels:
+    set: master
+spec:
+  clusterName: example
+  infrastructureRef:
+    apiVersion: cluster.weave.works/v1alpha3
+    kind: ExistingInfraMachine
+---
+apiVersion: cluster.x-k8s.io/v1alpha3
+kind: Machine
+metadata:
+  name: node-0
+  labels:
+    set: worker
+spec:
+  clusterName: example
+  infrastructureRef:
+    apiVersion: cluster.weave.works/v1alpha3
+    kind: ExistingInfraMachine
+---
+apiVersion: cluster.weave.works/v1alpha3
+kind: ExistingInfraMachine
+metadata:
+  generateName: master-
+spec:
+  private: {address: 172.17.8.101, port: 22}
+  public: {address: 127.0.0.1, port: 2222}
+---
+apiVersion: cluster.weave.works/v1alpha3
+kind: ExistingInfraMachine
+metadata:
+  generateName: node-
+spec:
+  private: {address: 172.17.8.102, port: 22}
+  public: {address: 127.0.0.1, port: 2223}
+`
+
+func TestUpdateWithGeneratedNamesWithGenerateNameFieldsOnBothKindsShouldUpdateInfrastructureRefs(t *testing.T) {
+	r := ioutil.NopCloser(strings.NewReader(manifestWithGenerateNameFieldsOnBothKinds))
+	updatedManifest, err := machine.UpdateWithGeneratedNames(r)
+	assert.NoError(t, err)
+	assert.NotContains(t, updatedManifest, "generateName:")
+	machines, bml, err := capeimachine.Parse(ioutil.NopCloser(strings.NewReader(updatedManifest)))
+	require.NoError(t, err)
+	require.Len(t, machines, 2)
+	require.Len(t, bml, 2)
+	assert.Regexp(t, regexp.MustCompile(`^master-[0-9a-z]{5}-[0-9a-z]{5}$`), machines[0].Name)
+	assert.Equal(t, "node-0", machines[1].Name)
+	for i, eim := range bml {
+		assert.Regexp(t, regexp.MustCompile(`^(master|node)-[0-9a-z]{5}-[0-9a-z]{5}$`), eim.Name)
+		assert.Equal(t, eim.Name, machines[i].Spec.InfrastructureRef.Name)
+	}
+	assert.Empty(t, capeimachine.Validate(machines, bml))
+
+	// The names are derived from the machines, rather than random.
+	r = ioutil.NopCloser(strings.NewReader(manifestWithGenerateNameFieldsOnBothKinds))
+	regeneratedManifest, err := machine.UpdateWithGeneratedNames(r)
+	assert.NoError(t, err)
+	assert.Equal(t, updatedManifest, regeneratedManifest, "processing the same manifest again should generate the same names")
+
+	r = ioutil.NopCloser(strings.NewReader(updatedManifest))
+	updatedManifest2, err := machine.UpdateWithGeneratedNames(r)
+	assert.NoError(t, err)
+	assert.Equal(t, updatedManifest, updatedManifest2, "processing the same manifest twice shouldn't modify it")
+}
+
+func TestUpdateWithGeneratedNamesWithoutMachineForGenerateNameShouldFail(t *testing.T) {
+	manifest := manifestWithGenerateNameFieldsOnBothKinds[:strings.Index(manifestWithGenerateNameFieldsOnBothKinds, "---")] +
+		manifestWithGenerateNameFieldsOnBothKinds[strings.Index(manifestWithGenerateNameFieldsOnBothKinds, "---\napiVersion: cluster.weave.works"):]
+	_, err := machine.UpdateWithGeneratedNames(ioutil.NopCloser(strings.NewReader(manifest)))
+	assert.Error(t, err)
+}
+
+const manifestWithMachinesInAnotherOrder = `
+apiVersion: cluster.x-k8s.io/v1alpha3
+kind: Machine
+metadata:
+  name: node-0
+spec:
+  clusterName: example
+  infrastructureRef:
+    apiVersion: cluster.weave.works/v1alpha3
+    kind: ExistingInfraMachine
+    name: worker
+---
+apiVersion: cluster.x-k8s.io/v1alpha3
+kind: Machine
+metadata:
+  name: master-0
+spec:
+  clusterName: example
+  infrastructureRef:
+    apiVersion: cluster.weave.works/v1alpha3
+    kind: ExistingInfraMachine
+    name: master-
+---
+apiVersion: cluster.weave.works/v1alpha3
+kind: ExistingInfraMachine
+metadata:
+  generateName: master-
+spec:
+  private: {address: 172.17.8.101, port: 22}
+  public: {address: 127.0.0.1, port: 2222}
+---
+apiVersion: cluster.weave.works/v1alpha3
+kind: ExistingInfraMachine
+metadata:
+  name: worker
+spec:
+  private: {address: 172.17.8.102, port: 22}
+  public: {address: 127.0.0.1, port: 2223}
+`
+
+func TestUpdateWithGeneratedNamesWithMachinesInAnotherOrderShouldFail(t *testing.T) {
+	_, err := machine.UpdateWithGeneratedNames(ioutil.NopCloser(strings.NewReader(manifestWithMachinesInAnotherOrder)))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `machine 0 refers to ExistingInfraMachine "worker"`)
+}
@@ -0,0 +1,227 @@
+package config
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/ghodss/yaml"
+	"github.com/pkg/errors"
+	"github.com/spf13/pflag"
+	capeipath "github.com/weaveworks/cluster-api-provider-existinginfra/pkg/utilities/path"
+	yamlv3 "gopkg.in/yaml.v3"
+)
+
+// The configuration file of wksctl holds named contexts, each holding the
+// values of the flags locating a cluster, which commands use unless set on the
+// command line or by environment variables:
+//
+//   current-context: prod
+//   contexts:
+//     prod:
+//       git-url: git@github.com:example/prod-cluster.git
+//       git-deploy-key: ~/.ssh/prod-deploy-key
+//       ssh-key: ~/.ssh/prod-cluster-key
+
+// EnvPrefix prefixes the environment variables setting the flags of commands,
+// named after the flags, e.g. WKSCTL_SSH_KEY for --ssh-key.
+const EnvPrefix = "WKSCTL_"
+
+// EnvFlags are the flags which can be set by environment variables: those
+// locating a cluster. Flags skipping confirmations or checks, such as --yes or
+// --ssh-host-key-policy, can't, for a stray variable not to turn them off.
+var EnvFlags = []string{
+	"cluster",
+	"machines",
+	"manifests",
+	"ssh-key",
+	"namespace",
+	"git-url",
+	"git-branch",
+	"git-path",
+	"git-deploy-key",
+	"git-ref",
+	"git-user",
+	"git-token-file",
+	"git-depth",
+}
+
+// PathEnv is the environment variable overriding the path of the
+// configuration file.
+const PathEnv = EnvPrefix + "CONFIG"
+
+// Context holds the values of the flags locating a cluster.
+type Context struct {
+	Cluster      string `json:"cluster,omitempty"`
+	Machines     string `json:"machines,omitempty"`
+	SSHKey       string `json:"ssh-key,omitempty"`
+	GitURL       string `json:"git-url,omitempty"`
+	GitBranch    string `json:"git-branch,omitempty"`
+	GitPath      string `json:"git-path,omitempty"`
+	GitDeployKey string `json:"git-deploy-key,omitempty"`
+	Namespace    string `json:"namespace,omitempty"`
+}
+
+// flags returns the values of the context, by the name of the flag they set.
+// Paths to local files can start with ~.
+func (c *Context) flags() map[string]string {
+	return map[string]string{
+		"cluster":        capeipath.ExpandHome(c.Cluster),
+		"machines":       capeipath.ExpandHome(c.Machines),
+		"ssh-key":        capeipath.ExpandHome(c.SSHKey),
+		"git-url":        c.GitURL,
+		"git-branch":     c.GitBranch,
+		"git-path":       c.GitPath,
+		"git-deploy-key": capeipath.ExpandHome(c.GitDeployKey),
+		"namespace":      c.Namespace,
+	}
+}
+
+// Config is the configuration of wksctl.
+type Config struct {
+	CurrentContext string             `json:"current-context,omitempty"`
+	Contexts       map[string]Context `json:"contexts,omitempty"`
+}
+
+// Path returns the path of the configuration file, ~/.wksctl/config.yaml
+// unless overridden by PathEnv.
+func Path() string {
+	if path := os.Getenv(PathEnv); path != "" {
+		return capeipath.ExpandHome(path)
+	}
+	return capeipath.ExpandHome(filepath.Join("~", ".wksctl", "config.yaml"))
+}
+
+// Load reads the configuration file at the provided path, which may not exist.
+func Load(path string) (*Config, error) {
+	contents, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return &Config{}, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read wksctl configuration")
+	}
+	c := &Config{}
+	if err := yaml.Unmarshal(contents, c); err != nil {
+		return nil, errors.Wrapf(err, "failed to parse wksctl configuration %s", path)
+	}
+	return c, nil
+}
+
+// SetCurrentContext sets the current context in the configuration file at the
+// provided path, keeping the rest of the file as written.
+func SetCurrentContext(path, name string) error {
+	contents, err := ioutil.ReadFile(path)
+	if err != nil {
+		return errors.Wrap(err, "failed to read wksctl configuration")
+	}
+	var doc yamlv3.Node
+	if err := yamlv3.Unmarshal(contents, &doc); err != nil {
+		return errors.Wrapf(err, "failed to parse wksctl configuration %s", path)
+	}
+	if doc.Kind != yamlv3.DocumentNode || len(doc.Content) == 0 || doc.Content[0].Kind != yamlv3.MappingNode {
+		return errors.Errorf("wksctl configuration %s is not a map", path)
+	}
+	root := doc.Content[0]
+	value := &yamlv3.Node{Kind: yamlv3.ScalarNode, Value: name}
+	found := false
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		if root.Content[i].Value == "current-context" {
+			root.Content[i+1], found = value, true
+		}
+	}
+	if !found {
+		key := &yamlv3.Node{Kind: yamlv3.ScalarNode, Value: "current-context"}
+		root.Content = append([]*yamlv3.Node{key, value}, root.Content...)
+	}
+	var out bytes.Buffer
+	encoder := yamlv3.NewEncoder(&out)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(&doc); err != nil {
+		return err
+	}
+	return errors.Wrap(ioutil.WriteFile(path, out.Bytes(), 0644), "failed to write wksctl configuration")
+}
+
+// ContextNames returns the names of the contexts, sorted.
+func (c *Config) ContextNames() []string {
+	var names []string
+	for name := range c.Contexts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Context returns the context of the provided name.
+func (c *Config) Context(name string) (*Context, error) {
+	named, ok := c.Contexts[name]
+	if !ok {
+		return nil, errors.Errorf("no context %q in the wksctl configuration", name)
+	}
+	return &named, nil
+}
+
+// EnvName returns the name of the environment variable setting the provided
+// flag.
+func EnvName(flag string) string {
+	return EnvPrefix + strings.ToUpper(strings.Replace(flag, "-", "_", -1))
+}
+
+// BindEnv sets the EnvFlags not set on the command line to the value of their
+// environment variable, if set.
+func BindEnv(fs *pflag.FlagSet, lookupEnv func(string) (string, bool)) error {
+	for _, name := range EnvFlags {
+		f := fs.Lookup(name)
+		if f == nil || f.Changed {
+			continue
+		}
+		if value, ok := lookupEnv(EnvName(name)); ok {
+			if err := fs.Set(name, value); err != nil {
+				return errors.Wrapf(err, "invalid value of %s", EnvName(name))
+			}
+		}
+	}
+	return nil
+}
+
+// locationFlags are the flags giving the location of the manifests of a
+// cluster, and locationFields the flags of a context only making sense
+// together with its own location.
+var (
+	locationFlags  = []string{"cluster", "machines", "manifests", "git-url"}
+	locationFields = map[string]bool{
+		"cluster":        true,
+		"machines":       true,
+		"git-url":        true,
+		"git-branch":     true,
+		"git-path":       true,
+		"git-deploy-key": true,
+	}
+)
+
+// Apply sets the flags not set on the command line, or by environment
+// variables, to the values the context holds. The location of the manifests
+// is applied as a whole: the context's is ignored if any flag locating the
+// manifests is set.
+func (c *Context) Apply(fs *pflag.FlagSet) error {
+	locationSet := false
+	for _, name := range locationFlags {
+		if f := fs.Lookup(name); f != nil && f.Changed {
+			locationSet = true
+		}
+	}
+	for name, value := range c.flags() {
+		f := fs.Lookup(name)
+		if f == nil || f.Changed || value == "" || (locationSet && locationFields[name]) {
+			continue
+		}
+		if err := fs.Set(name, value); err != nil {
+			return errors.Wrapf(err, "invalid %s in the wksctl context", name)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,147 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/pflag"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const configYAML = `# Clusters of the team.
+contexts:
+  prod:
+    # Production, in Git.
+    git-url: git@github.com:example/prod-cluster.git
+    git-branch: main
+    ssh-key: ~/.ssh/prod-cluster-key
+  dev:
+    cluster: dev/cluster.yaml
+    machines: dev/machines.yaml
+`
+
+func writeConfig(t *testing.T, contents string) (string, func()) {
+	dir, err := ioutil.TempDir("", "wksctl-config")
+	require.NoError(t, err)
+	path := filepath.Join(dir, "config.yaml")
+	require.NoError(t, ioutil.WriteFile(path, []byte(contents), 0644))
+	return path, func() { os.RemoveAll(dir) }
+}
+
+func flags() *pflag.FlagSet {
+	fs := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	fs.String("cluster", "cluster.yaml", "")
+	fs.String("machines", "machines.yaml", "")
+	fs.String("ssh-key", "./cluster-key", "")
+	fs.String("manifests", "", "")
+	fs.String("git-url", "", "")
+	fs.String("git-path", ".", "")
+	fs.String("git-branch", "master", "")
+	fs.Int("git-depth", 0, "")
+	fs.Bool("yes", false, "")
+	fs.String("ssh-host-key-policy", "accept-new", "")
+	return fs
+}
+
+func TestLoad(t *testing.T) {
+	path, cleanup := writeConfig(t, configYAML)
+	defer cleanup()
+
+	c, err := Load(path)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"dev", "prod"}, c.ContextNames())
+	prod, err := c.Context("prod")
+	require.NoError(t, err)
+	assert.Equal(t, "main", prod.GitBranch)
+	_, err = c.Context("staging")
+	assert.Error(t, err)
+
+	c, err = Load(filepath.Join(filepath.Dir(path), "missing.yaml"))
+	require.NoError(t, err)
+	assert.Empty(t, c.Contexts)
+}
+
+func TestPrecedence(t *testing.T) {
+	home, err := os.UserHomeDir()
+	require.NoError(t, err)
+	fs := flags()
+	require.NoError(t, fs.Parse([]string{"--git-branch=dev"}))
+	env := map[string]string{"WKSCTL_GIT_URL": "git@github.com:example/other.git", "WKSCTL_GIT_DEPTH": "1", "WKSCTL_YES": "true", "WKSCTL_SSH_HOST_KEY_POLICY": "insecure"}
+	require.NoError(t, BindEnv(fs, func(name string) (string, bool) { v, ok := env[name]; return v, ok }))
+
+	c := &Context{GitURL: "git@github.com:example/prod-cluster.git", GitBranch: "main", SSHKey: "~/.ssh/prod-cluster-key", Namespace: "wks"}
+	require.NoError(t, c.Apply(fs))
+
+	value := func(name string) string { return fs.Lookup(name).Value.String() }
+	assert.Equal(t, "dev", value("git-branch"))
+	assert.Equal(t, "git@github.com:example/other.git", value("git-url"))
+	assert.Equal(t, "1", value("git-depth"))
+	// Only the flags locating a cluster are set by environment variables.
+	assert.Equal(t, "false", value("yes"))
+	assert.Equal(t, "accept-new", value("ssh-host-key-policy"))
+	assert.Equal(t, filepath.Join(home, ".ssh/prod-cluster-key"), value("ssh-key"))
+	assert.Equal(t, "cluster.yaml", value("cluster"))
+	assert.True(t, fs.Lookup("ssh-key").Changed)
+
+	env = map[string]string{"WKSCTL_GIT_DEPTH": "all"}
+	assert.Error(t, BindEnv(flags(), func(name string) (string, bool) { v, ok := env[name]; return v, ok }))
+}
+
+func TestLocationAppliedAsAWhole(t *testing.T) {
+	c := &Context{GitURL: "https://example.invalid/prod.git", GitBranch: "main", GitPath: "prod", SSHKey: "prod-cluster-key"}
+	noEnv := func(string) (string, bool) { return "", false }
+	tests := []struct {
+		name string
+		args []string
+		env  map[string]string
+	}{
+		{name: "manifests paths", args: []string{"--cluster=examples/footloose/cluster.yaml", "--machines=examples/footloose/machines.yaml"}},
+		{name: "manifests location", args: []string{"--manifests=examples/footloose"}},
+		{name: "environment", env: map[string]string{"WKSCTL_MANIFESTS": "examples/footloose"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fs := flags()
+			require.NoError(t, fs.Parse(tt.args))
+			lookupEnv := noEnv
+			if tt.env != nil {
+				lookupEnv = func(name string) (string, bool) { v, ok := tt.env[name]; return v, ok }
+			}
+			require.NoError(t, BindEnv(fs, lookupEnv))
+			require.NoError(t, c.Apply(fs))
+
+			for _, name := range []string{"git-url", "git-branch", "git-path"} {
+				assert.False(t, fs.Lookup(name).Changed, "--%s", name)
+			}
+			assert.Equal(t, "", fs.Lookup("git-url").Value.String())
+			assert.Equal(t, "prod-cluster-key", fs.Lookup("ssh-key").Value.String())
+		})
+	}
+
+	fs := flags()
+	require.NoError(t, c.Apply(fs))
+	assert.Equal(t, "https://example.invalid/prod.git", fs.Lookup("git-url").Value.String())
+	assert.Equal(t, "prod", fs.Lookup("git-path").Value.String())
+}
+
+func TestSetCurrentContext(t *testing.T) {
+	path, cleanup := writeConfig(t, configYAML)
+	defer cleanup()
+
+	require.NoError(t, SetCurrentContext(path, "prod"))
+	contents, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "current-context: prod\n"+configYAML, string(contents))
+
+	require.NoError(t, SetCurrentContext(path, "dev"))
+	c, err := Load(path)
+	require.NoError(t, err)
+	assert.Equal(t, "dev", c.CurrentContext)
+}
+
+func TestEnvName(t *testing.T) {
+	assert.Equal(t, "WKSCTL_GIT_DEPLOY_KEY", EnvName("git-deploy-key"))
+}
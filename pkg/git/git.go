@@ -8,6 +8,7 @@ import (
 
 	"github.com/pkg/errors"
 	log "github.com/sirupsen/logrus"
+	"github.com/weaveworks/wksctl/pkg/utilities/tarball"
 	giturls "github.com/whilp/git-urls"
 )
 
@@ -74,3 +75,22 @@ func IsGitURL(rawURL string) error {
 	}
 	return nil
 }
+
+// ExtractRevision writes the files under the current directory, as of the
+// provided revision of its repository, to dir.
+func ExtractRevision(revision, dir string) error {
+	cmd := exec.Command("git", "archive", "--format=tar", revision)
+	cmd.Stderr = os.Stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return errors.Wrap(err, "git archive")
+	}
+	if err := tarball.Extract(stdout, dir); err != nil {
+		_ = cmd.Wait()
+		return errors.Wrapf(err, "failed to extract revision %q", revision)
+	}
+	return errors.Wrapf(cmd.Wait(), "git archive %s", revision)
+}
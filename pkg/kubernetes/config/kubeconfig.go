@@ -70,8 +70,8 @@ func Sanitize(configStr string, params Params) (string, error) {
 }
 
 // GetRemoteKubeconfig retrieves Kubernetes configuration from a master node of the cluster
-func GetRemoteKubeconfig(ctx context.Context, sp *specs.Specs, sshKeyPath string, verbose, skipTLSVerify bool) (string, error) {
-	sshClient, err := ssh.NewClientForMachine(sp.MasterSpec, sp.ClusterSpec.User, sshKeyPath, verbose)
+func GetRemoteKubeconfig(ctx context.Context, sp *specs.Specs, sshOptions *ssh.Options, verbose, skipTLSVerify bool) (string, error) {
+	sshClient, err := sshOptions.NewClientForMachine(sp.Cluster, sp.MasterSpec, sp.ClusterSpec.User, verbose)
 	if err != nil {
 		return "", errors.Wrap(err, "failed to create SSH client: ")
 	}
@@ -1,4 +1,37 @@
 package kubernetes
 
+import (
+	"github.com/blang/semver"
+	"github.com/pkg/errors"
+)
+
 // DefaultVersionsRange is the default Kubernetes versions' range used by WKS to validate Kubernetes versions.
 const DefaultVersionsRange = ">=1.16.1 <=1.20.x"
+
+// ValidateUpgrade checks that a cluster running the current version of
+// Kubernetes can be upgraded to the target version: the target version has to
+// be in DefaultVersionsRange and, as kubeadm only upgrades clusters one minor
+// version at a time, be a later patch version of the current minor version,
+// or a version of the next minor version.
+func ValidateUpgrade(current, target string) error {
+	cv, err := semver.ParseTolerant(current)
+	if err != nil {
+		return errors.Wrapf(err, "invalid current version %q", current)
+	}
+	tv, err := semver.ParseTolerant(target)
+	if err != nil {
+		return errors.Wrapf(err, "invalid target version %q", target)
+	}
+	if !semver.MustParseRange(DefaultVersionsRange)(tv) {
+		return errors.Errorf("version %s doesn't match range: %s", target, DefaultVersionsRange)
+	}
+	switch {
+	case tv.EQ(cv):
+		return errors.Errorf("the cluster already runs Kubernetes %s", current)
+	case tv.LT(cv):
+		return errors.Errorf("cannot downgrade Kubernetes from %s to %s", current, target)
+	case tv.Major != cv.Major || tv.Minor > cv.Minor+1:
+		return errors.Errorf("cannot upgrade Kubernetes from %s to %s: upgrades have to go through every minor version, upgrade to %d.%d first", current, target, cv.Major, cv.Minor+1)
+	}
+	return nil
+}
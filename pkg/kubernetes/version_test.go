@@ -0,0 +1,31 @@
+package kubernetes
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateUpgrade(t *testing.T) {
+	tests := []struct {
+		current, target string
+		err             string
+	}{
+		{current: "1.18.15", target: "1.18.16"},
+		{current: "1.18.15", target: "1.19.7"},
+		{current: "v1.19.7", target: "v1.20.2"},
+		{current: "1.18.15", target: "1.18.15", err: "the cluster already runs Kubernetes 1.18.15"},
+		{current: "1.18.15", target: "1.17.13", err: "cannot downgrade Kubernetes from 1.18.15 to 1.17.13"},
+		{current: "1.18.15", target: "1.20.2", err: "upgrade to 1.19 first"},
+		{current: "1.20.2", target: "1.21.0", err: "doesn't match range"},
+		{current: "1.18.15", target: "latest", err: `invalid target version "latest"`},
+	}
+	for _, test := range tests {
+		err := ValidateUpgrade(test.current, test.target)
+		if test.err == "" {
+			assert.NoError(t, err, "%s -> %s", test.current, test.target)
+		} else if assert.Error(t, err, "%s -> %s", test.current, test.target) {
+			assert.Contains(t, err.Error(), test.err)
+		}
+	}
+}
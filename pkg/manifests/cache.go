@@ -0,0 +1,192 @@
+package manifests
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	capeipath "github.com/weaveworks/cluster-api-provider-existinginfra/pkg/utilities/path"
+	"gopkg.in/src-d/go-billy.v4/osfs"
+	gogit "gopkg.in/src-d/go-git.v4"
+	"gopkg.in/src-d/go-git.v4/config"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/cache"
+	"gopkg.in/src-d/go-git.v4/storage/filesystem"
+)
+
+// The clones of repositories are cached across commands: the objects of each
+// repository are kept in a bare repository, which is updated by fetching from
+// the remote and moving its branches to the fetched ones, and the commit is then checked
+// out into the temporary worktree of the command. Concurrent commands wait for
+// each other while updating the cache and checking out.
+
+// cacheDirectory returns the directory holding the cached repositories,
+// replaced by tests.
+var cacheDirectory = func() string {
+	return capeipath.ExpandHome(filepath.Join("~", ".wksctl", "cache", "git"))
+}
+
+const remoteName = "origin"
+
+// cachePath returns the path of the bare repository caching the repository at
+// the provided URL.
+func cachePath(url string) string {
+	sum := sha256.Sum256([]byte(url))
+	return filepath.Join(cacheDirectory(), hex.EncodeToString(sum[:]))
+}
+
+// lockCache takes the lock of the cache of the repository at the provided URL,
+// until unlock is called.
+func lockCache(url string) (unlock func(), err error) {
+	path := cachePath(url)
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, errors.Wrap(err, "failed to create Git cache directory")
+	}
+	f, err := os.OpenFile(path+".lock", os.O_CREATE|os.O_RDWR, 0600)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to open Git cache lock")
+	}
+	locked, err := tryLockFile(f)
+	if err == nil && !locked {
+		log.Infof("Waiting for another wksctl to release the cache of %s", url)
+		err = lockFile(f)
+	}
+	if err != nil {
+		f.Close()
+		return nil, errors.Wrap(err, "failed to lock Git cache")
+	}
+	return func() {
+		unlockFile(f)
+		f.Close()
+	}, nil
+}
+
+// checkoutCached updates the cache of the repository, and checks the commit
+// selected by the options out into the worktree. The cache must be locked.
+func checkoutCached(worktreePath string, o GitOptions, co *gogit.CloneOptions) (*gogit.Repository, error) {
+	storage := filesystem.NewStorage(osfs.New(cachePath(o.URL)), cache.NewObjectLRUDefault())
+	repo, err := gogit.Open(storage, osfs.New(worktreePath))
+	if err == gogit.ErrRepositoryNotExists {
+		log.Infof("Caching %s", o.URL)
+		repo, err = initCache(storage, worktreePath, o.URL)
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to open the cache of %s", o.URL)
+	}
+
+	fetch := &gogit.FetchOptions{
+		RemoteName: remoteName,
+		RefSpecs:   []config.RefSpec{config.RefSpec("+refs/heads/*:refs/remotes/" + remoteName + "/*")},
+		Depth:      co.Depth,
+		Auth:       co.Auth,
+		Tags:       gogit.AllTags,
+	}
+	if strings.HasPrefix(o.Ref, "refs/") && !strings.HasPrefix(o.Ref, "refs/heads/") && !strings.HasPrefix(o.Ref, "refs/tags/") {
+		fetch.RefSpecs = append(fetch.RefSpecs, config.RefSpec("+"+o.Ref+":"+o.Ref))
+	}
+	if err := repo.Fetch(fetch); err != nil && err != gogit.NoErrAlreadyUpToDate {
+		return nil, errors.Wrapf(err, "failed to fetch repository: %s", o.URL)
+	}
+	if err := updateBranches(repo); err != nil {
+		return nil, err
+	}
+
+	hash, err := cachedCommit(repo, o)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkout(repo, hash); err != nil {
+		return nil, errors.Wrapf(err, "failed to check out %s", hash)
+	}
+	return repo, nil
+}
+
+func initCache(storage *filesystem.Storage, worktreePath, url string) (*gogit.Repository, error) {
+	repo, err := gogit.Init(storage, nil)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := repo.CreateRemote(&config.RemoteConfig{Name: remoteName, URLs: []string{url}}); err != nil {
+		return nil, err
+	}
+	// The cache is bare, each command checks out into its own worktree.
+	return gogit.Open(storage, osfs.New(worktreePath))
+}
+
+// updateBranches moves the branches of the cache to the ones just fetched,
+// resetting those rewritten on the remote, the way a new clone would have them.
+func updateBranches(repo *gogit.Repository) error {
+	refs, err := repo.References()
+	if err != nil {
+		return err
+	}
+	defer refs.Close()
+	prefix := plumbing.NewRemoteReferenceName(remoteName, "").String()
+	return refs.ForEach(func(remote *plumbing.Reference) error {
+		if !strings.HasPrefix(remote.Name().String(), prefix) || remote.Type() != plumbing.HashReference {
+			return nil
+		}
+		branch := plumbing.NewBranchReferenceName(strings.TrimPrefix(remote.Name().String(), prefix))
+		local, err := repo.Reference(branch, false)
+		switch {
+		case err == plumbing.ErrReferenceNotFound:
+		case err != nil:
+			return err
+		case local.Hash() == remote.Hash():
+			return nil
+		case !isAncestor(repo, local.Hash(), remote.Hash()):
+			log.Infof("Branch %s was rewritten, resetting its cache", branch.Short())
+		}
+		return repo.Storer.SetReference(plumbing.NewHashReference(branch, remote.Hash()))
+	})
+}
+
+func isAncestor(repo *gogit.Repository, ancestor, descendant plumbing.Hash) bool {
+	a, err := repo.CommitObject(ancestor)
+	if err != nil {
+		return false
+	}
+	d, err := repo.CommitObject(descendant)
+	if err != nil {
+		return false
+	}
+	ok, err := a.IsAncestor(d)
+	return err == nil && ok
+}
+
+// cachedCommit returns the commit of the cache the options select.
+func cachedCommit(repo *gogit.Repository, o GitOptions) (plumbing.Hash, error) {
+	var name plumbing.ReferenceName
+	switch {
+	case isCommit(o.Ref):
+		hash := plumbing.NewHash(o.Ref)
+		if _, err := repo.CommitObject(hash); err != nil {
+			return plumbing.ZeroHash, errors.Wrapf(err, "commit %s not found", o.Ref)
+		}
+		return hash, nil
+	case strings.HasPrefix(o.Ref, "refs/"):
+		name = plumbing.ReferenceName(o.Ref)
+	case o.Ref != "":
+		name = plumbing.NewTagReferenceName(o.Ref)
+	case o.Branch != "":
+		name = plumbing.NewBranchReferenceName(o.Branch)
+	default:
+		name = plumbing.Master
+	}
+	ref, err := repo.Reference(name, true)
+	if err != nil {
+		return plumbing.ZeroHash, errors.Wrapf(err, "reference %s not found", name)
+	}
+	if tag, err := repo.TagObject(ref.Hash()); err == nil {
+		commit, err := tag.Commit()
+		if err != nil {
+			return plumbing.ZeroHash, errors.Wrapf(err, "tag %s", name.Short())
+		}
+		return commit.Hash, nil
+	}
+	return ref.Hash(), nil
+}
@@ -0,0 +1,28 @@
+// +build !windows
+
+package manifests
+
+import (
+	"os"
+	"syscall"
+)
+
+// tryLockFile takes an exclusive lock of the file, and returns whether it did
+// without waiting for another process to release it.
+func tryLockFile(f *os.File) (bool, error) {
+	err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+	if err == syscall.EWOULDBLOCK {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// lockFile takes an exclusive lock of the file, waiting for other processes to
+// release it.
+func lockFile(f *os.File) error {
+	return syscall.Flock(int(f.Fd()), syscall.LOCK_EX)
+}
+
+func unlockFile(f *os.File) error {
+	return syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+}
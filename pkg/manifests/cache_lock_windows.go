@@ -0,0 +1,34 @@
+package manifests
+
+import (
+	"os"
+
+	"golang.org/x/sys/windows"
+)
+
+// The whole file is locked, as far as its size can go.
+const lockedBytes = ^uint32(0)
+
+// tryLockFile takes an exclusive lock of the file, and returns whether it did
+// without waiting for another process to release it.
+func tryLockFile(f *os.File) (bool, error) {
+	err := lock(f, windows.LOCKFILE_FAIL_IMMEDIATELY)
+	if err == windows.ERROR_LOCK_VIOLATION {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// lockFile takes an exclusive lock of the file, waiting for other processes to
+// release it.
+func lockFile(f *os.File) error {
+	return lock(f, 0)
+}
+
+func lock(f *os.File, flags uint32) error {
+	return windows.LockFileEx(windows.Handle(f.Fd()), windows.LOCKFILE_EXCLUSIVE_LOCK|flags, 0, lockedBytes, lockedBytes, new(windows.Overlapped))
+}
+
+func unlockFile(f *os.File) error {
+	return windows.UnlockFileEx(windows.Handle(f.Fd()), 0, lockedBytes, lockedBytes, new(windows.Overlapped))
+}
@@ -0,0 +1,84 @@
+package manifests
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	gogit "gopkg.in/src-d/go-git.v4"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+)
+
+// tempCache caches repositories in a temporary directory, until the returned
+// function is called.
+func tempCache(t *testing.T) func() {
+	dir, err := ioutil.TempDir("", "wksctl-git-cache")
+	require.NoError(t, err)
+	previous := cacheDirectory
+	cacheDirectory = func() string { return dir }
+	return func() {
+		cacheDirectory = previous
+		os.RemoveAll(dir)
+	}
+}
+
+func TestCache(t *testing.T) {
+	defer tempCache(t)()
+	dir, err := ioutil.TempDir("", "wksctl-manifests-repo")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	repo, err := gogit.PlainInit(dir, false)
+	require.NoError(t, err)
+	worktree, err := repo.Worktree()
+	require.NoError(t, err)
+	commit := func(name, contents string, parents ...plumbing.Hash) plumbing.Hash {
+		require.NoError(t, ioutil.WriteFile(filepath.Join(dir, name), []byte(contents), 0644))
+		_, err := worktree.Add(name)
+		require.NoError(t, err)
+		hash, err := worktree.Commit(contents, &gogit.CommitOptions{Author: &object.Signature{Name: "test", Email: "test@example.com", When: time.Now()}, Parents: parents})
+		require.NoError(t, err)
+		return hash
+	}
+	assertClone := func(expected string) {
+		r, err := CloneRepo(GitOptions{URL: dir, Branch: "master"})
+		require.NoError(t, err)
+		defer r.Close()
+		for name, contents := range map[string]string{"cluster.yaml": expected, "machines.yaml": "machines"} {
+			actual, err := ioutil.ReadFile(filepath.Join(r.worktreePath, name))
+			require.NoError(t, err)
+			assert.Equal(t, contents, string(actual))
+		}
+	}
+
+	commit("machines.yaml", "machines")
+	first := commit("cluster.yaml", "v1")
+	assertClone("v1")
+
+	commit("cluster.yaml", "v2")
+	assertClone("v2")
+
+	// Rewrite the history of the branch.
+	rewritten := commit("cluster.yaml", "v2-rewritten", first)
+	require.NoError(t, repo.Storer.SetReference(plumbing.NewHashReference(plumbing.Master, rewritten)))
+	assertClone("v2-rewritten")
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assertClone("v2-rewritten")
+		}()
+	}
+	wg.Wait()
+
+	entries, err := ioutil.ReadDir(cacheDirectory())
+	require.NoError(t, err)
+	assert.Len(t, entries, 2, "the repository and its lock")
+}
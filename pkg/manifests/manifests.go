@@ -1,14 +1,18 @@
 package manifests
 
 import (
+	"encoding/hex"
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/pkg/errors"
+	"github.com/spf13/pflag"
 	xcryptossh "golang.org/x/crypto/ssh"
 	gogit "gopkg.in/src-d/go-git.v4"
 	"gopkg.in/src-d/go-git.v4/plumbing"
+	githttp "gopkg.in/src-d/go-git.v4/plumbing/transport/http"
 	gogitssh "gopkg.in/src-d/go-git.v4/plumbing/transport/ssh"
 )
 
@@ -23,27 +27,84 @@ func (r *ClusterAPIRepo) Close() error {
 	return os.RemoveAll(r.worktreePath)
 }
 
+// directory is the directory of the repository holding the manifests.
+func (r *ClusterAPIRepo) directory() *Directory {
+	return &Directory{Path: filepath.Join(r.worktreePath, r.subdir)}
+}
+
 func (r *ClusterAPIRepo) ClusterManifestPath() (string, error) {
-	path := filepath.Join(r.worktreePath, r.subdir, "cluster.yaml")
-	if _, err := os.Stat(path); err != nil {
-		return "", errors.Wrap(err, "cluster manifest not readable")
-	}
-	return path, nil
+	return r.directory().ClusterManifestPath()
 }
 
 func (r *ClusterAPIRepo) MachinesManifestPath() (string, error) {
-	candidates := []string{"machine.yaml", "machines.yaml"}
+	return r.directory().MachinesManifestPath()
+}
 
-	for _, c := range candidates {
-		fqCand := filepath.Join(r.worktreePath, r.subdir, c)
-		if _, err := os.Stat(fqCand); err == nil {
-			return fqCand, nil
-		}
-	}
-	return "", errors.New("machines manifest not found")
+func (r *ClusterAPIRepo) ConfigDirectory() (string, error) {
+	return r.directory().ConfigDirectory()
+}
+
+// GitTokenEnv is the environment variable holding the password or access
+// token authenticating over HTTPS, unless read from a file.
+const GitTokenEnv = "WKSCTL_GIT_TOKEN"
+
+// defaultGitUser is the user Git hosts expect.
+const defaultGitUser = "git"
+
+// GitOptions select the repository cloned, the revision checked out, and how
+// to authenticate.
+type GitOptions struct {
+	URL string
+	// Branch is checked out, unless Ref is set.
+	Branch string
+	// Ref is a tag, a full reference name (refs/...), or a full commit SHA.
+	Ref string
+	// Path is the directory of the repository holding the manifests.
+	Path          string
+	DeployKeyPath string
+	// User authenticates by SSH, or over HTTPS with the token. Defaults to
+	// git.
+	User string
+	// TokenFile holds the password or access token authenticating over
+	// HTTPS, which is otherwise read from GitTokenEnv.
+	TokenFile string
+	// Depth limits the history cloned to that many commits, unless 0.
+	Depth int
+	// NoCache clones the repository into a temporary directory, rather than
+	// updating its cache in ~/.wksctl/cache/git.
+	NoCache bool
+	// RequireSigned refuses the clone unless the commit checked out, or the
+	// tag it is checked out by, is signed by one of the keys of
+	// TrustedKeysPath: armored PGP public keys and SSH public keys, in the
+	// authorized_keys format.
+	RequireSigned   bool
+	TrustedKeysPath string
+}
+
+// AddGitCloneFlags registers the flags shaping how the repository is cloned
+// and verified, which commands register next to their --git-url flag.
+func AddGitCloneFlags(fs *pflag.FlagSet, o *GitOptions) {
+	fs.StringVar(&o.Ref, "git-ref", "", "Tag, or full commit SHA, of the Git repo to read the manifests from, instead of the head of --git-branch")
+	fs.StringVar(&o.User, "git-user", "", "User authenticating to the Git repo by SSH, or over HTTPS with the token (defaults to git)")
+	fs.StringVar(&o.TokenFile, "git-token-file", "", "Path to a file holding the password or access token authenticating to the Git repo over HTTPS (defaults to the "+GitTokenEnv+" environment variable)")
+	fs.IntVar(&o.Depth, "git-depth", 0, "Number of commits of history to clone from the Git repo (all if 0)")
+	fs.BoolVar(&o.NoCache, "no-git-cache", false, "Clone the whole Git repo, rather than updating its cache in ~/.wksctl/cache/git")
+	fs.BoolVar(&o.RequireSigned, "require-signed-commit", false, "Refuse to proceed unless the commit cloned from the Git repo, or the tag of --git-ref, is signed by one of the keys of --trusted-keys")
+	fs.StringVar(&o.TrustedKeysPath, "trusted-keys", "", "Path to a file holding the armored PGP public keys, and the SSH public keys in the authorized_keys format, trusted to sign the commits of the Git repo")
 }
 
 func CloneClusterAPIRepo(url, branch, keyPath, subdir string) (*ClusterAPIRepo, error) {
+	return CloneRepo(GitOptions{URL: url, Branch: branch, DeployKeyPath: keyPath, Path: subdir})
+}
+
+// CloneRepo checks the repository holding the manifests out, for the duration
+// of the command, from its cache updated with the remote, or from a clone if
+// NoCache is set.
+func CloneRepo(o GitOptions) (*ClusterAPIRepo, error) {
+	if o.RequireSigned && o.TrustedKeysPath == "" {
+		return nil, errors.New("--require-signed-commit requires --trusted-keys")
+	}
+
 	var worktreePath string
 	var err error
 
@@ -57,45 +118,143 @@ func CloneClusterAPIRepo(url, branch, keyPath, subdir string) (*ClusterAPIRepo,
 
 	r := ClusterAPIRepo{
 		worktreePath: worktreePath,
-		subdir:       subdir,
+		subdir:       o.Path,
 	}
 
-	opt, err := cloneOptions(url, keyPath, branch)
+	opt, err := cloneOptions(o)
 	if err != nil {
 		r.Close()
 		return nil, errors.Wrap(err, "cloneOptions")
 	}
 
-	if _, err := gogit.PlainClone(r.worktreePath, false, &opt); err != nil {
+	var repo *gogit.Repository
+	if o.NoCache {
+		repo, err = clone(r.worktreePath, o, &opt)
+	} else {
+		var unlock func()
+		if unlock, err = lockCache(o.URL); err == nil {
+			// The cache stays locked until the signatures are verified.
+			defer unlock()
+			repo, err = checkoutCached(r.worktreePath, o, &opt)
+		}
+	}
+	if err != nil {
 		r.Close()
-		return nil, errors.Wrapf(err, "failed to clone repository: %s", url)
+		return nil, err
+	}
+	if o.RequireSigned {
+		if err := verifySignature(repo, o.Ref, o.TrustedKeysPath); err != nil {
+			r.Close()
+			return nil, errors.Wrapf(err, "refusing to use repository %s", o.URL)
+		}
 	}
 
 	return &r, nil
 }
 
-func cloneOptions(url, deployKeyPath, branch string) (gogit.CloneOptions, error) {
+func clone(worktreePath string, o GitOptions, co *gogit.CloneOptions) (*gogit.Repository, error) {
+	repo, err := gogit.PlainClone(worktreePath, false, co)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to clone repository: %s", o.URL)
+	}
+	if isCommit(o.Ref) {
+		if err := checkout(repo, plumbing.NewHash(o.Ref)); err != nil {
+			return nil, errors.Wrapf(err, "failed to check out commit %s", o.Ref)
+		}
+	}
+	return repo, nil
+}
+
+// isCommit returns whether the ref is a full commit SHA, rather than a name.
+func isCommit(ref string) bool {
+	if len(ref) != 40 {
+		return false
+	}
+	_, err := hex.DecodeString(ref)
+	return err == nil
+}
+
+// checkout checks the commit out, overwriting the worktree, which the index
+// of cached repositories doesn't describe.
+func checkout(repo *gogit.Repository, hash plumbing.Hash) error {
+	worktree, err := repo.Worktree()
+	if err != nil {
+		return err
+	}
+	return worktree.Checkout(&gogit.CheckoutOptions{Hash: hash, Force: true})
+}
+
+func cloneOptions(o GitOptions) (gogit.CloneOptions, error) {
 	co := gogit.CloneOptions{
-		URL: url,
+		URL:   o.URL,
+		Depth: o.Depth,
 	}
-	if branch != "" {
+	switch {
+	case isCommit(o.Ref):
+		// Commits can't be fetched on their own, they are checked out of
+		// the clone of all branches.
+		if o.Depth != 0 {
+			return co, errors.New("a commit cannot be checked out of a shallow clone, clone by branch or tag to limit its depth")
+		}
+	case strings.HasPrefix(o.Ref, "refs/"):
+		co.SingleBranch = true
+		co.ReferenceName = plumbing.ReferenceName(o.Ref)
+	case o.Ref != "":
+		co.SingleBranch = true
+		co.ReferenceName = plumbing.NewTagReferenceName(o.Ref)
+	case o.Branch != "":
 		co.SingleBranch = true
-		co.ReferenceName = plumbing.NewBranchReferenceName(branch)
+		co.ReferenceName = plumbing.NewBranchReferenceName(o.Branch)
 	}
-	if deployKeyPath == "" {
+	user := o.User
+	if user == "" {
+		user = defaultGitUser
+	}
+
+	if strings.HasPrefix(o.URL, "https://") || strings.HasPrefix(o.URL, "http://") {
+		token, err := gitToken(o.TokenFile)
+		if err != nil || token == "" {
+			return co, err
+		}
+		co.Auth = &githttp.BasicAuth{Username: user, Password: token}
+		return co, nil
+	}
+
+	if o.DeployKeyPath == "" {
+		if o.User != "" {
+			// Authenticate as the user with the keys of the SSH agent.
+			auth, err := gogitssh.NewSSHAgentAuth(o.User)
+			if err != nil {
+				return co, errors.Wrap(err, "failed to authenticate with the SSH agent")
+			}
+			co.Auth = auth
+		}
 		return co, nil
 	}
-	pem, err := ioutil.ReadFile(deployKeyPath)
+	pem, err := ioutil.ReadFile(o.DeployKeyPath)
 	if err != nil {
-		return co, errors.Wrapf(err, "failed to read deploy key: %s", deployKeyPath)
+		return co, errors.Wrapf(err, "failed to read deploy key: %s", o.DeployKeyPath)
 	}
 	signer, err := xcryptossh.ParsePrivateKey(pem)
 	if err != nil {
 		return co, errors.Wrapf(err, "failed to parse private key")
 	}
 
-	aith := &gogitssh.PublicKeys{User: "git", Signer: signer}
+	aith := &gogitssh.PublicKeys{User: user, Signer: signer}
 	co.Auth = aith
 
 	return co, nil
 }
+
+// gitToken returns the password or access token authenticating over HTTPS,
+// read from the provided file, or from GitTokenEnv.
+func gitToken(tokenFile string) (string, error) {
+	if tokenFile == "" {
+		return os.Getenv(GitTokenEnv), nil
+	}
+	token, err := ioutil.ReadFile(tokenFile)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to read Git token: %s", tokenFile)
+	}
+	return strings.TrimSpace(string(token)), nil
+}
@@ -8,17 +8,19 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
-
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	gogit "gopkg.in/src-d/go-git.v4"
 	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+	githttp "gopkg.in/src-d/go-git.v4/plumbing/transport/http"
 )
 
 func TestNoDeployKey(t *testing.T) {
-	co, err := cloneOptions("foo", "", "")
+	co, err := cloneOptions(GitOptions{URL: "foo"})
 	assert.NoError(t, err)
 	assert.Equal(t, gogit.CloneOptions{URL: "foo"}, co)
 
@@ -37,13 +39,13 @@ func TestSSHDeployKey(t *testing.T) {
 	_, err = f.Write(keyPem)
 	assert.NoError(t, f.Close())
 	assert.NoError(t, err)
-	co, err := cloneOptions("url", f.Name(), "")
+	co, err := cloneOptions(GitOptions{URL: "url", DeployKeyPath: f.Name()})
 	assert.NoError(t, err)
 	assert.NotNil(t, co.Auth)
 }
 
 func TestBranchClone(t *testing.T) {
-	co, err := cloneOptions("foo", "", "develop")
+	co, err := cloneOptions(GitOptions{URL: "foo", Branch: "develop"})
 	assert.NoError(t, err)
 	assert.Equal(t, gogit.CloneOptions{URL: "foo", SingleBranch: true, ReferenceName: plumbing.NewBranchReferenceName("develop")}, co)
 }
@@ -86,3 +88,78 @@ func TestMachinesManifestPath(t *testing.T) {
 		})
 	}
 }
+
+func TestRefClone(t *testing.T) {
+	co, err := cloneOptions(GitOptions{URL: "foo", Branch: "master", Ref: "v1.2.0", Depth: 1})
+	assert.NoError(t, err)
+	assert.Equal(t, gogit.CloneOptions{URL: "foo", SingleBranch: true, ReferenceName: plumbing.NewTagReferenceName("v1.2.0"), Depth: 1}, co)
+
+	co, err = cloneOptions(GitOptions{URL: "foo", Ref: "refs/heads/release"})
+	assert.NoError(t, err)
+	assert.Equal(t, gogit.CloneOptions{URL: "foo", SingleBranch: true, ReferenceName: plumbing.ReferenceName("refs/heads/release")}, co)
+
+	const sha = "0123456789abcdef0123456789abcdef01234567"
+	co, err = cloneOptions(GitOptions{URL: "foo", Branch: "master", Ref: sha})
+	assert.NoError(t, err)
+	assert.Equal(t, gogit.CloneOptions{URL: "foo"}, co)
+	_, err = cloneOptions(GitOptions{URL: "foo", Ref: sha, Depth: 1})
+	assert.Error(t, err)
+}
+
+func TestHTTPSToken(t *testing.T) {
+	defer os.Unsetenv(GitTokenEnv)
+	os.Setenv(GitTokenEnv, "from-env")
+	co, err := cloneOptions(GitOptions{URL: "https://example.com/cluster.git"})
+	assert.NoError(t, err)
+	assert.Equal(t, &githttp.BasicAuth{Username: "git", Password: "from-env"}, co.Auth)
+
+	f, err := ioutil.TempFile("", "")
+	require.NoError(t, err)
+	defer os.Remove(f.Name())
+	_, err = f.WriteString("from-file\n")
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+	co, err = cloneOptions(GitOptions{URL: "https://example.com/cluster.git", User: "ci", TokenFile: f.Name()})
+	assert.NoError(t, err)
+	assert.Equal(t, &githttp.BasicAuth{Username: "ci", Password: "from-file"}, co.Auth)
+
+	// Tokens are only sent over HTTPS.
+	co, err = cloneOptions(GitOptions{URL: "git@example.com:cluster.git"})
+	assert.NoError(t, err)
+	assert.Nil(t, co.Auth)
+}
+
+func TestCloneRef(t *testing.T) {
+	defer tempCache(t)()
+	dir, err := ioutil.TempDir("", "wksctl-manifests-repo")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	repo, err := gogit.PlainInit(dir, false)
+	require.NoError(t, err)
+	worktree, err := repo.Worktree()
+	require.NoError(t, err)
+	commit := func(contents string) plumbing.Hash {
+		require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "cluster.yaml"), []byte(contents), 0644))
+		_, err := worktree.Add("cluster.yaml")
+		require.NoError(t, err)
+		hash, err := worktree.Commit(contents, &gogit.CommitOptions{Author: &object.Signature{Name: "test", Email: "test@example.com", When: time.Now()}})
+		require.NoError(t, err)
+		return hash
+	}
+	first := commit("v1")
+	_, err = repo.CreateTag("v1.0.0", first, nil)
+	require.NoError(t, err)
+	second := commit("v2")
+	commit("v3")
+
+	for _, noCache := range []bool{true, false} {
+		for ref, expected := range map[string]string{"": "v3", "v1.0.0": "v1", second.String(): "v2"} {
+			r, err := CloneRepo(GitOptions{URL: dir, Ref: ref, NoCache: noCache})
+			require.NoError(t, err, ref)
+			contents, err := ioutil.ReadFile(filepath.Join(r.worktreePath, "cluster.yaml"))
+			require.NoError(t, err)
+			assert.Equal(t, expected, string(contents), ref)
+			r.Close()
+		}
+	}
+}
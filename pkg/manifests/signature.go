@@ -0,0 +1,266 @@
+package manifests
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/pem"
+	"hash"
+	"io/ioutil"
+	"strings"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/crypto/openpgp"
+	xcryptossh "golang.org/x/crypto/ssh"
+	gogit "gopkg.in/src-d/go-git.v4"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+)
+
+const (
+	pgpPublicKeyBegin = "-----BEGIN PGP PUBLIC KEY BLOCK-----"
+	pgpPublicKeyEnd   = "-----END PGP PUBLIC KEY BLOCK-----"
+	pgpSignatureBegin = "-----BEGIN PGP SIGNATURE-----"
+	sshSignatureBegin = "-----BEGIN SSH SIGNATURE-----"
+	// sshSignatureMagic starts SSH signatures, and the data they sign.
+	sshSignatureMagic = "SSHSIG"
+	// sshSignatureNamespace is the namespace of the SSH signatures of Git.
+	sshSignatureNamespace = "git"
+)
+
+// trustedKeys are the keys the commits or tags of the repository must be
+// signed with.
+type trustedKeys struct {
+	pgp openpgp.EntityList
+	ssh []xcryptossh.PublicKey
+}
+
+// loadTrustedKeys reads the armored PGP public keys, and the SSH public keys,
+// in the authorized_keys format, of the provided file.
+func loadTrustedKeys(path string) (*trustedKeys, error) {
+	contents, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read trusted keys: %s", path)
+	}
+	keys := &trustedKeys{}
+	rest := string(contents)
+	for {
+		begin := strings.Index(rest, pgpPublicKeyBegin)
+		if begin < 0 {
+			break
+		}
+		end := strings.Index(rest[begin:], pgpPublicKeyEnd)
+		if end < 0 {
+			return nil, errors.Errorf("unterminated PGP public key in %s", path)
+		}
+		end += begin + len(pgpPublicKeyEnd)
+		entities, err := openpgp.ReadArmoredKeyRing(strings.NewReader(rest[begin:end]))
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to parse PGP public key in %s", path)
+		}
+		keys.pgp = append(keys.pgp, entities...)
+		rest = rest[:begin] + rest[end:]
+	}
+	for _, line := range strings.Split(rest, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		key, _, _, _, err := xcryptossh.ParseAuthorizedKey([]byte(line))
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to parse SSH public key %q in %s", line, path)
+		}
+		keys.ssh = append(keys.ssh, key)
+	}
+	if len(keys.pgp) == 0 && len(keys.ssh) == 0 {
+		return nil, errors.Errorf("no trusted keys in %s", path)
+	}
+	return keys, nil
+}
+
+// verify checks the armored signature of the payload is one of a trusted key,
+// and returns the key.
+func (k *trustedKeys) verify(signature string, payload []byte) (string, error) {
+	switch {
+	case strings.HasPrefix(signature, pgpSignatureBegin):
+		entity, err := openpgp.CheckArmoredDetachedSignature(k.pgp, bytes.NewReader(payload), strings.NewReader(signature))
+		if err != nil {
+			return "", err
+		}
+		for name := range entity.Identities {
+			return name, nil
+		}
+		return entity.PrimaryKey.KeyIdString(), nil
+	case strings.HasPrefix(signature, sshSignatureBegin):
+		key, err := verifySSHSignature(signature, payload)
+		if err != nil {
+			return "", err
+		}
+		for _, trusted := range k.ssh {
+			if bytes.Equal(trusted.Marshal(), key.Marshal()) {
+				return xcryptossh.FingerprintSHA256(key), nil
+			}
+		}
+		return "", errors.Errorf("signed by untrusted key %s", xcryptossh.FingerprintSHA256(key))
+	default:
+		return "", errors.New("unsupported signature")
+	}
+}
+
+// sshSignature is an SSH signature, as specified by PROTOCOL.sshsig of
+// OpenSSH, after its magic preamble.
+type sshSignature struct {
+	Version       uint32
+	PublicKey     []byte
+	Namespace     string
+	Reserved      string
+	HashAlgorithm string
+	Signature     []byte
+}
+
+// sshSignedData is the data an SSH signature signs, after its magic preamble.
+type sshSignedData struct {
+	Namespace     string
+	Reserved      string
+	HashAlgorithm string
+	Hash          []byte
+}
+
+// verifySSHSignature checks the armored SSH signature of the payload, and
+// returns the key which signed it.
+func verifySSHSignature(armored string, payload []byte) (xcryptossh.PublicKey, error) {
+	block, _ := pem.Decode([]byte(armored))
+	if block == nil || !bytes.HasPrefix(block.Bytes, []byte(sshSignatureMagic)) {
+		return nil, errors.New("invalid SSH signature")
+	}
+	var sig sshSignature
+	if err := xcryptossh.Unmarshal(block.Bytes[len(sshSignatureMagic):], &sig); err != nil {
+		return nil, errors.Wrap(err, "invalid SSH signature")
+	}
+	if sig.Version != 1 || sig.Namespace != sshSignatureNamespace {
+		return nil, errors.Errorf("unexpected SSH signature version %d or namespace %q", sig.Version, sig.Namespace)
+	}
+	var h hash.Hash
+	switch sig.HashAlgorithm {
+	case "sha256":
+		h = sha256.New()
+	case "sha512":
+		h = sha512.New()
+	default:
+		return nil, errors.Errorf("unsupported SSH signature hash algorithm %q", sig.HashAlgorithm)
+	}
+	h.Write(payload)
+	key, err := xcryptossh.ParsePublicKey(sig.PublicKey)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid SSH signature key")
+	}
+	var signature xcryptossh.Signature
+	if err := xcryptossh.Unmarshal(sig.Signature, &signature); err != nil {
+		return nil, errors.Wrap(err, "invalid SSH signature")
+	}
+	signed := append([]byte(sshSignatureMagic), xcryptossh.Marshal(sshSignedData{
+		Namespace:     sig.Namespace,
+		Reserved:      sig.Reserved,
+		HashAlgorithm: sig.HashAlgorithm,
+		Hash:          h.Sum(nil),
+	})...)
+	if err := key.Verify(signed, &signature); err != nil {
+		return nil, errors.Wrap(err, "bad SSH signature")
+	}
+	return key, nil
+}
+
+// verifySignature checks the commit checked out, or the tag it was checked out
+// by, is signed by one of the trusted keys.
+func verifySignature(repo *gogit.Repository, ref, trustedKeysPath string) error {
+	keys, err := loadTrustedKeys(trustedKeysPath)
+	if err != nil {
+		return err
+	}
+	head, err := repo.Head()
+	if err != nil {
+		return errors.Wrap(err, "failed to read the commit checked out")
+	}
+	commit, err := repo.CommitObject(head.Hash())
+	if err != nil {
+		return errors.Wrap(err, "failed to read the commit checked out")
+	}
+	var reasons []string
+	if commit.PGPSignature == "" {
+		reasons = append(reasons, "the commit is not signed")
+	} else {
+		payload, err := encoded(commit.EncodeWithoutSignature)
+		if err != nil {
+			return err
+		}
+		signer, err := keys.verify(commit.PGPSignature, payload)
+		if err == nil {
+			log.Infof("Commit %s is signed by %s", commit.Hash, signer)
+			return nil
+		}
+		reasons = append(reasons, "commit: "+err.Error())
+	}
+
+	if tag := tagObject(repo, ref); tag != nil {
+		signature, payload, err := tagSignature(tag)
+		if err != nil {
+			return err
+		}
+		if signature == "" {
+			reasons = append(reasons, "the tag is not signed")
+		} else if signer, err := keys.verify(signature, payload); err == nil {
+			log.Infof("Tag %s is signed by %s", tag.Name, signer)
+			return nil
+		} else {
+			reasons = append(reasons, "tag: "+err.Error())
+		}
+	}
+	return errors.Errorf("commit %s is not signed by a trusted key (%s)", commit.Hash, strings.Join(reasons, "; "))
+}
+
+// tagObject returns the annotated tag the ref names, if any.
+func tagObject(repo *gogit.Repository, ref string) *object.Tag {
+	if ref == "" || isCommit(ref) {
+		return nil
+	}
+	name := plumbing.NewTagReferenceName(ref)
+	if strings.HasPrefix(ref, "refs/") {
+		name = plumbing.ReferenceName(ref)
+	}
+	r, err := repo.Reference(name, true)
+	if err != nil {
+		return nil
+	}
+	tag, err := repo.TagObject(r.Hash())
+	if err != nil {
+		// Lightweight tags aren't objects, and can't be signed.
+		return nil
+	}
+	return tag
+}
+
+// tagSignature returns the signature of the tag, and the payload it signs.
+// go-git only splits PGP signatures from the message of tags.
+func tagSignature(tag *object.Tag) (string, []byte, error) {
+	unsigned := *tag
+	signature := tag.PGPSignature
+	if i := strings.Index(tag.Message, sshSignatureBegin); i >= 0 {
+		unsigned.Message, signature = tag.Message[:i], tag.Message[i:]
+	}
+	payload, err := encoded(unsigned.EncodeWithoutSignature)
+	return signature, payload, err
+}
+
+func encoded(encode func(plumbing.EncodedObject) error) ([]byte, error) {
+	o := &plumbing.MemoryObject{}
+	if err := encode(o); err != nil {
+		return nil, err
+	}
+	r, err := o.Reader()
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return ioutil.ReadAll(r)
+}
@@ -0,0 +1,161 @@
+package manifests
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha512"
+	"encoding/pem"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/openpgp"
+	"golang.org/x/crypto/openpgp/armor"
+	xcryptossh "golang.org/x/crypto/ssh"
+	gogit "gopkg.in/src-d/go-git.v4"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+)
+
+func armoredPublicKey(t *testing.T, entity *openpgp.Entity) string {
+	var buf bytes.Buffer
+	w, err := armor.Encode(&buf, openpgp.PublicKeyType, nil)
+	require.NoError(t, err)
+	require.NoError(t, entity.Serialize(w))
+	require.NoError(t, w.Close())
+	return buf.String()
+}
+
+// sshSign signs the payload as ssh-keygen -Y sign -n git does.
+func sshSign(t *testing.T, signer xcryptossh.Signer, payload []byte) string {
+	hash := sha512.Sum512(payload)
+	signed := append([]byte(sshSignatureMagic), xcryptossh.Marshal(sshSignedData{
+		Namespace:     sshSignatureNamespace,
+		HashAlgorithm: "sha512",
+		Hash:          hash[:],
+	})...)
+	signature, err := signer.Sign(rand.Reader, signed)
+	require.NoError(t, err)
+	blob := append([]byte(sshSignatureMagic), xcryptossh.Marshal(sshSignature{
+		Version:       1,
+		PublicKey:     signer.PublicKey().Marshal(),
+		Namespace:     sshSignatureNamespace,
+		HashAlgorithm: "sha512",
+		Signature:     xcryptossh.Marshal(signature),
+	})...)
+	return string(pem.EncodeToMemory(&pem.Block{Type: "SSH SIGNATURE", Bytes: blob}))
+}
+
+func storeObject(t *testing.T, repo *gogit.Repository, o interface {
+	Encode(plumbing.EncodedObject) error
+}) plumbing.Hash {
+	obj := repo.Storer.NewEncodedObject()
+	require.NoError(t, o.Encode(obj))
+	hash, err := repo.Storer.SetEncodedObject(obj)
+	require.NoError(t, err)
+	return hash
+}
+
+func TestRequireSignedCommit(t *testing.T) {
+	defer tempCache(t)()
+	dir, err := ioutil.TempDir("", "wksctl-manifests-repo")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	repo, err := gogit.PlainInit(dir, false)
+	require.NoError(t, err)
+	worktree, err := repo.Worktree()
+	require.NoError(t, err)
+
+	pgpKey, err := openpgp.NewEntity("test", "", "test@example.com", nil)
+	require.NoError(t, err)
+	otherPGPKey, err := openpgp.NewEntity("other", "", "other@example.com", nil)
+	require.NoError(t, err)
+	_, private, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	sshKey, err := xcryptossh.NewSignerFromKey(private)
+	require.NoError(t, err)
+	_, otherPrivate, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	otherSSHKey, err := xcryptossh.NewSignerFromKey(otherPrivate)
+	require.NoError(t, err)
+
+	author := &object.Signature{Name: "test", Email: "test@example.com", When: time.Now()}
+	commit := func(contents string, key *openpgp.Entity) plumbing.Hash {
+		require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "cluster.yaml"), []byte(contents), 0644))
+		_, err := worktree.Add("cluster.yaml")
+		require.NoError(t, err)
+		hash, err := worktree.Commit(contents, &gogit.CommitOptions{Author: author, SignKey: key})
+		require.NoError(t, err)
+		return hash
+	}
+	unsigned := commit("unsigned", nil)
+	pgpSigned := commit("pgp", pgpKey)
+
+	// go-git only signs with PGP keys, SSH signatures are made by hand.
+	sshCommit := func(signer xcryptossh.Signer) plumbing.Hash {
+		head, err := repo.Head()
+		require.NoError(t, err)
+		parent, err := repo.CommitObject(head.Hash())
+		require.NoError(t, err)
+		c := &object.Commit{Author: *author, Committer: *author, Message: "ssh", TreeHash: parent.TreeHash, ParentHashes: []plumbing.Hash{parent.Hash}}
+		payload, err := encoded(c.EncodeWithoutSignature)
+		require.NoError(t, err)
+		c.PGPSignature = sshSign(t, signer, payload)
+		hash := storeObject(t, repo, c)
+		require.NoError(t, repo.Storer.SetReference(plumbing.NewHashReference(head.Name(), hash)))
+		return hash
+	}
+	sshSigned := sshCommit(sshKey)
+	otherSSHSigned := sshCommit(otherSSHKey)
+
+	_, err = repo.CreateTag("pgp-tag", unsigned, &gogit.CreateTagOptions{Tagger: author, Message: "pgp-tag", SignKey: pgpKey})
+	require.NoError(t, err)
+	_, err = repo.CreateTag("unsigned-tag", unsigned, &gogit.CreateTagOptions{Tagger: author, Message: "unsigned-tag"})
+	require.NoError(t, err)
+	_, err = repo.CreateTag("lightweight-tag", unsigned, nil)
+	require.NoError(t, err)
+	tag := &object.Tag{Name: "ssh-tag", Tagger: *author, Message: "ssh-tag\n", TargetType: plumbing.CommitObject, Target: unsigned}
+	payload, err := encoded(tag.EncodeWithoutSignature)
+	require.NoError(t, err)
+	tag.Message += sshSign(t, sshKey, payload)
+	require.NoError(t, repo.Storer.SetReference(plumbing.NewHashReference(plumbing.NewTagReferenceName("ssh-tag"), storeObject(t, repo, tag))))
+
+	trustedKeys := filepath.Join(dir, "..", filepath.Base(dir)+"-trusted-keys")
+	require.NoError(t, ioutil.WriteFile(trustedKeys, []byte("# Release managers.\n"+armoredPublicKey(t, pgpKey)+string(xcryptossh.MarshalAuthorizedKey(sshKey.PublicKey()))), 0644))
+	defer os.Remove(trustedKeys)
+	untrustedKeys := trustedKeys + "-other"
+	require.NoError(t, ioutil.WriteFile(untrustedKeys, []byte(armoredPublicKey(t, otherPGPKey)), 0644))
+	defer os.Remove(untrustedKeys)
+
+	for _, test := range []struct {
+		ref, keys string
+		valid     bool
+	}{
+		{pgpSigned.String(), trustedKeys, true},
+		{sshSigned.String(), trustedKeys, true},
+		{"pgp-tag", trustedKeys, true},
+		{"ssh-tag", trustedKeys, true},
+		{"", trustedKeys, false},
+		{unsigned.String(), trustedKeys, false},
+		{otherSSHSigned.String(), trustedKeys, false},
+		{"unsigned-tag", trustedKeys, false},
+		{"lightweight-tag", trustedKeys, false},
+		{pgpSigned.String(), untrustedKeys, false},
+		{"pgp-tag", untrustedKeys, false},
+		{pgpSigned.String(), "", false},
+		{pgpSigned.String(), filepath.Join(dir, "missing"), false},
+	} {
+		r, err := CloneRepo(GitOptions{URL: dir, Ref: test.ref, RequireSigned: true, TrustedKeysPath: test.keys})
+		if test.valid {
+			require.NoError(t, err, test.ref)
+			r.Close()
+		} else {
+			assert.Error(t, err, test.ref)
+		}
+	}
+}
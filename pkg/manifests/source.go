@@ -0,0 +1,310 @@
+package manifests
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	capeimachine "github.com/weaveworks/cluster-api-provider-existinginfra/pkg/cluster/machine"
+	"github.com/weaveworks/wksctl/pkg/cluster/machine"
+	"github.com/weaveworks/wksctl/pkg/utilities/tarball"
+)
+
+// ManifestSource provides the cluster and machines manifests of a cluster, and
+// the directory holding its configuration, as local files.
+type ManifestSource interface {
+	ClusterManifestPath() (string, error)
+	MachinesManifestPath() (string, error)
+	ConfigDirectory() (string, error)
+	// Close removes the local copies of remote manifests.
+	Close() error
+}
+
+// Names of the manifests in directories, repositories and bundles.
+const clusterManifestName = "cluster.yaml"
+
+var machinesManifestNames = []string{"machine.yaml", "machines.yaml"}
+
+// httpClient downloads remote manifests, replaced by tests.
+var httpClient = http.DefaultClient
+
+// SourceOptions select the source of the manifests of a cluster.
+type SourceOptions struct {
+	// Location is a local directory, a local tar.gz bundle, or the HTTPS URL
+	// of a tar.gz bundle, holding the manifests. Both the manifests and the
+	// configuration are read from it.
+	Location string
+	// ClusterPath and MachinesPath are the paths of the manifests on the
+	// local filesystem, used unless Location or the Git URL are set.
+	ClusterPath  string
+	MachinesPath string
+	// ConfigDirectory overrides the configuration directory of remote
+	// sources, unless it is empty or ".".
+	ConfigDirectory string
+	// Git, if its URL is set, selects the repository holding the manifests.
+	Git GitOptions
+}
+
+// OpenSource returns the source of manifests the options select, fetching
+// remote manifests to local files.
+func OpenSource(ctx context.Context, o SourceOptions) (ManifestSource, error) {
+	var source ManifestSource
+	var err error
+	switch {
+	case o.Git.URL != "" && o.Location != "":
+		return nil, errors.New("the manifests can come from either a Git repository or a location, not both")
+	case o.Git.RequireSigned && o.Git.URL == "":
+		return nil, errors.New("only the commits of Git repositories can be verified, --require-signed-commit requires --git-url")
+	case o.Git.URL != "":
+		source, err = CloneRepo(o.Git)
+	case isBundle(o.Location) && isHTTPS(o.Location):
+		source, err = FetchBundle(ctx, o.Location)
+	case isBundle(o.Location):
+		source, err = UnpackBundle(o.Location)
+	case isHTTPS(o.Location):
+		// The configuration files of a directory can't be listed over HTTPS.
+		return nil, errors.Errorf("%s is not a tar.gz bundle, only bundles holding the manifests and the configuration of the cluster can be downloaded", o.Location)
+	case o.Location != "":
+		source = &Directory{Path: o.Location}
+	default:
+		return &generatedNames{ManifestSource: &Local{ClusterPath: o.ClusterPath, MachinesPath: o.MachinesPath, ConfigDir: o.ConfigDirectory}}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if o.ConfigDirectory != "" && o.ConfigDirectory != "." {
+		source = &configOverride{ManifestSource: source, configDir: o.ConfigDirectory}
+	}
+	return &generatedNames{ManifestSource: source}, nil
+}
+
+// Paths returns the paths of the cluster and machines manifests of the source,
+// and of its configuration directory.
+func Paths(s ManifestSource) (clusterPath, machinesPath, configDir string, err error) {
+	if clusterPath, err = s.ClusterManifestPath(); err != nil {
+		return "", "", "", errors.Wrap(err, "ClusterManifestPath")
+	}
+	if machinesPath, err = s.MachinesManifestPath(); err != nil {
+		return "", "", "", errors.Wrap(err, "MachinesManifestPath")
+	}
+	if configDir, err = s.ConfigDirectory(); err != nil {
+		return "", "", "", errors.Wrap(err, "ConfigDirectory")
+	}
+	return clusterPath, machinesPath, configDir, nil
+}
+
+// IsLocal returns whether the manifests of the source are the user's own
+// files, rather than local copies of remote manifests, or of manifests whose
+// machines were named.
+func IsLocal(s ManifestSource) bool {
+	switch s := s.(type) {
+	case *Local:
+		return true
+	case *Directory:
+		return s.Remove == ""
+	case *configOverride:
+		return IsLocal(s.ManifestSource)
+	case *generatedNames:
+		return s.dir == "" && IsLocal(s.ManifestSource)
+	}
+	return false
+}
+
+func isHTTPS(location string) bool {
+	return strings.HasPrefix(location, "https://")
+}
+
+func isBundle(location string) bool {
+	if u, err := url.Parse(location); err == nil && isHTTPS(location) {
+		location = u.Path
+	}
+	return strings.HasSuffix(location, ".tar.gz") || strings.HasSuffix(location, ".tgz")
+}
+
+// Local are manifests and configuration at the provided paths.
+type Local struct {
+	ClusterPath  string
+	MachinesPath string
+	ConfigDir    string
+}
+
+func (l *Local) ClusterManifestPath() (string, error) {
+	return l.ClusterPath, nil
+}
+
+func (l *Local) MachinesManifestPath() (string, error) {
+	return l.MachinesPath, nil
+}
+
+func (l *Local) ConfigDirectory() (string, error) {
+	return l.ConfigDir, nil
+}
+
+func (l *Local) Close() error {
+	return nil
+}
+
+// Directory is a directory holding the manifests, as cluster.yaml and
+// machines.yaml (or machine.yaml), and the configuration. Remove, if set,
+// removes the directory on Close.
+type Directory struct {
+	Path   string
+	Remove string
+}
+
+func (d *Directory) ClusterManifestPath() (string, error) {
+	path := filepath.Join(d.Path, clusterManifestName)
+	if _, err := os.Stat(path); err != nil {
+		return "", errors.Wrap(err, "cluster manifest not readable")
+	}
+	return path, nil
+}
+
+func (d *Directory) MachinesManifestPath() (string, error) {
+	for _, name := range machinesManifestNames {
+		path := filepath.Join(d.Path, name)
+		if _, err := os.Stat(path); err == nil {
+			return path, nil
+		}
+	}
+	return "", errors.New("machines manifest not found")
+}
+
+func (d *Directory) ConfigDirectory() (string, error) {
+	return d.Path, nil
+}
+
+func (d *Directory) Close() error {
+	if d.Remove == "" {
+		return nil
+	}
+	return os.RemoveAll(d.Remove)
+}
+
+// configOverride reads the configuration from another directory than the one
+// of the source.
+type configOverride struct {
+	ManifestSource
+	configDir string
+}
+
+func (c *configOverride) ConfigDirectory() (string, error) {
+	return c.configDir, nil
+}
+
+// generatedNames names the machines of the source's machines manifest using
+// "generateName", in a copy of the manifest, for the objects applied to the
+// cluster to be named, and named the same way on every run (see
+// machine.UpdateWithGeneratedNames).
+type generatedNames struct {
+	ManifestSource
+	// dir holds the copy of the machines manifest, if one was made.
+	dir  string
+	path string
+}
+
+func (g *generatedNames) MachinesManifestPath() (string, error) {
+	if g.path != "" {
+		return g.path, nil
+	}
+	path, err := g.ManifestSource.MachinesManifestPath()
+	if err != nil {
+		return "", err
+	}
+	machines, bml, err := capeimachine.ParseManifest(path)
+	if err != nil || !machine.UsesGenerateName(machines, bml) {
+		// Invalid manifests are reported by the commands parsing them.
+		g.path = path
+		return path, nil
+	}
+	contents, err := machine.GetMachinesManifest(path)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to generate the names of the machines of %s", path)
+	}
+	dir, err := ioutil.TempDir("", "wksctl-machines")
+	if err != nil {
+		return "", errors.Wrap(err, "TempDir")
+	}
+	named := filepath.Join(dir, filepath.Base(path))
+	if err := ioutil.WriteFile(named, []byte(contents), 0600); err != nil {
+		os.RemoveAll(dir)
+		return "", errors.Wrap(err, "failed to write the machines manifest with generated names")
+	}
+	log.Infof("Generated the names of the machines of %s using generateName", path)
+	g.dir, g.path = dir, named
+	return named, nil
+}
+
+func (g *generatedNames) Close() error {
+	if g.dir != "" {
+		os.RemoveAll(g.dir)
+	}
+	return g.ManifestSource.Close()
+}
+
+// FetchBundle downloads and unpacks the tar.gz bundle at the provided HTTPS
+// URL.
+func FetchBundle(ctx context.Context, location string) (*Directory, error) {
+	f, err := ioutil.TempFile("", "wksctl-manifests-*.tar.gz")
+	if err != nil {
+		return nil, errors.Wrap(err, "TempFile")
+	}
+	f.Close()
+	defer os.Remove(f.Name())
+	if err := download(ctx, location, f.Name()); err != nil {
+		return nil, errors.Wrap(err, "failed to download manifests bundle")
+	}
+	return UnpackBundle(f.Name())
+}
+
+// UnpackBundle unpacks the tar.gz bundle at the provided path. The manifests
+// are at the root of the bundle, or of its only directory.
+func UnpackBundle(path string) (*Directory, error) {
+	dir, err := ioutil.TempDir("", "wksctl-manifests")
+	if err != nil {
+		return nil, errors.Wrap(err, "TempDir")
+	}
+	d := &Directory{Path: dir, Remove: dir}
+	if err := (&tarball.Tarball{Path: path, Compression: "z"}).Unpack(dir); err != nil {
+		d.Close()
+		return nil, errors.Wrapf(err, "failed to unpack manifests bundle %s", path)
+	}
+	if _, err := os.Stat(filepath.Join(dir, clusterManifestName)); os.IsNotExist(err) {
+		entries, err := ioutil.ReadDir(dir)
+		if err == nil && len(entries) == 1 && entries[0].IsDir() {
+			d.Path = filepath.Join(dir, entries[0].Name())
+		}
+	}
+	return d, nil
+}
+
+func download(ctx context.Context, location, path string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, location, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return errors.Errorf("GET %s: %s", location, resp.Status)
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, resp.Body); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
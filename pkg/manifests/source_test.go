@@ -0,0 +1,208 @@
+package manifests
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	capeimachine "github.com/weaveworks/cluster-api-provider-existinginfra/pkg/cluster/machine"
+)
+
+var files = map[string]string{
+	"cluster.yaml":       "kind: Cluster\n",
+	"machines.yaml":      "kind: Machine\n",
+	"docker-config.yaml": "registry: example.com\n",
+}
+
+func tarGz(t *testing.T, prefix string) []byte {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for name, contents := range files {
+		require.NoError(t, tw.WriteHeader(&tar.Header{Name: prefix + name, Mode: 0644, Size: int64(len(contents))}))
+		_, err := tw.Write([]byte(contents))
+		require.NoError(t, err)
+	}
+	require.NoError(t, tw.Close())
+	require.NoError(t, gz.Close())
+	return buf.Bytes()
+}
+
+// assertSource checks the source provides the manifests and configuration of
+// files, and removes its local copies once closed.
+func assertSource(t *testing.T, source ManifestSource) {
+	clusterPath, machinesPath, configDir, err := Paths(source)
+	require.NoError(t, err)
+	for path, name := range map[string]string{clusterPath: "cluster.yaml", machinesPath: "machines.yaml"} {
+		contents, err := ioutil.ReadFile(path)
+		require.NoError(t, err)
+		assert.Equal(t, files[name], string(contents))
+	}
+	_, err = os.Stat(filepath.Join(configDir, "docker-config.yaml"))
+	assert.NoError(t, err)
+
+	require.NoError(t, source.Close())
+	_, err = os.Stat(clusterPath)
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestLocalSources(t *testing.T) {
+	dir, err := ioutil.TempDir("", "wksctl-manifests-test")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	for name, contents := range files {
+		require.NoError(t, ioutil.WriteFile(filepath.Join(dir, name), []byte(contents), 0644))
+	}
+	ctx := context.Background()
+
+	source, err := OpenSource(ctx, SourceOptions{ClusterPath: "c.yaml", MachinesPath: "m.yaml", ConfigDirectory: "."})
+	require.NoError(t, err)
+	clusterPath, machinesPath, configDir, err := Paths(source)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"c.yaml", "m.yaml", "."}, []string{clusterPath, machinesPath, configDir})
+	assert.True(t, IsLocal(source))
+
+	source, err = OpenSource(ctx, SourceOptions{Location: dir, ConfigDirectory: "."})
+	require.NoError(t, err)
+	clusterPath, machinesPath, configDir, err = Paths(source)
+	require.NoError(t, err)
+	assert.Equal(t, []string{filepath.Join(dir, "cluster.yaml"), filepath.Join(dir, "machines.yaml"), dir}, []string{clusterPath, machinesPath, configDir})
+	assert.True(t, IsLocal(source))
+	require.NoError(t, source.Close())
+	_, err = os.Stat(dir)
+	assert.NoError(t, err, "local directories are left in place")
+
+	bundle := filepath.Join(dir, "cluster.tar.gz")
+	require.NoError(t, ioutil.WriteFile(bundle, tarGz(t, "my-cluster/"), 0644))
+	source, err = OpenSource(ctx, SourceOptions{Location: bundle})
+	require.NoError(t, err)
+	assertSource(t, source)
+
+	source, err = OpenSource(ctx, SourceOptions{Location: bundle, ConfigDirectory: "/etc/wks"})
+	require.NoError(t, err)
+	_, _, configDir, err = Paths(source)
+	require.NoError(t, err)
+	assert.Equal(t, "/etc/wks", configDir)
+	assert.False(t, IsLocal(source), "bundles are unpacked to temporary directories")
+	source.Close()
+
+	_, err = OpenSource(ctx, SourceOptions{Location: dir, Git: GitOptions{URL: "git@github.com:example/cluster.git"}})
+	assert.Error(t, err)
+}
+
+func TestBundleRefusesLinksOutside(t *testing.T) {
+	dir, err := ioutil.TempDir("", "wksctl-manifests-test")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	secret := filepath.Join(dir, "id_rsa")
+	require.NoError(t, ioutil.WriteFile(secret, []byte("private key"), 0600))
+
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	contents := files["cluster.yaml"]
+	require.NoError(t, tw.WriteHeader(&tar.Header{Name: "cluster.yaml", Mode: 0644, Size: int64(len(contents))}))
+	_, err = tw.Write([]byte(contents))
+	require.NoError(t, err)
+	require.NoError(t, tw.WriteHeader(&tar.Header{Name: "machines.yaml", Typeflag: tar.TypeSymlink, Linkname: secret}))
+	require.NoError(t, tw.Close())
+	require.NoError(t, gz.Close())
+	bundle := filepath.Join(dir, "cluster.tar.gz")
+	require.NoError(t, ioutil.WriteFile(bundle, buf.Bytes(), 0644))
+
+	_, err = OpenSource(context.Background(), SourceOptions{Location: bundle})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `symbolic link "machines.yaml" in archive points outside of it`)
+}
+
+func TestHTTPSSources(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/cluster.tar.gz":
+			w.Write(tarGz(t, ""))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+	httpClient = server.Client()
+	defer func() { httpClient = http.DefaultClient }()
+	ctx := context.Background()
+
+	// Directories would only provide the manifests, without the configuration.
+	_, err := OpenSource(ctx, SourceOptions{Location: server.URL + "/manifests/"})
+	assert.Error(t, err)
+
+	source, err := OpenSource(ctx, SourceOptions{Location: server.URL + "/cluster.tar.gz?version=2"})
+	require.NoError(t, err)
+	assertSource(t, source)
+
+	_, err = OpenSource(ctx, SourceOptions{Location: server.URL + "/missing.tar.gz"})
+	assert.Error(t, err)
+}
+
+const machinesWithGenerateName = `apiVersion: cluster.x-k8s.io/v1alpha3
+kind: Machine
+metadata:
+  generateName: master-
+  labels:
+    set: master
+spec:
+  clusterName: example
+  infrastructureRef:
+    apiVersion: cluster.weave.works/v1alpha3
+    kind: ExistingInfraMachine
+---
+apiVersion: cluster.weave.works/v1alpha3
+kind: ExistingInfraMachine
+metadata:
+  generateName: master-
+spec:
+  private: {address: 172.17.8.101, port: 22}
+  public: {address: 127.0.0.1, port: 2222}
+`
+
+func TestGeneratedNames(t *testing.T) {
+	dir, err := ioutil.TempDir("", "wksctl-manifests-test")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "cluster.yaml"), []byte(files["cluster.yaml"]), 0644))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "machines.yaml"), []byte(machinesWithGenerateName), 0644))
+
+	open := func() (string, string) {
+		source, err := OpenSource(context.Background(), SourceOptions{Location: dir})
+		require.NoError(t, err)
+		defer func() {
+			require.NoError(t, source.Close())
+		}()
+		_, machinesPath, _, err := Paths(source)
+		require.NoError(t, err)
+		assert.False(t, IsLocal(source), "the machines are named in a copy of the manifest")
+		machines, bml, err := capeimachine.ParseManifest(machinesPath)
+		require.NoError(t, err)
+		require.Len(t, machines, 1)
+		require.Len(t, bml, 1)
+		assert.Empty(t, capeimachine.Validate(machines, bml))
+		assert.Equal(t, bml[0].Name, machines[0].Spec.InfrastructureRef.Name)
+		return machines[0].Name, bml[0].Name
+	}
+	machineName, eimName := open()
+	assert.Regexp(t, `^master-[0-9a-z]{5}-[0-9a-z]{5}$`, machineName)
+	assert.Regexp(t, `^master-[0-9a-z]{5}-[0-9a-z]{5}$`, eimName)
+	againMachineName, againEIMName := open()
+	assert.Equal(t, machineName, againMachineName, "every run names the machines the same way")
+	assert.Equal(t, eimName, againEIMName, "every run names the machines the same way")
+
+	contents, err := ioutil.ReadFile(filepath.Join(dir, "machines.yaml"))
+	require.NoError(t, err)
+	assert.Equal(t, machinesWithGenerateName, string(contents), "the manifest is left as it is")
+}
@@ -0,0 +1,220 @@
+package diff
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/weaveworks/cluster-api-provider-existinginfra/pkg/plan"
+)
+
+// Diff lists the differences between two plans. Resources of nested plans are
+// identified by the IDs of their enclosing plans and their own ID, separated
+// by slashes, e.g. "install:seed-node/install:cni".
+type Diff struct {
+	Added   []Resource       `json:"added"`
+	Removed []Resource       `json:"removed"`
+	Changed []ResourceChange `json:"changed"`
+}
+
+// Resource is a resource present in only one of the plans.
+type Resource struct {
+	ID   string `json:"id"`
+	Type string `json:"type"`
+}
+
+// ResourceChange is a resource present in both plans, whose state differs.
+type ResourceChange struct {
+	ID     string        `json:"id"`
+	Type   string        `json:"type"`
+	Fields []FieldChange `json:"fields"`
+}
+
+// FieldChange is a field of a resource's state which differs between both
+// plans. From or To is nil if the field is only present in the other plan.
+type FieldChange struct {
+	Path string      `json:"path"`
+	From interface{} `json:"from"`
+	To   interface{} `json:"to"`
+}
+
+// Empty returns true if both plans are identical.
+func (d *Diff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0
+}
+
+// Plans computes the differences between the from and to plans.
+func Plans(from, to *plan.Plan) (*Diff, error) {
+	fromResources, err := flatten(from, "")
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read the original plan")
+	}
+	toResources, err := flatten(to, "")
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read the updated plan")
+	}
+
+	d := &Diff{Added: []Resource{}, Removed: []Resource{}, Changed: []ResourceChange{}}
+	for _, id := range sortedIDs(fromResources) {
+		f := fromResources[id]
+		t, ok := toResources[id]
+		if !ok {
+			d.Removed = append(d.Removed, Resource{ID: id, Type: f.typename})
+			continue
+		}
+		var fields []FieldChange
+		if f.typename != t.typename {
+			fields = append(fields, FieldChange{Path: "type", From: f.typename, To: t.typename})
+		}
+		fields = append(fields, diffValues("dependsOn", f.dependsOn, t.dependsOn)...)
+		fields = append(fields, diffValues("", f.state, t.state)...)
+		if len(fields) > 0 {
+			d.Changed = append(d.Changed, ResourceChange{ID: id, Type: t.typename, Fields: fields})
+		}
+	}
+	for _, id := range sortedIDs(toResources) {
+		if _, ok := fromResources[id]; !ok {
+			d.Added = append(d.Added, Resource{ID: id, Type: toResources[id].typename})
+		}
+	}
+	return d, nil
+}
+
+// leaf is the displayable state of a resource which isn't a plan, as decoded
+// from JSON so that values of both plans can be compared whatever their Go
+// types.
+type leaf struct {
+	typename  string
+	dependsOn interface{}
+	state     interface{}
+}
+
+func flatten(p *plan.Plan, prefix string) (map[string]leaf, error) {
+	var entries map[string]map[string]interface{}
+	if err := json.Unmarshal([]byte(p.ToJSON()), &entries); err != nil {
+		return nil, err
+	}
+	leaves := map[string]leaf{}
+	for id, entry := range entries {
+		if nested, ok := p.GetResource(id).(*plan.Plan); ok {
+			nestedLeaves, err := flatten(nested, prefix+id+"/")
+			if err != nil {
+				return nil, err
+			}
+			for nestedID, l := range nestedLeaves {
+				leaves[nestedID] = l
+			}
+			continue
+		}
+		l := leaf{}
+		if meta, ok := entry["meta"].(map[string]interface{}); ok {
+			l.dependsOn = meta["dependsOn"]
+		}
+		for key, value := range entry {
+			if key != "meta" {
+				l.typename, l.state = key, value
+			}
+		}
+		leaves[prefix+id] = l
+	}
+	return leaves, nil
+}
+
+func sortedIDs(resources map[string]leaf) []string {
+	ids := make([]string, 0, len(resources))
+	for id := range resources {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// diffValues recursively compares two values decoded from JSON, and returns
+// the differences between their fields, sorted by path.
+func diffValues(path string, from, to interface{}) []FieldChange {
+	fromMap, fromIsMap := from.(map[string]interface{})
+	toMap, toIsMap := to.(map[string]interface{})
+	if fromIsMap && toIsMap {
+		keys := map[string]struct{}{}
+		for k := range fromMap {
+			keys[k] = struct{}{}
+		}
+		for k := range toMap {
+			keys[k] = struct{}{}
+		}
+		sortedKeys := make([]string, 0, len(keys))
+		for k := range keys {
+			sortedKeys = append(sortedKeys, k)
+		}
+		sort.Strings(sortedKeys)
+
+		var changes []FieldChange
+		for _, k := range sortedKeys {
+			changes = append(changes, diffValues(joinPath(path, k), fromMap[k], toMap[k])...)
+		}
+		return changes
+	}
+	if reflect.DeepEqual(from, to) {
+		return nil
+	}
+	return []FieldChange{{Path: path, From: from, To: to}}
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// maxValueLength is the length above which values are elided by WriteText.
+const maxValueLength = 72
+
+// WriteText writes a human readable version of the diff to w, eliding long
+// values.
+func (d *Diff) WriteText(w io.Writer) error {
+	var b strings.Builder
+	if d.Empty() {
+		b.WriteString("No differences.\n")
+	}
+	for _, r := range d.Added {
+		fmt.Fprintf(&b, "+ %s (%s)\n", r.ID, r.Type)
+	}
+	for _, r := range d.Removed {
+		fmt.Fprintf(&b, "- %s (%s)\n", r.ID, r.Type)
+	}
+	for _, r := range d.Changed {
+		fmt.Fprintf(&b, "~ %s (%s)\n", r.ID, r.Type)
+		for _, f := range r.Fields {
+			fmt.Fprintf(&b, "    %s: %s -> %s\n", f.Path, displayValue(f.From), displayValue(f.To))
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// WriteJSON writes the diff to w, as JSON.
+func (d *Diff) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", " ")
+	return enc.Encode(d)
+}
+
+func displayValue(v interface{}) string {
+	if v == nil {
+		return "<none>"
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprintf("%v", v)
+	}
+	s := string(data)
+	if len(s) > maxValueLength {
+		return fmt.Sprintf("%s... (%d bytes)", s[:maxValueLength], len(s))
+	}
+	return s
+}
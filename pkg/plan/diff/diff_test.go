@@ -0,0 +1,108 @@
+package diff
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/weaveworks/cluster-api-provider-existinginfra/pkg/plan"
+	"github.com/weaveworks/cluster-api-provider-existinginfra/pkg/plan/resource"
+	"github.com/weaveworks/cluster-api-provider-existinginfra/pkg/utilities/object"
+)
+
+func buildPlan(t *testing.T, build func(b *plan.Builder)) *plan.Plan {
+	b := plan.NewBuilder()
+	build(b)
+	p, err := b.Plan()
+	require.NoError(t, err)
+	return &p
+}
+
+func TestIdenticalPlans(t *testing.T) {
+	build := func(b *plan.Builder) {
+		b.AddResource("install:file", &resource.File{Content: "foo", Destination: "/etc/foo"})
+		b.AddResource("run:script", &resource.Run{Script: object.String("echo foo")}, plan.DependOn("install:file"))
+	}
+	d, err := Plans(buildPlan(t, build), buildPlan(t, build))
+	require.NoError(t, err)
+	assert.True(t, d.Empty())
+
+	var out bytes.Buffer
+	require.NoError(t, d.WriteText(&out))
+	assert.Equal(t, "No differences.\n", out.String())
+}
+
+func TestAddedRemovedAndChangedResources(t *testing.T) {
+	from := buildPlan(t, func(b *plan.Builder) {
+		b.AddResource("install:file", &resource.File{Content: "foo", Destination: "/etc/foo"})
+		b.AddResource("run:old", &resource.Run{Script: object.String("echo old")})
+	})
+	to := buildPlan(t, func(b *plan.Builder) {
+		b.AddResource("install:file", &resource.File{Content: "bar", Destination: "/etc/foo"})
+		b.AddResource("run:new", &resource.Run{Script: object.String("echo new")}, plan.DependOn("install:file"))
+	})
+
+	d, err := Plans(from, to)
+	require.NoError(t, err)
+	assert.Equal(t, []Resource{{ID: "run:new", Type: "Run"}}, d.Added)
+	assert.Equal(t, []Resource{{ID: "run:old", Type: "Run"}}, d.Removed)
+	assert.Equal(t, []ResourceChange{{
+		ID:     "install:file",
+		Type:   "File",
+		Fields: []FieldChange{{Path: "content", From: "foo", To: "bar"}},
+	}}, d.Changed)
+
+	var out bytes.Buffer
+	require.NoError(t, d.WriteText(&out))
+	assert.Equal(t, `+ run:new (Run)
+- run:old (Run)
+~ install:file (File)
+    content: "foo" -> "bar"
+`, out.String())
+}
+
+func TestChangedDependencies(t *testing.T) {
+	from := buildPlan(t, func(b *plan.Builder) {
+		b.AddResource("run:a", &resource.Run{Script: object.String("a")})
+		b.AddResource("run:b", &resource.Run{Script: object.String("b")})
+	})
+	to := buildPlan(t, func(b *plan.Builder) {
+		b.AddResource("run:a", &resource.Run{Script: object.String("a")})
+		b.AddResource("run:b", &resource.Run{Script: object.String("b")}, plan.DependOn("run:a"))
+	})
+
+	d, err := Plans(from, to)
+	require.NoError(t, err)
+	require.Len(t, d.Changed, 1)
+	assert.Equal(t, "run:b", d.Changed[0].ID)
+	assert.Equal(t, []FieldChange{{Path: "dependsOn", From: []interface{}{}, To: []interface{}{"run:a"}}}, d.Changed[0].Fields)
+}
+
+func TestNestedPlans(t *testing.T) {
+	nested := func(content string) *plan.Plan {
+		return buildPlan(t, func(b *plan.Builder) {
+			b.AddResource("install:file", &resource.File{Content: content, Destination: "/etc/foo"})
+		})
+	}
+	from := buildPlan(t, func(b *plan.Builder) {
+		b.AddResource("install:nested", nested("foo"))
+	})
+	to := buildPlan(t, func(b *plan.Builder) {
+		b.AddResource("install:nested", nested("bar"))
+	})
+
+	d, err := Plans(from, to)
+	require.NoError(t, err)
+	assert.Empty(t, d.Added)
+	assert.Empty(t, d.Removed)
+	require.Len(t, d.Changed, 1)
+	assert.Equal(t, "install:nested/install:file", d.Changed[0].ID)
+}
+
+func TestDisplayValue(t *testing.T) {
+	assert.Equal(t, "<none>", displayValue(nil))
+	assert.Equal(t, `"foo"`, displayValue("foo"))
+	long := displayValue(string(make([]byte, 100)))
+	assert.Contains(t, long, "... (")
+}
@@ -0,0 +1,108 @@
+package events
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/weaveworks/wksctl/pkg/plan/journal"
+)
+
+// Events report the progress of an apply to programs, as JSON objects written
+// one per line: the start and end of its phases, and of the resources of the
+// plan applied.
+
+// Types of events.
+const (
+	PhaseEvent    = "phase"
+	ResourceEvent = "resource"
+)
+
+// Phases of an apply.
+const (
+	Preflight        = "preflight"
+	SSHConnect       = "ssh-connect"
+	OSIdentification = "os-identification"
+	PlanBuild        = "plan-build"
+	PlanApply        = "plan-apply"
+)
+
+// Started is the status of events reporting the start of a phase or resource,
+// whose end is reported with one of the statuses of the journal.
+const Started journal.Status = "started"
+
+// Event is the start or end of a phase or resource.
+type Event struct {
+	Time     time.Time      `json:"time"`
+	Type     string         `json:"type"`
+	Phase    string         `json:"phase,omitempty"`
+	Resource string         `json:"resource,omitempty"`
+	Status   journal.Status `json:"status"`
+	// Duration is the duration, in seconds, of the phase or resource which
+	// ended.
+	Duration float64 `json:"duration,omitempty"`
+	Error    string  `json:"error,omitempty"`
+}
+
+// ValidateOutputFormat checks the provided events output format is supported.
+func ValidateOutputFormat(output string) error {
+	switch output {
+	case "", "json":
+		return nil
+	default:
+		return errors.Errorf("invalid events output format %q, expected json", output)
+	}
+}
+
+// Writer writes events as JSON objects, one per line.
+type Writer struct {
+	mu      sync.Mutex
+	encoder *json.Encoder
+}
+
+var _ journal.Listener = &Writer{}
+
+// NewWriter creates a writer of events to w.
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{encoder: json.NewEncoder(w)}
+}
+
+func (w *Writer) write(e Event) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	e.Time = time.Now().UTC()
+	// Events are best effort, failing to write them doesn't fail the apply.
+	_ = w.encoder.Encode(e)
+}
+
+// Phase reports the start of the provided phase, and returns the function
+// reporting its end, with the error it failed with, if any.
+func (w *Writer) Phase(phase string) func(error) {
+	start := time.Now()
+	w.write(Event{Type: PhaseEvent, Phase: phase, Status: Started})
+	return func(err error) {
+		w.write(ended(Event{Type: PhaseEvent, Phase: phase}, time.Since(start), err))
+	}
+}
+
+// ResourceStarted implements journal.Listener.
+func (w *Writer) ResourceStarted(id string) {
+	w.write(Event{Type: ResourceEvent, Resource: id, Status: Started})
+}
+
+// ResourceFinished implements journal.Listener.
+func (w *Writer) ResourceFinished(id string, status journal.Status, duration time.Duration, err error) {
+	e := ended(Event{Type: ResourceEvent, Resource: id}, duration, err)
+	e.Status = status
+	w.write(e)
+}
+
+func ended(e Event, duration time.Duration, err error) Event {
+	e.Status, e.Duration = journal.Succeeded, duration.Seconds()
+	if err != nil {
+		e.Status, e.Error = journal.Failed, err.Error()
+	}
+	return e
+}
@@ -0,0 +1,60 @@
+package events
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/weaveworks/wksctl/pkg/plan/journal"
+)
+
+func decode(t *testing.T, out *bytes.Buffer) []Event {
+	var decoded []Event
+	scanner := bufio.NewScanner(out)
+	for scanner.Scan() {
+		var e Event
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &e), scanner.Text())
+		assert.False(t, e.Time.IsZero())
+		e.Time = time.Time{}
+		decoded = append(decoded, e)
+	}
+	return decoded
+}
+
+func TestWriter(t *testing.T) {
+	var out bytes.Buffer
+	w := NewWriter(&out)
+
+	phaseDone := w.Phase(SSHConnect)
+	phaseDone(nil)
+	phaseDone = w.Phase(PlanApply)
+	w.ResourceStarted("install:base")
+	w.ResourceFinished("install:base", journal.Succeeded, 2*time.Second, nil)
+	w.ResourceStarted("install:docker")
+	w.ResourceFinished("install:docker", journal.Skipped, 0, nil)
+	w.ResourceStarted("kubeadm:init")
+	w.ResourceFinished("kubeadm:init", journal.Failed, 1500*time.Millisecond, errors.New("exit status 1"))
+	phaseDone(errors.New("apply failed"))
+
+	events := decode(t, &out)
+	require.Len(t, events, 10)
+	assert.Equal(t, Event{Type: PhaseEvent, Phase: SSHConnect, Status: Started}, events[0])
+	assert.Equal(t, journal.Succeeded, events[1].Status)
+	assert.Equal(t, Event{Type: ResourceEvent, Resource: "install:base", Status: journal.Succeeded, Duration: 2}, events[4])
+	assert.Equal(t, Event{Type: ResourceEvent, Resource: "install:docker", Status: journal.Skipped}, events[6])
+	assert.Equal(t, Event{Type: ResourceEvent, Resource: "kubeadm:init", Status: journal.Failed, Duration: 1.5, Error: "exit status 1"}, events[8])
+	assert.Equal(t, PlanApply, events[9].Phase)
+	assert.Equal(t, journal.Failed, events[9].Status)
+	assert.Equal(t, "apply failed", events[9].Error)
+}
+
+func TestValidateOutputFormat(t *testing.T) {
+	assert.NoError(t, ValidateOutputFormat(""))
+	assert.NoError(t, ValidateOutputFormat("json"))
+	assert.Error(t, ValidateOutputFormat("yaml"))
+}
@@ -0,0 +1,248 @@
+package journal
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"github.com/weaveworks/cluster-api-provider-existinginfra/pkg/plan"
+)
+
+// The journal of an apply records which resources of the seed node plan were
+// applied, so that a failed apply can be resumed from where it stopped rather
+// than from scratch. It is written locally and on the seed node itself after
+// every resource, so that an apply can be resumed from another machine.
+
+const version = 1
+
+// SeedNodePath is where the journal of "wksctl apply" is kept on the seed node.
+const SeedNodePath = "/var/lib/wksctl/apply-journal.json"
+
+// Status is the outcome of applying a resource.
+type Status string
+
+const (
+	Succeeded Status = "succeeded"
+	Failed    Status = "failed"
+	// Skipped is only reported to listeners, the journal doesn't record
+	// resources which didn't need to be applied.
+	Skipped Status = "skipped"
+)
+
+// Entry is the journal entry of a resource. The state of the resource is
+// recorded as a digest, since states may contain secrets.
+type Entry struct {
+	Status      Status    `json:"status"`
+	StateDigest string    `json:"stateDigest"`
+	Error       string    `json:"error,omitempty"`
+	Time        time.Time `json:"time"`
+}
+
+// Journal records the outcome of the resources of a plan.
+type Journal struct {
+	Version int `json:"version"`
+	// BootstrapToken is the token the plan was built with. It is part of the
+	// state of kubeadm's resources, which therefore only match their
+	// recorded state if the resumed plan is built with the same token.
+	BootstrapToken string           `json:"bootstrapToken,omitempty"`
+	Resources      map[string]Entry `json:"resources"`
+
+	mu     sync.Mutex
+	stores []Store
+}
+
+// New creates an empty journal, saved to the provided stores.
+func New(bootstrapToken string, stores ...Store) *Journal {
+	return &Journal{
+		Version:        version,
+		BootstrapToken: bootstrapToken,
+		Resources:      map[string]Entry{},
+		stores:         stores,
+	}
+}
+
+// Load reads the journal from the first of the provided stores holding one,
+// and saves it to all of them from then on.
+func Load(ctx context.Context, stores ...Store) (*Journal, error) {
+	for _, s := range stores {
+		data, err := s.Read(ctx)
+		if os.IsNotExist(errors.Cause(err)) {
+			continue
+		}
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to read journal from %s", s)
+		}
+		j := &Journal{}
+		if err := json.Unmarshal(data, j); err != nil {
+			return nil, errors.Wrapf(err, "failed to parse journal from %s", s)
+		}
+		if j.Version != version {
+			return nil, errors.Errorf("unsupported version %d of journal from %s", j.Version, s)
+		}
+		if j.Resources == nil {
+			j.Resources = map[string]Entry{}
+		}
+		j.stores = stores
+		log.Infof("Resuming from the journal read from %s", s)
+		return j, nil
+	}
+	return nil, errors.New("no journal to resume from")
+}
+
+// Succeeded returns true if the resource with the provided ID was applied
+// successfully with the provided state.
+func (j *Journal) Succeeded(id string, state plan.State) bool {
+	digest, err := Digest(state)
+	if err != nil {
+		return false
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	e, ok := j.Resources[id]
+	return ok && e.Status == Succeeded && e.StateDigest == digest
+}
+
+// Record records the outcome of applying the resource with the provided ID
+// and state, and saves the journal.
+func (j *Journal) Record(ctx context.Context, id string, state plan.State, applyErr error) {
+	e := Entry{Status: Succeeded, Time: time.Now().UTC()}
+	if applyErr != nil {
+		e.Status = Failed
+		e.Error = applyErr.Error()
+	}
+	digest, err := Digest(state)
+	if err != nil {
+		log.Warnf("Failed to compute the digest of the state of %s, it will be applied again if resuming: %v", id, err)
+	}
+	e.StateDigest = digest
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.Resources[id] = e
+	j.save(ctx)
+}
+
+// Save writes the journal to its stores.
+func (j *Journal) Save(ctx context.Context) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.save(ctx)
+}
+
+func (j *Journal) save(ctx context.Context) {
+	data, err := json.MarshalIndent(j, "", "  ")
+	if err != nil {
+		log.Warnf("Failed to serialize the journal: %v", err)
+		return
+	}
+	// Failing to save the journal only prevents resuming, so it doesn't fail
+	// the apply.
+	for _, s := range j.stores {
+		if err := s.Write(ctx, data); err != nil {
+			log.Warnf("Failed to write the journal to %s: %v", s, err)
+		}
+	}
+}
+
+// Digest returns a digest of the provided state.
+func Digest(state plan.State) (string, error) {
+	data, err := json.Marshal(normalize(map[string]interface{}(state)))
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// normalize replaces the fmt.Stringer values found in states, such as
+// plan.ParamString, by their current values, which wouldn't be serialized
+// otherwise.
+func normalize(v interface{}) interface{} {
+	switch value := v.(type) {
+	case plan.State:
+		return normalize(map[string]interface{}(value))
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(value))
+		for k, e := range value {
+			m[k] = normalize(e)
+		}
+		return m
+	case []interface{}:
+		s := make([]interface{}, len(value))
+		for i, e := range value {
+			s[i] = normalize(e)
+		}
+		return s
+	case fmt.Stringer:
+		return value.String()
+	default:
+		return v
+	}
+}
+
+// Store persists a journal.
+type Store interface {
+	fmt.Stringer
+	// Read returns the journal, or an error satisfying os.IsNotExist if there
+	// is none.
+	Read(ctx context.Context) ([]byte, error)
+	Write(ctx context.Context, data []byte) error
+}
+
+// FileStore stores the journal in a local file.
+type FileStore struct {
+	Path string
+}
+
+func (s *FileStore) String() string {
+	return s.Path
+}
+
+func (s *FileStore) Read(_ context.Context) ([]byte, error) {
+	return ioutil.ReadFile(s.Path)
+}
+
+func (s *FileStore) Write(_ context.Context, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(s.Path), 0700); err != nil {
+		return err
+	}
+	// The journal holds the bootstrap token.
+	return ioutil.WriteFile(s.Path, data, 0600)
+}
+
+// RemoteStore stores the journal in a file of the machine the runner runs
+// commands on.
+type RemoteStore struct {
+	Runner plan.Runner
+	Path   string
+}
+
+func (s *RemoteStore) String() string {
+	return fmt.Sprintf("seed node:%s", s.Path)
+}
+
+func (s *RemoteStore) Read(ctx context.Context) ([]byte, error) {
+	out, err := s.Runner.RunCommand(ctx, fmt.Sprintf("if [ -f %[1]q ]; then cat %[1]q; fi", s.Path), nil)
+	if err != nil {
+		return nil, err
+	}
+	if out == "" {
+		return nil, os.ErrNotExist
+	}
+	return []byte(out), nil
+}
+
+func (s *RemoteStore) Write(ctx context.Context, data []byte) error {
+	_, err := s.Runner.RunCommand(ctx, fmt.Sprintf("mkdir -p %q && umask 077 && cat > %q", filepath.Dir(s.Path), s.Path), bytes.NewReader(data))
+	return err
+}
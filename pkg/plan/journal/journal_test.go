@@ -0,0 +1,301 @@
+package journal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/weaveworks/cluster-api-provider-existinginfra/pkg/plan"
+	"github.com/weaveworks/cluster-api-provider-existinginfra/pkg/plan/resource"
+	"github.com/weaveworks/cluster-api-provider-existinginfra/pkg/utilities/object"
+)
+
+// step is a resource which is always applied, unless skipped, like most
+// resources of the seed node plan.
+type step struct {
+	resource.Base
+
+	Name    string `structs:"name"`
+	applied *[]string
+	fail    *bool
+}
+
+var _ plan.Resource = plan.RegisterResource(&step{})
+
+func (s *step) State() plan.State {
+	return resource.ToState(s)
+}
+
+func (s *step) Apply(_ context.Context, _ plan.Runner, _ plan.Diff) (bool, error) {
+	*s.applied = append(*s.applied, s.Name)
+	if s.fail != nil && *s.fail {
+		return false, errors.New("failed")
+	}
+	return true, nil
+}
+
+type memoryStore struct {
+	data []byte
+}
+
+func (s *memoryStore) String() string {
+	return "memory"
+}
+
+func (s *memoryStore) Read(_ context.Context) ([]byte, error) {
+	if s.data == nil {
+		return nil, os.ErrNotExist
+	}
+	return s.data, nil
+}
+
+func (s *memoryStore) Write(_ context.Context, data []byte) error {
+	s.data = data
+	return nil
+}
+
+func buildPlan(t *testing.T, applied *[]string, fail *bool) *plan.Plan {
+	nested := plan.NewBuilder()
+	nested.AddResource("nested:a", &step{Name: "nested:a", applied: applied})
+	nested.AddResource("nested:b", &step{Name: "nested:b", applied: applied, fail: fail}, plan.DependOn("nested:a"))
+	n, err := nested.Plan()
+	require.NoError(t, err)
+
+	b := plan.NewBuilder()
+	b.AddResource("first", &step{Name: "first", applied: applied})
+	b.AddResource("nested", &n, plan.DependOn("first"))
+	b.AddResource("last", &step{Name: "last", applied: applied}, plan.DependOn("nested"))
+	p, err := b.Plan()
+	require.NoError(t, err)
+	return &p
+}
+
+func TestResume(t *testing.T) {
+	ctx := context.Background()
+	store := &memoryStore{}
+	var applied []string
+	fail := true
+
+	j := New("abcdef.0123456789abcdef", store)
+	p, err := Wrap(buildPlan(t, &applied, &fail), j, false, nil)
+	require.NoError(t, err)
+	_, err = p.Apply(ctx, nil, plan.EmptyDiff())
+	assert.Error(t, err)
+	assert.Equal(t, []string{"first", "nested:a", "nested:b"}, applied)
+
+	j, err = Load(ctx, store)
+	require.NoError(t, err)
+	assert.Equal(t, "abcdef.0123456789abcdef", j.BootstrapToken)
+	assert.Equal(t, Succeeded, j.Resources["first"].Status)
+	assert.Equal(t, Succeeded, j.Resources["nested/nested:a"].Status)
+	assert.Equal(t, Failed, j.Resources["nested/nested:b"].Status)
+	assert.Equal(t, "failed", j.Resources["nested/nested:b"].Error)
+
+	applied = nil
+	fail = false
+	p, err = Wrap(buildPlan(t, &applied, &fail), j, true, nil)
+	require.NoError(t, err)
+	_, err = p.Apply(ctx, nil, plan.EmptyDiff())
+	require.NoError(t, err)
+	assert.Equal(t, []string{"nested:b", "last"}, applied)
+	assert.Equal(t, Succeeded, j.Resources["nested/nested:b"].Status)
+	assert.Equal(t, Succeeded, j.Resources["last"].Status)
+}
+
+type recordingListener struct {
+	events []string
+}
+
+func (l *recordingListener) ResourceStarted(id string) {
+	l.events = append(l.events, id+" started")
+}
+
+func (l *recordingListener) ResourceFinished(id string, status Status, _ time.Duration, err error) {
+	event := id + " " + string(status)
+	if err != nil {
+		event += ": " + err.Error()
+	}
+	l.events = append(l.events, event)
+}
+
+func TestListener(t *testing.T) {
+	ctx := context.Background()
+	var applied []string
+	fail := true
+	j := New("")
+
+	l := &recordingListener{}
+	p, err := Wrap(buildPlan(t, &applied, &fail), j, false, l)
+	require.NoError(t, err)
+	_, err = p.Apply(ctx, nil, plan.EmptyDiff())
+	assert.Error(t, err)
+	assert.Equal(t, []string{
+		"first started", "first succeeded",
+		"nested/nested:a started", "nested/nested:a succeeded",
+		"nested/nested:b started", "nested/nested:b failed: failed",
+	}, l.events)
+
+	fail = false
+	l = &recordingListener{}
+	p, err = Wrap(buildPlan(t, &applied, &fail), j, true, l)
+	require.NoError(t, err)
+	_, err = p.Apply(ctx, nil, plan.EmptyDiff())
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"first started", "first skipped",
+		"nested/nested:a started", "nested/nested:a skipped",
+		"nested/nested:b started", "nested/nested:b succeeded",
+		"last started", "last succeeded",
+	}, l.events)
+
+	// Resources skipped last are reported when the plan ends.
+	l = &recordingListener{}
+	p, err = Wrap(buildPlan(t, &applied, &fail), j, true, l)
+	require.NoError(t, err)
+	_, err = p.Apply(ctx, nil, plan.EmptyDiff())
+	require.NoError(t, err)
+	assert.Equal(t, "last skipped", l.events[len(l.events)-1])
+}
+
+func TestResumeAppliesChangedResources(t *testing.T) {
+	ctx := context.Background()
+	var applied []string
+	j := New("")
+
+	b := plan.NewBuilder()
+	b.AddResource("step", &step{Name: "before", applied: &applied})
+	p, err := b.Plan()
+	require.NoError(t, err)
+	wrapped, err := Wrap(&p, j, false, nil)
+	require.NoError(t, err)
+	_, err = wrapped.Apply(ctx, nil, plan.EmptyDiff())
+	require.NoError(t, err)
+
+	b = plan.NewBuilder()
+	b.AddResource("step", &step{Name: "after", applied: &applied})
+	p, err = b.Plan()
+	require.NoError(t, err)
+	wrapped, err = Wrap(&p, j, true, nil)
+	require.NoError(t, err)
+	_, err = wrapped.Apply(ctx, nil, plan.EmptyDiff())
+	require.NoError(t, err)
+	assert.Equal(t, []string{"before", "after"}, applied)
+}
+
+type echoRunner struct {
+	commands []string
+}
+
+func (r *echoRunner) RunCommand(_ context.Context, cmd string, _ io.Reader) (string, error) {
+	r.commands = append(r.commands, cmd)
+	return strings.TrimPrefix(cmd, "echo "), nil
+}
+
+func TestResumeAppliesRunsWithOutput(t *testing.T) {
+	ctx := context.Background()
+	j := New("")
+	build := func(output *string) *plan.Plan {
+		b := plan.NewBuilder()
+		b.AddResource("output", &resource.Run{Script: object.String("echo foo"), Output: output})
+		b.AddResource("no-output", &resource.Run{Script: object.String("echo bar")})
+		p, err := b.Plan()
+		require.NoError(t, err)
+		return &p
+	}
+
+	var output string
+	p, err := Wrap(build(&output), j, false, nil)
+	require.NoError(t, err)
+	r := &echoRunner{}
+	_, err = p.Apply(ctx, r, plan.EmptyDiff())
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"echo foo", "echo bar"}, r.commands)
+
+	var resumedOutput string
+	p, err = Wrap(build(&resumedOutput), j, true, nil)
+	require.NoError(t, err)
+	r = &echoRunner{}
+	_, err = p.Apply(ctx, r, plan.EmptyDiff())
+	require.NoError(t, err)
+	assert.Equal(t, []string{"echo foo"}, r.commands)
+	assert.Equal(t, "foo", resumedOutput)
+}
+
+func TestDigestUsesParamStringValues(t *testing.T) {
+	var param string
+	r := &resource.Run{Script: plan.ParamString("echo %s", &param)}
+	before, err := Digest(r.State())
+	require.NoError(t, err)
+	param = "foo"
+	after, err := Digest(r.State())
+	require.NoError(t, err)
+	assert.NotEqual(t, before, after)
+}
+
+func TestLoadWithoutJournal(t *testing.T) {
+	_, err := Load(context.Background(), &memoryStore{})
+	assert.Error(t, err)
+}
+
+func TestFileStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "wksctl-journal")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	ctx := context.Background()
+
+	s := &FileStore{Path: filepath.Join(dir, "cluster", "apply-journal.json")}
+	_, err = s.Read(ctx)
+	assert.True(t, os.IsNotExist(err))
+
+	require.NoError(t, s.Write(ctx, []byte("{}")))
+	info, err := os.Stat(s.Path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+	data, err := s.Read(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "{}", string(data))
+}
+
+type fileRunner struct {
+	files map[string]string
+}
+
+func (r *fileRunner) RunCommand(_ context.Context, cmd string, stdin io.Reader) (string, error) {
+	var path string
+	if _, err := fmt.Sscanf(cmd, "if [ -f %q ]", &path); err == nil {
+		return r.files[path], nil
+	}
+	if i := strings.Index(cmd, "cat > "); i >= 0 {
+		if _, err := fmt.Sscanf(cmd[i:], "cat > %q", &path); err != nil {
+			return "", err
+		}
+		data, err := ioutil.ReadAll(stdin)
+		if err != nil {
+			return "", err
+		}
+		r.files[path] = string(data)
+		return "", nil
+	}
+	return "", fmt.Errorf("unexpected command %q", cmd)
+}
+
+func TestRemoteStore(t *testing.T) {
+	ctx := context.Background()
+	s := &RemoteStore{Runner: &fileRunner{files: map[string]string{}}, Path: "/var/lib/wksctl/apply-journal.json"}
+	_, err := s.Read(ctx)
+	assert.True(t, os.IsNotExist(err))
+
+	require.NoError(t, s.Write(ctx, []byte("{}")))
+	data, err := s.Read(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "{}", string(data))
+}
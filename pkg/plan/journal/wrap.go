@@ -0,0 +1,248 @@
+package journal
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"github.com/weaveworks/cluster-api-provider-existinginfra/pkg/plan"
+	"github.com/weaveworks/cluster-api-provider-existinginfra/pkg/plan/resource"
+)
+
+// Wrap returns a copy of the provided plan whose resources record their
+// outcome in the journal. Resources of nested plans are recorded under the
+// IDs of their enclosing plans and their own ID, separated by slashes.
+//
+// If resume is true, resources which the journal records as successfully
+// applied with their current state are considered applied, and skipped.
+//
+// The listener, if not nil, is notified of the progress of the resources as
+// the returned plan is applied.
+//
+// The returned plan is meant to be applied only: undo conditions of nested
+// plans aren't copied, so the original plan should be undone instead.
+func Wrap(p *plan.Plan, j *Journal, resume bool, l Listener) (*Plan, error) {
+	progress := &progress{listener: l}
+	wrapped, err := wrap(p, "", j, resume, progress)
+	if err != nil {
+		return nil, err
+	}
+	return &Plan{Plan: wrapped, progress: progress}, nil
+}
+
+// Plan is a plan wrapped by Wrap.
+type Plan struct {
+	*plan.Plan
+	progress *progress
+}
+
+// Apply implements plan.Resource.
+func (p *Plan) Apply(ctx context.Context, runner plan.Runner, diff plan.Diff) (bool, error) {
+	propagate, err := p.Plan.Apply(ctx, runner, diff)
+	p.progress.done()
+	return propagate, err
+}
+
+func wrap(p *plan.Plan, prefix string, j *Journal, resume bool, progress *progress) (*plan.Plan, error) {
+	b := plan.NewBuilder()
+	for id, entry := range p.ToState() {
+		var deps []string
+		if meta, ok := entry.(map[string]interface{})["meta"].(map[string]interface{}); ok {
+			deps, _ = meta["dependsOn"].([]string)
+		}
+		var r plan.Resource = p.GetResource(id)
+		if nested, ok := r.(*plan.Plan); ok {
+			wrapped, err := wrap(nested, prefix+id+"/", j, resume, progress)
+			if err != nil {
+				return nil, err
+			}
+			r = &nestedPlan{Plan: wrapped, progress: progress}
+		} else {
+			r = &journaled{id: prefix + id, resource: r, journal: j, resume: resume, progress: progress}
+		}
+		if len(deps) > 0 {
+			b.AddResource(id, r, plan.DependOn(deps[0], deps[1:]...))
+		} else {
+			b.AddResource(id, r)
+		}
+	}
+	wrapped, err := b.Plan()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to wrap plan")
+	}
+	return &wrapped, nil
+}
+
+// journaled wraps a resource to record the outcome of its application in a
+// journal.
+type journaled struct {
+	id       string
+	resource plan.Resource
+	journal  *Journal
+	resume   bool
+	progress *progress
+}
+
+var _ plan.Resource = plan.RegisterResource(&journaled{})
+
+// State implements plan.Resource.
+func (r *journaled) State() plan.State {
+	return r.resource.State()
+}
+
+// QueryState implements plan.Resource. When resuming, the state of resources
+// recorded as applied is their desired state, so that they are skipped.
+func (r *journaled) QueryState(ctx context.Context, runner plan.Runner) (plan.State, error) {
+	r.progress.started(r.id)
+	if r.resume && skippable(r.resource) {
+		state := r.resource.State()
+		if r.journal.Succeeded(r.id, state) {
+			log.WithField("resource", r.id).Info("Skipping (already applied)")
+			return state, nil
+		}
+	}
+	state, err := r.resource.QueryState(ctx, runner)
+	if err != nil {
+		r.progress.finished(r.id, Failed, err)
+	}
+	return state, err
+}
+
+// Apply implements plan.Resource.
+func (r *journaled) Apply(ctx context.Context, runner plan.Runner, diff plan.Diff) (bool, error) {
+	propagate, err := r.resource.Apply(ctx, runner, diff)
+	r.journal.Record(ctx, r.id, r.resource.State(), err)
+	status := Succeeded
+	if err != nil {
+		status = Failed
+	}
+	r.progress.finished(r.id, status, err)
+	return propagate, err
+}
+
+// Undo implements plan.Resource.
+func (r *journaled) Undo(ctx context.Context, runner plan.Runner, current plan.State) error {
+	return r.resource.Undo(ctx, runner, current)
+}
+
+// nestedPlan wraps a nested plan, to report the progress of its resources.
+type nestedPlan struct {
+	*plan.Plan
+	progress *progress
+}
+
+var _ plan.Resource = plan.RegisterResource(&nestedPlan{})
+
+// QueryState implements plan.Resource. The state of the resources is queried
+// to know whether the plan needs to be applied, which isn't their processing.
+func (p *nestedPlan) QueryState(ctx context.Context, runner plan.Runner) (plan.State, error) {
+	p.progress.mute()
+	defer p.progress.unmute()
+	return p.Plan.QueryState(ctx, runner)
+}
+
+// Apply implements plan.Resource. Plans pass the state of their resources
+// down to nested plans, whose resources then don't query it. It is dropped
+// when progress is reported, for the resources to query it again when they
+// are processed, which is what is reported as their start.
+func (p *nestedPlan) Apply(ctx context.Context, runner plan.Runner, diff plan.Diff) (bool, error) {
+	if p.progress.listener != nil {
+		diff.CurrentState = nil
+	}
+	return p.Plan.Apply(ctx, runner, diff)
+}
+
+// skippable returns false for resources which must be applied even if they
+// were applied already, such as commands whose output is used by later
+// resources.
+func skippable(r plan.Resource) bool {
+	if run, ok := r.(*resource.Run); ok && run.Output != nil {
+		return false
+	}
+	return true
+}
+
+// Listener is notified of the progress of the resources of a plan wrapped by
+// Wrap, identified like in the journal.
+type Listener interface {
+	// ResourceStarted is called when the resource starts being processed.
+	ResourceStarted(id string)
+	// ResourceFinished is called when the resource was applied, failed, or
+	// was skipped as it already was in its desired state.
+	ResourceFinished(id string, status Status, duration time.Duration, err error)
+}
+
+// progress follows the resources being processed, to notify the listener.
+// Plans process resources one at a time, querying their state and applying
+// them if it isn't their desired state: a resource is skipped if the next one
+// starts, or the plan ends, without it being applied.
+type progress struct {
+	listener Listener
+
+	mu      sync.Mutex
+	pending string
+	start   time.Time
+	// muted is set while the state of nested plans is queried.
+	muted int
+}
+
+func (p *progress) mute() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.muted++
+}
+
+func (p *progress) unmute() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.muted--
+}
+
+func (p *progress) started(id string) {
+	if p.listener == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.muted > 0 {
+		return
+	}
+	p.skipPending()
+	p.pending, p.start = id, time.Now()
+	p.listener.ResourceStarted(id)
+}
+
+func (p *progress) finished(id string, status Status, err error) {
+	if p.listener == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.muted > 0 {
+		return
+	}
+	var duration time.Duration
+	if p.pending == id {
+		duration = time.Since(p.start)
+		p.pending = ""
+	}
+	p.listener.ResourceFinished(id, status, duration, err)
+}
+
+func (p *progress) done() {
+	if p.listener == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.skipPending()
+}
+
+func (p *progress) skipPending() {
+	if p.pending != "" {
+		p.listener.ResourceFinished(p.pending, Skipped, time.Since(p.start), nil)
+		p.pending = ""
+	}
+}
@@ -0,0 +1,61 @@
+package recipe
+
+import (
+	"path/filepath"
+
+	log "github.com/sirupsen/logrus"
+	capeios "github.com/weaveworks/cluster-api-provider-existinginfra/pkg/apis/wksprovider/machine/os"
+	"github.com/weaveworks/cluster-api-provider-existinginfra/pkg/plan"
+	capeiresource "github.com/weaveworks/cluster-api-provider-existinginfra/pkg/plan/resource"
+	"github.com/weaveworks/cluster-api-provider-existinginfra/pkg/utilities/object"
+	"github.com/weaveworks/wksctl/pkg/plan/journal"
+	"github.com/weaveworks/wksctl/pkg/plan/resource"
+)
+
+const (
+	kubeadmResetScript = "if command -v kubeadm >/dev/null 2>&1; then kubeadm reset --force; fi"
+
+	// cniResetScript removes the configuration, state and network interfaces
+	// of the CNI plugins wksctl clusters commonly run.
+	cniResetScript = `rm -rf /etc/cni/net.d /var/lib/cni /var/lib/weave /var/lib/calico /run/flannel
+for link in weave datapath vxlan-6784 vxlan-6789 cni0 tunl0 vxlan.calico flannel.1 cilium_host cilium_net cilium_vxlan; do
+  ip link delete "$link" 2>/dev/null || true
+done`
+)
+
+// BuildResetPlan creates a plan whose undo tears down what setting up a node
+// of a cluster running the provided version of Kubernetes did: the node is
+// reset by kubeadm, and the CNI state, the files created by kubeadm init and
+// the configuration files installed by wksctl are removed. The plan is only
+// meant to be undone.
+func BuildResetPlan(kubernetesVersion string) plan.Resource {
+	b := plan.NewBuilder()
+	b.AddResource(
+		"dir:wksctl-config",
+		&capeiresource.Dir{Path: object.String(capeios.ConfigDestDir), RecursiveDelete: true},
+	).AddResource(
+		"dir:wksctl-journal",
+		&capeiresource.Dir{Path: object.String(filepath.Dir(journal.SeedNodePath)), RecursiveDelete: true},
+	).AddResource(
+		"file:kubeconfig",
+		&capeiresource.Run{Script: object.String("echo no operation"), UndoScript: object.String("rm -f $HOME/.kube/config")},
+	).AddResource(
+		"kubeadm:init",
+		&resource.KubeadmInit{KubernetesVersion: kubernetesVersion},
+		plan.DependOn("dir:wksctl-config", "dir:wksctl-journal", "file:kubeconfig"),
+	).AddResource(
+		"cni:state",
+		&capeiresource.Run{Script: object.String("echo no operation"), UndoScript: object.String(cniResetScript)},
+		plan.DependOn("kubeadm:init"),
+	).AddResource(
+		// Undone first, while kubeadm's files it relies on are still there.
+		"kubeadm:reset",
+		&capeiresource.Run{Script: object.String("echo no operation"), UndoScript: object.String(kubeadmResetScript)},
+		plan.DependOn("cni:state"),
+	)
+	p, err := b.Plan()
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+	return &p
+}
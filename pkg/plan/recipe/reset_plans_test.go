@@ -0,0 +1,52 @@
+package recipe
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/weaveworks/cluster-api-provider-existinginfra/pkg/plan"
+)
+
+type recordingRunner struct {
+	commands []string
+}
+
+func (r *recordingRunner) RunCommand(_ context.Context, cmd string, _ io.Reader) (string, error) {
+	r.commands = append(r.commands, cmd)
+	return "", nil
+}
+
+func indexOf(commands []string, substr string) int {
+	for i, cmd := range commands {
+		if strings.Contains(cmd, substr) {
+			return i
+		}
+	}
+	return -1
+}
+
+func TestResetPlanUndo(t *testing.T) {
+	r := &recordingRunner{}
+	require.NoError(t, BuildResetPlan("1.18.15").Undo(context.Background(), r, plan.EmptyState))
+
+	reset := indexOf(r.commands, "kubeadm reset --force")
+	cni := indexOf(r.commands, "/etc/cni/net.d")
+	etcd := indexOf(r.commands, `rm -rvf -- "/var/lib/etcd"`)
+	manifests := indexOf(r.commands, "rm -f /etc/kubernetes/manifests/kube-apiserver.yaml")
+	config := indexOf(r.commands, `rm -rvf -- "/etc/pki/weaveworks/wksctl"`)
+	journal := indexOf(r.commands, `rm -rvf -- "/var/lib/wksctl"`)
+	kubeconfig := indexOf(r.commands, "rm -f $HOME/.kube/config")
+
+	for _, i := range []int{reset, cni, etcd, manifests, config, journal, kubeconfig} {
+		assert.NotEqual(t, -1, i, "missing command in %v", r.commands)
+	}
+	assert.Less(t, reset, cni)
+	assert.Less(t, cni, etcd)
+	assert.Less(t, cni, manifests)
+	assert.Less(t, etcd, config)
+	assert.Less(t, etcd, journal)
+}
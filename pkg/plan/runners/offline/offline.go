@@ -0,0 +1,103 @@
+package offline
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/weaveworks/cluster-api-provider-existinginfra/pkg/plan"
+)
+
+// Runner is a plan.Runner which never reaches any machine. It answers the
+// commands run while building a plan with the canned outputs of a freshly
+// installed machine running the declared operating system, so that plans can
+// be rendered without SSH access to the cluster.
+type Runner struct {
+	machine machine
+}
+
+type machine struct {
+	osRelease   string
+	seLinuxMode string // empty if SELinux isn't installed
+}
+
+var machines = map[string]machine{
+	"centos7": {
+		osRelease:   "NAME=\"CentOS Linux\"\nVERSION=\"7 (Core)\"\nID=\"centos\"\nID_LIKE=\"rhel fedora\"\nVERSION_ID=\"7\"\n",
+		seLinuxMode: "enforcing",
+	},
+	"ubuntu18.04": {
+		osRelease: "NAME=\"Ubuntu\"\nVERSION=\"18.04.5 LTS (Bionic Beaver)\"\nID=ubuntu\nID_LIKE=debian\nVERSION_ID=\"18.04\"\n",
+	},
+	"rhel8": {
+		osRelease:   "NAME=\"Red Hat Enterprise Linux\"\nVERSION=\"8.3 (Ootpa)\"\nID=\"rhel\"\nID_LIKE=\"fedora\"\nVERSION_ID=\"8.3\"\n",
+		seLinuxMode: "enforcing",
+	},
+}
+
+// machineID is returned for both the machine ID and the system UUID.
+const machineID = "00000000000000000000000000000000"
+
+const sudoPrefix = "sudo -n -- sh -c "
+
+// SupportedOSes lists the operating systems NewRunner accepts.
+func SupportedOSes() []string {
+	oses := make([]string, 0, len(machines))
+	for name := range machines {
+		oses = append(oses, name)
+	}
+	sort.Strings(oses)
+	return oses
+}
+
+// NewRunner creates a Runner pretending to run on the provided operating
+// system, e.g. "centos7".
+func NewRunner(osName string) (*Runner, error) {
+	m, ok := machines[osName]
+	if !ok {
+		return nil, errors.Errorf("unsupported operating system %q, expected one of: %s", osName, strings.Join(SupportedOSes(), ", "))
+	}
+	return &Runner{machine: m}, nil
+}
+
+// RunCommand implements plan.Runner.
+func (r *Runner) RunCommand(_ context.Context, cmd string, _ io.Reader) (stdouterr string, err error) {
+	cmd = unwrapSudo(cmd)
+	switch {
+	case cmd == "cat /etc/*release":
+		return r.machine.osRelease, nil
+	case cmd == "cat /proc/1/environ":
+		return "", nil
+	case strings.HasPrefix(cmd, "cat /etc/machine-id"), strings.HasPrefix(cmd, "cat /sys/class/dmi/id/product_uuid"):
+		return machineID, nil
+	case cmd == `command -v -- "selinuxenabled" >/dev/null 2>&1`, cmd == "selinuxenabled":
+		if r.machine.seLinuxMode == "" {
+			return "", &plan.RunError{ExitCode: 1}
+		}
+		return "", nil
+	case strings.HasPrefix(cmd, "sestatus | grep 'Current mode' | grep "):
+		if strings.TrimPrefix(cmd, "sestatus | grep 'Current mode' | grep ") != r.machine.seLinuxMode {
+			return "", &plan.RunError{ExitCode: 1}
+		}
+		return "", nil
+	case strings.HasPrefix(cmd, "curl -s http://169.254.169.254/latest/meta-data/local-hostname"):
+		return "localhost.localdomain", nil
+	default:
+		return "", fmt.Errorf("cannot run %q offline", cmd)
+	}
+}
+
+// unwrapSudo reverts the wrapping of commands done by the sudo runner.
+func unwrapSudo(cmd string) string {
+	if !strings.HasPrefix(cmd, sudoPrefix) {
+		return cmd
+	}
+	quoted := strings.TrimPrefix(cmd, sudoPrefix)
+	if len(quoted) < 2 || quoted[0] != '\'' || quoted[len(quoted)-1] != '\'' {
+		return cmd
+	}
+	return strings.ReplaceAll(quoted[1:len(quoted)-1], `'"'"'`, "'")
+}
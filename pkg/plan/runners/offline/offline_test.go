@@ -0,0 +1,72 @@
+package offline
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	capeios "github.com/weaveworks/cluster-api-provider-existinginfra/pkg/apis/wksprovider/machine/os"
+	"github.com/weaveworks/cluster-api-provider-existinginfra/pkg/plan/resource"
+	"github.com/weaveworks/cluster-api-provider-existinginfra/pkg/utilities/envcfg"
+)
+
+func TestIdentify(t *testing.T) {
+	for _, tt := range []struct {
+		os          string
+		wantName    string
+		wantPkgType resource.PkgType
+	}{
+		{"centos7", capeios.CentOS, resource.PkgTypeRPM},
+		{"ubuntu18.04", capeios.Ubuntu, resource.PkgTypeDeb},
+		{"rhel8", capeios.RHEL, resource.PkgTypeRHEL},
+	} {
+		t.Run(tt.os, func(t *testing.T) {
+			r, err := NewRunner(tt.os)
+			require.NoError(t, err)
+			installer, err := capeios.Identify(context.Background(), r)
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantName, installer.Name)
+			assert.Equal(t, tt.wantPkgType, installer.PkgType)
+		})
+	}
+}
+
+func TestEnvSpecificConfig(t *testing.T) {
+	ctx := context.Background()
+
+	r, err := NewRunner("centos7")
+	require.NoError(t, err)
+	installer, err := capeios.Identify(ctx, r)
+	require.NoError(t, err)
+	cfg, err := envcfg.GetEnvSpecificConfig(ctx, installer.PkgType, "weavek8sops", "aws", installer.Runner)
+	require.NoError(t, err)
+	assert.True(t, cfg.SELinuxInstalled)
+	assert.True(t, cfg.SetSELinuxPermissive)
+	assert.Equal(t, "localhost.localdomain", cfg.HostnameOverride)
+
+	r, err = NewRunner("ubuntu18.04")
+	require.NoError(t, err)
+	cfg, err = envcfg.GetEnvSpecificConfig(ctx, resource.PkgTypeDeb, "weavek8sops", "", r)
+	require.NoError(t, err)
+	assert.False(t, cfg.SELinuxInstalled)
+}
+
+func TestNewRunnerUnsupportedOS(t *testing.T) {
+	_, err := NewRunner("windows")
+	assert.Error(t, err)
+}
+
+func TestUnsupportedCommand(t *testing.T) {
+	r, err := NewRunner("centos7")
+	require.NoError(t, err)
+	_, err = r.RunCommand(context.Background(), "yum install -y docker-ce", nil)
+	assert.Error(t, err)
+}
+
+func TestUnwrapSudo(t *testing.T) {
+	assert.Equal(t, "cat /etc/*release", unwrapSudo("cat /etc/*release"))
+	assert.Equal(t, "cat /etc/*release", unwrapSudo(`sudo -n -- sh -c 'cat /etc/*release'`))
+	assert.Equal(t, "sestatus | grep 'Current mode' | grep permissive",
+		unwrapSudo(`sudo -n -- sh -c 'sestatus | grep '"'"'Current mode'"'"' | grep permissive'`))
+}
@@ -0,0 +1,250 @@
+package ssh
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"github.com/weaveworks/cluster-api-provider-existinginfra/pkg/plan"
+	"golang.org/x/crypto/ssh"
+)
+
+// ClientParams groups inputs to build a client object.
+type ClientParams struct {
+	User           string
+	Host           string
+	Port           uint16
+	PrivateKeyPath string
+	PrivateKey     []byte
+	// PassphraseFile is the path to a file holding the passphrase of the
+	// private key, which is prompted for if it is needed and not provided.
+	PassphraseFile string
+	// JumpHost, if set, is the host the connection to the machine is
+	// tunnelled through.
+	JumpHost *JumpHost
+	// HostKeyPolicy is the policy the host keys of the machine and jump host
+	// are checked with, accept-new if empty.
+	HostKeyPolicy string
+	// KnownHostsPath is the path to the known hosts, ~/.ssh/known_hosts if
+	// empty.
+	KnownHostsPath string
+	// KeepKnownHosts, if set, leaves the known hosts as they are: the keys
+	// accepted on first contact aren't added to them.
+	KeepKnownHosts bool
+	// HostKey, if set, is the key the machine must present, instead of the one
+	// in the known hosts.
+	HostKey ssh.PublicKey
+	// HostKeyAccepted, if set, is called with the key of the machine when it is
+	// accepted on first contact.
+	HostKeyAccepted func(ssh.PublicKey) error
+	PrintOutputs    bool
+}
+
+// Client runs commands on a machine by SSH. Unlike the provider's client, it
+// can reach machines through a jump host.
+type Client struct {
+	client *ssh.Client
+	// jumpClient is the connection to the jump host, if any.
+	jumpClient *ssh.Client
+	// agentConns are the connections to the SSH agent signing in to the
+	// machine and jump host.
+	agentConns   []io.Closer
+	printOutputs bool
+}
+
+var _ plan.Runner = &Client{}
+
+const tcp = "tcp"
+
+// NewClient instantiates a new SSH Client object.
+// N.B.: provide either the key (privateKey) or its path (privateKeyPath).
+func NewClient(params ClientParams) (*Client, error) {
+	log.WithFields(log.Fields{"user": params.User, "host": params.Host, "port": params.Port, "privateKeyPath": params.PrivateKeyPath, "jumpHost": params.JumpHost, "printOutputs": params.PrintOutputs}).Infof("creating SSH client")
+	hostKeyCallback, err := hostKeyCallback(params.HostKeyPolicy, params.KnownHostsPath, params.KeepKnownHosts, params.HostKey, params.HostKeyAccepted)
+	if err != nil {
+		return nil, err
+	}
+	config, agentConn, err := clientConfig(params.User, params.PrivateKeyPath, params.PrivateKey, params.PassphraseFile, hostKeyCallback)
+	if err != nil {
+		return nil, err
+	}
+	c := &Client{printOutputs: params.PrintOutputs}
+	c.addAgentConn(agentConn)
+	hostPort := fmt.Sprintf("%s:%d", params.Host, params.Port)
+
+	if params.JumpHost == nil {
+		c.client, err = ssh.Dial(tcp, hostPort, config)
+		if err != nil {
+			c.Close()
+			return nil, errors.Wrapf(err,
+				"failed to connect to %s using private key %s as user %s, please verify connection manually", hostPort, params.PrivateKeyPath, config.User)
+		}
+		return c, nil
+	}
+
+	c.jumpClient, agentConn, err = dialJumpHost(params)
+	c.addAgentConn(agentConn)
+	if err != nil {
+		c.Close()
+		return nil, err
+	}
+	conn, err := c.jumpClient.Dial(tcp, hostPort)
+	if err != nil {
+		c.Close()
+		return nil, errors.Wrapf(err, "failed to reach %s through jump host %s", hostPort, params.JumpHost)
+	}
+	clientConn, chans, reqs, err := ssh.NewClientConn(conn, hostPort, config)
+	if err != nil {
+		conn.Close()
+		c.Close()
+		return nil, errors.Wrapf(err,
+			"failed to connect to %s through jump host %s using private key %s as user %s, please verify connection manually", hostPort, params.JumpHost, params.PrivateKeyPath, config.User)
+	}
+	c.client = ssh.NewClient(clientConn, chans, reqs)
+	return c, nil
+}
+
+func (c *Client) addAgentConn(conn io.Closer) {
+	if conn != nil {
+		c.agentConns = append(c.agentConns, conn)
+	}
+}
+
+// dialJumpHost connects to the jump host of the provided parameters, with its
+// own key if it has one, or with the key of the machines otherwise. Its host
+// key is checked against the known hosts. The connection to the SSH agent, if
+// any, is returned too.
+func dialJumpHost(params ClientParams) (*ssh.Client, io.Closer, error) {
+	jumpHost, privateKeyPath, privateKey := params.JumpHost, params.PrivateKeyPath, params.PrivateKey
+	if jumpHost.PrivateKeyPath != "" {
+		privateKeyPath, privateKey = jumpHost.PrivateKeyPath, nil
+	}
+	hostKeyCallback, err := hostKeyCallback(params.HostKeyPolicy, params.KnownHostsPath, params.KeepKnownHosts, nil, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	config, agentConn, err := clientConfig(jumpHost.User, privateKeyPath, privateKey, params.PassphraseFile, hostKeyCallback)
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "jump host %s", jumpHost)
+	}
+	hostPort := fmt.Sprintf("%s:%d", jumpHost.Host, jumpHost.Port)
+	client, err := ssh.Dial(tcp, hostPort, config)
+	if err != nil {
+		return nil, agentConn, errors.Wrapf(err,
+			"failed to connect to jump host %s using private key %s as user %s, please verify connection manually", hostPort, privateKeyPath, config.User)
+	}
+	return client, agentConn, nil
+}
+
+func clientConfig(user, privateKeyPath string, privateKey []byte, passphraseFile string, hostKeyCallback ssh.HostKeyCallback) (*ssh.ClientConfig, io.Closer, error) {
+	auth, agentConn, err := authMethod(privateKeyPath, privateKey, passphraseFile)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &ssh.ClientConfig{
+		User: user,
+		Auth: []ssh.AuthMethod{
+			auth,
+		},
+		HostKeyCallback: hostKeyCallback,
+	}, agentConn, nil
+}
+
+// RunCommand executes the provided command on the remote machine configured in
+// this Client object. A new Session is created for each call to RunCommand.
+// A Client supports multiple interactive sessions.
+func (c *Client) RunCommand(ctx context.Context, command string, stdin io.Reader) (string, error) {
+	log.Debugf("running command: %s", command)
+	return c.handleSessionIO(func(session *ssh.Session) error {
+		session.Stdin = stdin
+		return session.Start(command)
+	})
+}
+
+// Handle output and command completion for a remote shell
+func (c *Client) handleSessionIO(action func(*ssh.Session) error) (string, error) {
+	session, err := c.client.NewSession()
+	if err != nil {
+		return "", errors.Wrap(err, "failed to create new SSH session")
+	}
+	defer session.Close()
+	// Write stdout and stderr to both this process' stdout and stderr, and
+	// buffers, for later re-use.
+	stdOutPipe, err := session.StdoutPipe()
+	if err != nil {
+		return "", errors.Wrap(err, "failed to get pipe to standard output")
+	}
+	stdErrPipe, err := session.StderrPipe()
+	if err != nil {
+		return "", errors.Wrap(err, "failed to get pipe to standard error")
+	}
+	var stdOutErr bytes.Buffer
+	outWriters := []io.Writer{&stdOutErr}
+	errWriters := []io.Writer{&stdOutErr}
+	if c.printOutputs {
+		outWriters = append(outWriters, os.Stdout)
+		errWriters = append(errWriters, os.Stderr)
+	}
+	stdOutWriter := io.MultiWriter(outWriters...)
+	stdErrWriter := io.MultiWriter(errWriters...)
+
+	err = action(session)
+
+	// Don't respond to err until output complete
+	var errStdOut, errStdErr error
+	syncChan := make(chan bool)
+	go func() {
+		_, errStdOut = io.Copy(stdOutWriter, stdOutPipe)
+		syncChan <- true
+	}()
+	go func() {
+		_, errStdErr = io.Copy(stdErrWriter, stdErrPipe)
+		syncChan <- true
+	}()
+
+	// Make sure copying is finished
+	<-syncChan
+	<-syncChan
+
+	// Now we can return the error
+	if err != nil {
+		return stdOutErr.String(), errors.Wrap(err, "failed while remote executing")
+	}
+
+	if err := session.Wait(); err != nil {
+		if err, ok := err.(*ssh.ExitError); ok {
+			return stdOutErr.String(), &plan.RunError{ExitCode: err.ExitStatus()}
+		}
+		return stdOutErr.String(), errors.Wrap(err, "failed while waiting for end of remote execution")
+	}
+
+	if errStdOut != nil {
+		return stdOutErr.String(), errors.Wrap(errStdOut, "failed while capturing stdout")
+	}
+	if errStdErr != nil {
+		return stdOutErr.String(), errors.Wrap(errStdErr, "failed while capturing stderr")
+	}
+	return stdOutErr.String(), nil
+}
+
+// Close closes this high-level Client's underlying SSH connection, and the
+// connection to the jump host, if any.
+func (c *Client) Close() error {
+	var err error
+	if c.client != nil {
+		err = c.client.Close()
+	}
+	if c.jumpClient != nil {
+		if jumpErr := c.jumpClient.Close(); err == nil {
+			err = jumpErr
+		}
+	}
+	for _, conn := range c.agentConns {
+		conn.Close()
+	}
+	return err
+}
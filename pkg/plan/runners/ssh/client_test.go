@@ -0,0 +1,185 @@
+package ssh
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/binary"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ssh"
+)
+
+// server is an SSH server accepting the provided key, which answers commands
+// with their own text, and forwards connections if it is a jump host.
+type server struct {
+	listener  net.Listener
+	hostKey   ssh.PublicKey
+	forwarded []string
+}
+
+func newServer(t *testing.T, authorized ssh.PublicKey) *server {
+	_, hostKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	hostSigner, err := ssh.NewSignerFromKey(hostKey)
+	require.NoError(t, err)
+	config := &ssh.ServerConfig{
+		PublicKeyCallback: func(_ ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			if string(key.Marshal()) != string(authorized.Marshal()) {
+				return nil, fmt.Errorf("unauthorized key")
+			}
+			return nil, nil
+		},
+	}
+	config.AddHostKey(hostSigner)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	s := &server{listener: listener, hostKey: hostSigner.PublicKey()}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn, config)
+		}
+	}()
+	return s
+}
+
+func (s *server) port() uint16 {
+	return uint16(s.listener.Addr().(*net.TCPAddr).Port)
+}
+
+func (s *server) serve(conn net.Conn, config *ssh.ServerConfig) {
+	_, chans, reqs, err := ssh.NewServerConn(conn, config)
+	if err != nil {
+		return
+	}
+	go ssh.DiscardRequests(reqs)
+	for newChannel := range chans {
+		switch newChannel.ChannelType() {
+		case "session":
+			go s.session(newChannel)
+		case "direct-tcpip":
+			go s.forward(newChannel)
+		default:
+			_ = newChannel.Reject(ssh.UnknownChannelType, "unsupported")
+		}
+	}
+}
+
+func (s *server) session(newChannel ssh.NewChannel) {
+	channel, requests, err := newChannel.Accept()
+	if err != nil {
+		return
+	}
+	defer channel.Close()
+	for req := range requests {
+		if req.Type != "exec" {
+			_ = req.Reply(false, nil)
+			continue
+		}
+		_ = req.Reply(true, nil)
+		var payload struct{ Command string }
+		_ = ssh.Unmarshal(req.Payload, &payload)
+		fmt.Fprintf(channel, "ran %s", payload.Command)
+		status := make([]byte, 4)
+		binary.BigEndian.PutUint32(status, 0)
+		_, _ = channel.SendRequest("exit-status", false, status)
+		return
+	}
+}
+
+func (s *server) forward(newChannel ssh.NewChannel) {
+	var target struct {
+		Host       string
+		Port       uint32
+		OriginHost string
+		OriginPort uint32
+	}
+	if err := ssh.Unmarshal(newChannel.ExtraData(), &target); err != nil {
+		_ = newChannel.Reject(ssh.ConnectionFailed, err.Error())
+		return
+	}
+	hostPort := net.JoinHostPort(target.Host, strconv.Itoa(int(target.Port)))
+	conn, err := net.Dial("tcp", hostPort)
+	if err != nil {
+		_ = newChannel.Reject(ssh.ConnectionFailed, err.Error())
+		return
+	}
+	s.forwarded = append(s.forwarded, hostPort)
+	channel, requests, err := newChannel.Accept()
+	if err != nil {
+		conn.Close()
+		return
+	}
+	go ssh.DiscardRequests(requests)
+	go func() {
+		_, _ = io.Copy(conn, channel)
+		conn.Close()
+	}()
+	_, _ = io.Copy(channel, conn)
+	channel.Close()
+}
+
+func writeKey(t *testing.T, dir, name string) (string, ssh.PublicKey) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	signer, err := ssh.NewSignerFromKey(key)
+	require.NoError(t, err)
+	der, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+	path := filepath.Join(dir, name)
+	require.NoError(t, ioutil.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der}), 0600))
+	return path, signer.PublicKey()
+}
+
+func TestClientThroughJumpHost(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ssh")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	// No known hosts.
+	home := os.Getenv("HOME")
+	os.Setenv("HOME", dir)
+	defer os.Setenv("HOME", home)
+
+	machineKey, machinePublicKey := writeKey(t, dir, "machine-key")
+	jumpKey, jumpPublicKey := writeKey(t, dir, "jump-key")
+	machine := newServer(t, machinePublicKey)
+	defer machine.listener.Close()
+	jump := newServer(t, jumpPublicKey)
+	defer jump.listener.Close()
+
+	// The jump host only accepts its own key.
+	_, err = NewClient(ClientParams{
+		User: "root", Host: "127.0.0.1", Port: machine.port(), PrivateKeyPath: machineKey,
+		JumpHost: &JumpHost{User: "bastion", Host: "127.0.0.1", Port: jump.port()},
+	})
+	assert.Error(t, err)
+
+	client, err := NewClient(ClientParams{
+		User: "root", Host: "127.0.0.1", Port: machine.port(), PrivateKeyPath: machineKey,
+		JumpHost: &JumpHost{User: "bastion", Host: "127.0.0.1", Port: jump.port(), PrivateKeyPath: jumpKey},
+	})
+	require.NoError(t, err)
+	defer client.Close()
+	out, err := client.RunCommand(context.Background(), "hostname", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "ran hostname", out)
+	assert.Equal(t, []string{fmt.Sprintf("127.0.0.1:%d", machine.port())}, jump.forwarded)
+}
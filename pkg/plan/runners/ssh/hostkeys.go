@@ -0,0 +1,145 @@
+package ssh
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// HostKeyAnnotation is the annotation of ExistingInfraMachine objects pinning
+// the SSH host key of the machine, in the authorized_keys format, e.g.:
+// "ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAI...". Machines without one are
+// checked against the known hosts.
+const HostKeyAnnotation = "wksctl.weave.works/ssh-host-key"
+
+// Policies applied to the host keys of the machines and jump hosts.
+const (
+	// StrictHostKeyPolicy only accepts hosts whose key is pinned or known.
+	StrictHostKeyPolicy = "strict"
+	// AcceptNewHostKeyPolicy accepts, and records, the keys of unknown hosts
+	// but refuses hosts whose key changed.
+	AcceptNewHostKeyPolicy = "accept-new"
+	// InsecureHostKeyPolicy accepts any host key.
+	InsecureHostKeyPolicy = "insecure"
+)
+
+// HostKeyPolicies lists the valid host key policies.
+var HostKeyPolicies = []string{StrictHostKeyPolicy, AcceptNewHostKeyPolicy, InsecureHostKeyPolicy}
+
+// knownHostsLock serializes the writes to known hosts files.
+var knownHostsLock sync.Mutex
+
+// defaultKnownHostsPath returns the path to the current user's known hosts.
+func defaultKnownHostsPath() string {
+	return filepath.Join(os.Getenv("HOME"), ".ssh", "known_hosts")
+}
+
+// ParseHostKey parses a host key in the authorized_keys format.
+func ParseHostKey(s string) (ssh.PublicKey, error) {
+	key, _, _, _, err := ssh.ParseAuthorizedKey([]byte(s))
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid host key %q", s)
+	}
+	return key, nil
+}
+
+// FormatHostKey formats a host key in the authorized_keys format.
+func FormatHostKey(key ssh.PublicKey) string {
+	return string(bytes.TrimSpace(ssh.MarshalAuthorizedKey(key)))
+}
+
+// hostKeyCallback returns the callback checking host keys with the provided
+// policy: against the pinned key if there is one, and otherwise against the
+// known hosts. Keys accepted on first contact are added to the known hosts,
+// unless keepKnownHosts is set, and passed to accepted, if not nil.
+func hostKeyCallback(policy, knownHostsPath string, keepKnownHosts bool, pinned ssh.PublicKey, accepted func(ssh.PublicKey) error) (ssh.HostKeyCallback, error) {
+	switch policy {
+	case InsecureHostKeyPolicy:
+		return ssh.InsecureIgnoreHostKey(), nil
+	case StrictHostKeyPolicy, AcceptNewHostKeyPolicy, "":
+	default:
+		return nil, errors.Errorf("invalid host key policy %q, expected one of %v", policy, HostKeyPolicies)
+	}
+	if pinned != nil {
+		return func(hostname string, _ net.Addr, key ssh.PublicKey) error {
+			if !bytes.Equal(key.Marshal(), pinned.Marshal()) {
+				return errors.Errorf("the host key of %s, %s, is not the one pinned by the machine's %q annotation, someone could be impersonating the host (if the machine was recreated, remove the annotation)", hostname, ssh.FingerprintSHA256(key), HostKeyAnnotation)
+			}
+			return nil
+		}, nil
+	}
+	if knownHostsPath == "" {
+		knownHostsPath = defaultKnownHostsPath()
+	}
+	known, err := knownHosts(knownHostsPath)
+	if err != nil {
+		return nil, err
+	}
+	return func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		err := known(hostname, remote, key)
+		keyErr, ok := err.(*knownhosts.KeyError)
+		if !ok || len(keyErr.Want) > 0 {
+			if ok {
+				return errors.Errorf("the host key of %s, %s, differs from the one in %s, someone could be impersonating the host (if the machine was recreated, remove its key with \"ssh-keygen -R '%s' -f %s\")", hostname, ssh.FingerprintSHA256(key), knownHostsPath, knownhosts.Normalize(hostname), knownHostsPath)
+			}
+			return err
+		}
+		if policy == StrictHostKeyPolicy {
+			return errors.Errorf("%s is not a known host, add its key to %s or set the %q annotation of its machine", hostname, knownHostsPath, HostKeyAnnotation)
+		}
+		if keepKnownHosts {
+			log.Infof("Accepted the host key of %s, %s", hostname, ssh.FingerprintSHA256(key))
+		} else {
+			if err := addKnownHost(knownHostsPath, hostname, key); err != nil {
+				return err
+			}
+			log.Infof("Added the host key of %s, %s, to %s", hostname, ssh.FingerprintSHA256(key), knownHostsPath)
+		}
+		if accepted != nil {
+			return accepted(key)
+		}
+		return nil
+	}, nil
+}
+
+// knownHosts returns the callback checking host keys against the provided
+// known hosts file, to which no host is known if it doesn't exist.
+func knownHosts(path string) (ssh.HostKeyCallback, error) {
+	knownHostsLock.Lock()
+	defer knownHostsLock.Unlock()
+	callback, err := knownhosts.New(path)
+	if os.IsNotExist(err) {
+		return func(string, net.Addr, ssh.PublicKey) error {
+			return &knownhosts.KeyError{}
+		}, nil
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read known hosts %s", path)
+	}
+	return callback, nil
+}
+
+func addKnownHost(path, hostname string, key ssh.PublicKey) error {
+	knownHostsLock.Lock()
+	defer knownHostsLock.Unlock()
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return errors.Wrapf(err, "failed to create the directory of known hosts %s", path)
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return errors.Wrapf(err, "failed to open known hosts %s", path)
+	}
+	if _, err := fmt.Fprintln(f, knownhosts.Line([]string{knownhosts.Normalize(hostname)}, key)); err != nil {
+		f.Close()
+		return errors.Wrapf(err, "failed to add %s to known hosts %s", hostname, path)
+	}
+	return f.Close()
+}
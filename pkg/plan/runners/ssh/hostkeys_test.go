@@ -0,0 +1,163 @@
+package ssh
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/weaveworks/cluster-api-provider-existinginfra/apis/cluster.weave.works/v1alpha3"
+	capeimachine "github.com/weaveworks/cluster-api-provider-existinginfra/pkg/cluster/machine"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha3"
+)
+
+func otherHostKey(t *testing.T) ssh.PublicKey {
+	public, _, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	key, err := ssh.NewPublicKey(public)
+	require.NoError(t, err)
+	return key
+}
+
+func TestHostKeyPolicies(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ssh")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	knownHosts := filepath.Join(dir, "known_hosts")
+
+	keyPath, publicKey := writeKey(t, dir, "cluster-key")
+	machine := newServer(t, publicKey)
+	defer machine.listener.Close()
+	params := ClientParams{User: "root", Host: "127.0.0.1", Port: machine.port(), PrivateKeyPath: keyPath, KnownHostsPath: knownHosts}
+	connect := func(policy string) error {
+		params.HostKeyPolicy = policy
+		client, err := NewClient(params)
+		if err == nil {
+			client.Close()
+		}
+		return err
+	}
+
+	// Unknown hosts.
+	err = connect(StrictHostKeyPolicy)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "not a known host")
+	assert.NoError(t, connect(InsecureHostKeyPolicy))
+	_, err = os.Stat(knownHosts)
+	assert.True(t, os.IsNotExist(err))
+
+	var accepted ssh.PublicKey
+	params.HostKeyAccepted = func(key ssh.PublicKey) error {
+		accepted = key
+		return nil
+	}
+	params.KeepKnownHosts = true
+	require.NoError(t, connect(AcceptNewHostKeyPolicy))
+	assert.Equal(t, machine.hostKey, accepted)
+	_, err = os.Stat(knownHosts)
+	assert.True(t, os.IsNotExist(err))
+	params.KeepKnownHosts = false
+	require.NoError(t, connect(AcceptNewHostKeyPolicy))
+	assert.Equal(t, machine.hostKey, accepted)
+	contents, err := ioutil.ReadFile(knownHosts)
+	require.NoError(t, err)
+	assert.Equal(t, knownhosts.Line([]string{fmt.Sprintf("[127.0.0.1]:%d", machine.port())}, machine.hostKey)+"\n", string(contents))
+
+	// Known hosts.
+	accepted = nil
+	assert.NoError(t, connect(StrictHostKeyPolicy))
+	assert.NoError(t, connect(AcceptNewHostKeyPolicy))
+	assert.Nil(t, accepted)
+
+	// Changed host keys.
+	line := knownhosts.Line([]string{fmt.Sprintf("[127.0.0.1]:%d", machine.port())}, otherHostKey(t))
+	require.NoError(t, ioutil.WriteFile(knownHosts, []byte(line+"\n"), 0600))
+	err = connect(AcceptNewHostKeyPolicy)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "differs from the one in")
+	assert.NoError(t, connect(InsecureHostKeyPolicy))
+
+	// Pinned host keys take precedence over the known hosts.
+	params.HostKey = machine.hostKey
+	assert.NoError(t, connect(StrictHostKeyPolicy))
+	params.HostKey = otherHostKey(t)
+	err = connect(AcceptNewHostKeyPolicy)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "is not the one pinned")
+
+	assert.Error(t, connect("trust-me"))
+}
+
+const machinesManifest = `apiVersion: cluster.x-k8s.io/v1alpha3
+kind: Machine
+metadata:
+  labels:
+    set: master
+  name: master-0
+spec:
+  clusterName: example
+  version: 1.18.9
+  infrastructureRef:
+    apiVersion: cluster.weave.works/v1alpha3
+    kind: ExistingInfraMachine
+    name: master-0
+---
+apiVersion: cluster.weave.works/v1alpha3
+kind: ExistingInfraMachine
+metadata:
+  name: master-0 # The seed node.
+spec:
+  private:
+    address: 172.17.8.101
+    port: 22
+  public:
+    address: 127.0.0.1
+    port: %d
+`
+
+func TestRecordHostKey(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ssh")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	keyPath, publicKey := writeKey(t, dir, "cluster-key")
+	machine := newServer(t, publicKey)
+	defer machine.listener.Close()
+	machinesPath := filepath.Join(dir, "machines.yaml")
+	require.NoError(t, ioutil.WriteFile(machinesPath, []byte(fmt.Sprintf(machinesManifest, machine.port())), 0600))
+	spec := &v1alpha3.MachineSpec{Public: v1alpha3.EndPoint{Address: "127.0.0.1", Port: machine.port()}}
+	o := &Options{
+		KeyPath:        keyPath,
+		KnownHostsPath: filepath.Join(dir, "known_hosts"),
+		HostKeyPolicy:  AcceptNewHostKeyPolicy,
+		MachinesPath:   machinesPath,
+		RecordHostKeys: true,
+	}
+
+	client, err := o.NewClientForMachine(&clusterv1.Cluster{}, spec, "root", false)
+	require.NoError(t, err)
+	client.Close()
+	_, machines, err := capeimachine.ParseManifest(machinesPath)
+	require.NoError(t, err)
+	assert.Equal(t, FormatHostKey(machine.hostKey), machines[0].Annotations[HostKeyAnnotation])
+	contents, err := ioutil.ReadFile(machinesPath)
+	require.NoError(t, err)
+	assert.Contains(t, string(contents), "  name: master-0 # The seed node.\n")
+	info, err := os.Stat(machinesPath)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode(), "the mode of the manifest is kept")
+
+	// The pinned key is enforced, even with an empty known hosts file.
+	require.NoError(t, os.Remove(o.KnownHostsPath))
+	o.HostKeyPolicy = StrictHostKeyPolicy
+	client, err = o.NewClientForMachine(&clusterv1.Cluster{}, spec, "root", false)
+	require.NoError(t, err)
+	client.Close()
+}
@@ -0,0 +1,50 @@
+package ssh
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// JumpHostAnnotation is the annotation of Cluster objects setting the jump
+// host, as user@host[:port], the machines of the cluster are reached through.
+const JumpHostAnnotation = "wksctl.weave.works/ssh-jump-host"
+
+const defaultPort = 22
+
+// JumpHost is a host connections to machines are tunnelled through.
+type JumpHost struct {
+	User string
+	Host string
+	Port uint16
+	// PrivateKeyPath is the path to the key to log in to the jump host with.
+	// The key of the machines is used if empty.
+	PrivateKeyPath string
+}
+
+func (j *JumpHost) String() string {
+	return fmt.Sprintf("%s@%s", j.User, net.JoinHostPort(j.Host, strconv.Itoa(int(j.Port))))
+}
+
+// ParseJumpHost parses a jump host of the form user@host[:port].
+func ParseJumpHost(s string) (*JumpHost, error) {
+	at := strings.LastIndex(s, "@")
+	if at <= 0 {
+		return nil, errors.Errorf("invalid jump host %q, expected user@host[:port]", s)
+	}
+	j := &JumpHost{User: s[:at], Host: s[at+1:], Port: defaultPort}
+	if host, port, err := net.SplitHostPort(j.Host); err == nil {
+		p, err := strconv.ParseUint(port, 10, 16)
+		if err != nil {
+			return nil, errors.Errorf("invalid port %q of jump host %q", port, s)
+		}
+		j.Host, j.Port = host, uint16(p)
+	}
+	if j.Host == "" {
+		return nil, errors.Errorf("invalid jump host %q, expected user@host[:port]", s)
+	}
+	return j, nil
+}
@@ -0,0 +1,53 @@
+package ssh
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha3"
+)
+
+func TestParseJumpHost(t *testing.T) {
+	tests := []struct {
+		in       string
+		expected *JumpHost
+	}{
+		{in: "bastion@10.0.0.1", expected: &JumpHost{User: "bastion", Host: "10.0.0.1", Port: 22}},
+		{in: "bastion@bastion.example.com:2222", expected: &JumpHost{User: "bastion", Host: "bastion.example.com", Port: 2222}},
+		{in: "bastion@[fd00::1]:2222", expected: &JumpHost{User: "bastion", Host: "fd00::1", Port: 2222}},
+		{in: "10.0.0.1"},
+		{in: "bastion@"},
+		{in: "bastion@10.0.0.1:ssh"},
+	}
+	for _, test := range tests {
+		j, err := ParseJumpHost(test.in)
+		if test.expected == nil {
+			assert.Error(t, err, test.in)
+			continue
+		}
+		require.NoError(t, err, test.in)
+		assert.Equal(t, test.expected, j)
+	}
+	j, _ := ParseJumpHost("bastion@fd00::1")
+	assert.Equal(t, "bastion@[fd00::1]:22", j.String())
+}
+
+func TestJumpHostFor(t *testing.T) {
+	cluster := &clusterv1.Cluster{ObjectMeta: metav1.ObjectMeta{
+		Annotations: map[string]string{JumpHostAnnotation: "annotated@10.0.0.1"},
+	}}
+
+	j, err := (&Options{JumpHostKeyPath: "bastion-key"}).JumpHostFor(cluster)
+	require.NoError(t, err)
+	assert.Equal(t, &JumpHost{User: "annotated", Host: "10.0.0.1", Port: 22, PrivateKeyPath: "bastion-key"}, j)
+
+	j, err = (&Options{JumpHost: "flag@10.0.0.2:2222"}).JumpHostFor(cluster)
+	require.NoError(t, err)
+	assert.Equal(t, &JumpHost{User: "flag", Host: "10.0.0.2", Port: 2222}, j)
+
+	j, err = (&Options{}).JumpHostFor(&clusterv1.Cluster{})
+	require.NoError(t, err)
+	assert.Nil(t, j)
+}
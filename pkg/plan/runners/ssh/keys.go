@@ -0,0 +1,117 @@
+package ssh
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"sync"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	sshutil "github.com/weaveworks/cluster-api-provider-existinginfra/pkg/utilities/ssh"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/crypto/ssh/terminal"
+)
+
+// Passphrases read from a file or typed in are kept for the duration of the
+// command, for commands connecting to several machines not to prompt for them
+// again.
+var passphrases = struct {
+	sync.Mutex
+	byKey map[string][]byte
+}{byKey: map[string][]byte{}}
+
+// authMethod returns the method to log in with: the provided key, decrypted
+// with the passphrase held by passphraseFile or typed in if it is protected by
+// one, and the keys held by the SSH agent, if one is running. The key file may
+// be missing if the agent holds keys. The connection to the agent, if any, is
+// returned too, to be closed once done with the method.
+func authMethod(privateKeyPath string, privateKey []byte, passphraseFile string) (ssh.AuthMethod, io.Closer, error) {
+	agentSigners, agentConn, err := agentSigners()
+	if err != nil {
+		log.Warnf("Failed to read keys from the SSH agent: %v", err)
+	}
+	fail := func(err error) (ssh.AuthMethod, io.Closer, error) {
+		if agentConn != nil {
+			agentConn.Close()
+		}
+		return nil, nil, err
+	}
+
+	if len(privateKey) == 0 {
+		privateKey, err = sshutil.ReadPrivateKey(privateKeyPath)
+		if os.IsNotExist(errors.Cause(err)) && len(agentSigners) > 0 {
+			log.Debugf("No private key at %s, using the keys of the SSH agent", privateKeyPath)
+			return ssh.PublicKeys(agentSigners...), agentConn, nil
+		}
+		if err != nil {
+			return fail(err)
+		}
+	}
+	signer, err := ssh.ParsePrivateKey(privateKey)
+	if _, ok := err.(*ssh.PassphraseMissingError); ok {
+		signer, err = parseEncryptedKey(privateKeyPath, privateKey, passphraseFile)
+	}
+	if err != nil {
+		return fail(errors.Wrapf(err, "failed to parse private key \"%s\"", privateKeyPath))
+	}
+	return ssh.PublicKeys(append([]ssh.Signer{signer}, agentSigners...)...), agentConn, nil
+}
+
+func parseEncryptedKey(privateKeyPath string, privateKey []byte, passphraseFile string) (ssh.Signer, error) {
+	passphrases.Lock()
+	defer passphrases.Unlock()
+	if passphrase, ok := passphrases.byKey[privateKeyPath]; ok {
+		return ssh.ParsePrivateKeyWithPassphrase(privateKey, passphrase)
+	}
+
+	var passphrase []byte
+	if passphraseFile != "" {
+		data, err := ioutil.ReadFile(passphraseFile)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to read passphrase")
+		}
+		passphrase = bytes.TrimRight(data, "\r\n")
+	} else {
+		if !terminal.IsTerminal(int(os.Stdin.Fd())) {
+			return nil, errors.New("the key is protected by a passphrase, provide it with --ssh-key-passphrase-file or load the key in the SSH agent")
+		}
+		fmt.Fprintf(os.Stderr, "Enter passphrase for key %s: ", privateKeyPath)
+		var err error
+		passphrase, err = terminal.ReadPassword(int(os.Stdin.Fd()))
+		fmt.Fprintln(os.Stderr)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to read passphrase")
+		}
+	}
+	signer, err := ssh.ParsePrivateKeyWithPassphrase(privateKey, passphrase)
+	if err != nil {
+		return nil, err
+	}
+	passphrases.byKey[privateKeyPath] = passphrase
+	return signer, nil
+}
+
+// agentSigners returns the keys held by the SSH agent listening on
+// SSH_AUTH_SOCK, if any, and the connection to the agent, which is kept open
+// for the agent to sign with the keys.
+func agentSigners() ([]ssh.Signer, io.Closer, error) {
+	socket := os.Getenv("SSH_AUTH_SOCK")
+	if socket == "" {
+		return nil, nil, nil
+	}
+	conn, err := net.Dial("unix", socket)
+	if err != nil {
+		return nil, nil, err
+	}
+	signers, err := agent.NewClient(conn).Signers()
+	if err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+	return signers, conn, nil
+}
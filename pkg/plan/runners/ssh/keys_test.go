@@ -0,0 +1,143 @@
+package ssh
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+func writeEncryptedKey(t *testing.T, dir, name, passphrase string) (string, ssh.PublicKey) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	signer, err := ssh.NewSignerFromKey(key)
+	require.NoError(t, err)
+	der, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+	//nolint:staticcheck // Legacy PEM encryption is what older ssh-keygen versions produce.
+	block, err := x509.EncryptPEMBlock(rand.Reader, "EC PRIVATE KEY", der, []byte(passphrase), x509.PEMCipherAES256)
+	require.NoError(t, err)
+	path := filepath.Join(dir, name)
+	require.NoError(t, ioutil.WriteFile(path, pem.EncodeToMemory(block), 0600))
+	return path, signer.PublicKey()
+}
+
+func TestClientWithEncryptedKey(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ssh")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	home := os.Getenv("HOME")
+	os.Setenv("HOME", dir)
+	defer os.Setenv("HOME", home)
+	authSock := os.Getenv("SSH_AUTH_SOCK")
+	os.Unsetenv("SSH_AUTH_SOCK")
+	defer os.Setenv("SSH_AUTH_SOCK", authSock)
+
+	keyPath, publicKey := writeEncryptedKey(t, dir, "cluster-key", "open sesame")
+	machine := newServer(t, publicKey)
+	defer machine.listener.Close()
+	params := ClientParams{User: "root", Host: "127.0.0.1", Port: machine.port(), PrivateKeyPath: keyPath}
+
+	// Tests don't run in a terminal, where the passphrase would be prompted for.
+	_, err = NewClient(params)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "--ssh-key-passphrase-file")
+
+	wrongPassphrase := filepath.Join(dir, "wrong-passphrase")
+	require.NoError(t, ioutil.WriteFile(wrongPassphrase, []byte("sesame\n"), 0600))
+	params.PassphraseFile = wrongPassphrase
+	_, err = NewClient(params)
+	assert.Error(t, err)
+
+	passphrase := filepath.Join(dir, "passphrase")
+	require.NoError(t, ioutil.WriteFile(passphrase, []byte("open sesame\n"), 0600))
+	params.PassphraseFile = passphrase
+	client, err := NewClient(params)
+	require.NoError(t, err)
+	defer client.Close()
+	out, err := client.RunCommand(context.Background(), "true", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "ran true", out)
+
+	// The passphrase is remembered.
+	params.PassphraseFile = ""
+	client, err = NewClient(params)
+	require.NoError(t, err)
+	client.Close()
+}
+
+func TestClientWithAgent(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ssh")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	home := os.Getenv("HOME")
+	os.Setenv("HOME", dir)
+	defer os.Setenv("HOME", home)
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	keyring := agent.NewKeyring()
+	require.NoError(t, keyring.Add(agent.AddedKey{PrivateKey: key}))
+	socket := filepath.Join(dir, "agent.sock")
+	listener, err := net.Listen("unix", socket)
+	require.NoError(t, err)
+	defer listener.Close()
+	// Connections to the agent are reported once the client closed them.
+	closed := make(chan struct{}, 10)
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				_ = agent.ServeAgent(keyring, conn)
+				closed <- struct{}{}
+			}()
+		}
+	}()
+	assertClosed := func() {
+		select {
+		case <-closed:
+		case <-time.After(5 * time.Second):
+			t.Error("the connection to the SSH agent wasn't closed")
+		}
+	}
+	authSock := os.Getenv("SSH_AUTH_SOCK")
+	os.Setenv("SSH_AUTH_SOCK", socket)
+	defer os.Setenv("SSH_AUTH_SOCK", authSock)
+
+	signer, err := ssh.NewSignerFromKey(key)
+	require.NoError(t, err)
+	machine := newServer(t, signer.PublicKey())
+	defer machine.listener.Close()
+
+	// The default key file doesn't exist, the agent's key is used.
+	client, err := NewClient(ClientParams{User: "root", Host: "127.0.0.1", Port: machine.port(), PrivateKeyPath: filepath.Join(dir, "cluster-key")})
+	require.NoError(t, err)
+	out, err := client.RunCommand(context.Background(), "true", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "ran true", out)
+	assert.Empty(t, closed)
+	require.NoError(t, client.Close())
+	assertClosed()
+
+	// The connection is closed when the client fails to connect, too.
+	machine.listener.Close()
+	_, err = NewClient(ClientParams{User: "root", Host: "127.0.0.1", Port: machine.port(), PrivateKeyPath: filepath.Join(dir, "cluster-key")})
+	require.Error(t, err)
+	assertClosed()
+}
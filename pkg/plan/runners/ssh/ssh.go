@@ -1,18 +1,147 @@
 package ssh
 
 import (
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/pflag"
 	"github.com/weaveworks/cluster-api-provider-existinginfra/apis/cluster.weave.works/v1alpha3"
-	capeissh "github.com/weaveworks/cluster-api-provider-existinginfra/pkg/plan/runners/ssh"
+	capeimachine "github.com/weaveworks/cluster-api-provider-existinginfra/pkg/cluster/machine"
+	"github.com/weaveworks/wksctl/pkg/utilities/manifest"
+	"golang.org/x/crypto/ssh"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha3"
 )
 
-func NewClientForMachine(m *v1alpha3.MachineSpec, user, keyPath string, printOutputs bool) (*capeissh.Client, error) {
-	ip := m.Public.Address
-	port := m.Public.Port
-	return capeissh.NewClient(capeissh.ClientParams{
+// Options are the command line options of commands reaching machines by SSH.
+type Options struct {
+	KeyPath           string
+	KeyPassphraseFile string
+	JumpHost          string
+	JumpHostKeyPath   string
+	KnownHostsPath    string
+	HostKeyPolicy     string
+	// MachinesPath, if set, is the path to the machines manifest, whose
+	// machines may pin their host key with the HostKeyAnnotation.
+	MachinesPath string
+	// RecordHostKeys sets whether the keys of machines accepted on first
+	// contact are pinned in the machines manifest.
+	RecordHostKeys bool
+	// KeepKnownHosts sets whether the known hosts are left as they are, the
+	// keys accepted on first contact then not being added to them.
+	KeepKnownHosts bool
+}
+
+// machinesLock serializes the updates of machines manifests.
+var machinesLock sync.Mutex
+
+// AddFlags registers the SSH options with the provided flag set.
+func (o *Options) AddFlags(fs *pflag.FlagSet) {
+	fs.StringVar(&o.KeyPath, "ssh-key", "./cluster-key", "Path to a key authorized to log in to machines by SSH (keys held by the SSH agent are also used)")
+	fs.StringVar(&o.KeyPassphraseFile, "ssh-key-passphrase-file", "", "Path to a file holding the passphrase of the SSH keys, which is otherwise prompted for")
+	fs.StringVar(&o.JumpHost, "ssh-jump-host", "", "Host to reach machines through by SSH, as user@host[:port], overriding the cluster's \""+JumpHostAnnotation+"\" annotation")
+	fs.StringVar(&o.JumpHostKeyPath, "ssh-jump-host-key", "", "Path to a key authorized to log in to the jump host by SSH (defaults to the key of the machines)")
+	fs.StringVar(&o.KnownHostsPath, "known-hosts", "", "Path to the known hosts the host keys of machines without a pinned key are checked against (defaults to ~/.ssh/known_hosts)")
+	fs.StringVar(&o.HostKeyPolicy, "ssh-host-key-policy", AcceptNewHostKeyPolicy, "Policy for the host keys of machines ("+strings.Join(HostKeyPolicies, "|")+"): strict only accepts pinned or known keys, accept-new also accepts and records the keys of new hosts, insecure accepts any key")
+}
+
+// JumpHostFor returns the jump host to reach the machines of the provided
+// cluster through, set by the options or else by the cluster's annotation,
+// or nil if there is none.
+func (o *Options) JumpHostFor(cluster *clusterv1.Cluster) (*JumpHost, error) {
+	s := o.JumpHost
+	if s == "" && cluster != nil {
+		s = cluster.Annotations[JumpHostAnnotation]
+	}
+	if s == "" {
+		return nil, nil
+	}
+	j, err := ParseJumpHost(s)
+	if err != nil {
+		return nil, err
+	}
+	j.PrivateKeyPath = o.JumpHostKeyPath
+	return j, nil
+}
+
+// NewClientForMachine creates a client to the provided machine of the
+// provided cluster, logging in as the provided user.
+func (o *Options) NewClientForMachine(cluster *clusterv1.Cluster, m *v1alpha3.MachineSpec, user string, printOutputs bool) (*Client, error) {
+	jumpHost, err := o.JumpHostFor(cluster)
+	if err != nil {
+		return nil, err
+	}
+	params := ClientParams{
 		User:           user,
-		Host:           ip,
-		Port:           port,
-		PrivateKeyPath: keyPath,
+		Host:           m.Public.Address,
+		Port:           m.Public.Port,
+		PrivateKeyPath: o.KeyPath,
+		PassphraseFile: o.KeyPassphraseFile,
+		JumpHost:       jumpHost,
+		HostKeyPolicy:  o.HostKeyPolicy,
+		KnownHostsPath: o.KnownHostsPath,
+		KeepKnownHosts: o.KeepKnownHosts,
 		PrintOutputs:   printOutputs,
-	})
+	}
+	if o.MachinesPath != "" {
+		machine, err := o.machineAt(m.Public)
+		if err != nil {
+			return nil, err
+		}
+		if machine != nil {
+			if hostKey, ok := machine.Annotations[HostKeyAnnotation]; ok {
+				params.HostKey, err = ParseHostKey(hostKey)
+				if err != nil {
+					return nil, errors.Wrapf(err, "machine %s", machine.Name)
+				}
+			} else if o.RecordHostKeys {
+				params.HostKeyAccepted = func(key ssh.PublicKey) error {
+					return o.recordHostKey(machine.Name, key)
+				}
+			}
+		}
+	}
+	return NewClient(params)
+}
+
+// machineAt returns the machine of the machines manifest reached at the
+// provided public endpoint, or nil if there is none.
+func (o *Options) machineAt(public v1alpha3.EndPoint) (*v1alpha3.ExistingInfraMachine, error) {
+	_, machines, err := capeimachine.ParseManifest(o.MachinesPath)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to parse machines manifest %s", o.MachinesPath)
+	}
+	for _, m := range machines {
+		if m.Spec.Public == public {
+			return m, nil
+		}
+	}
+	return nil, nil
+}
+
+// recordHostKey pins the provided host key in the machines manifest, with the
+// HostKeyAnnotation of the provided machine.
+func (o *Options) recordHostKey(machine string, key ssh.PublicKey) error {
+	machinesLock.Lock()
+	defer machinesLock.Unlock()
+	info, err := os.Stat(o.MachinesPath)
+	if err != nil {
+		return errors.Wrapf(err, "failed to read machines manifest %s", o.MachinesPath)
+	}
+	contents, err := ioutil.ReadFile(o.MachinesPath)
+	if err != nil {
+		return errors.Wrapf(err, "failed to read machines manifest %s", o.MachinesPath)
+	}
+	contents, err = manifest.SetAnnotation(contents, "ExistingInfraMachine", machine, HostKeyAnnotation, FormatHostKey(key))
+	if err != nil {
+		return errors.Wrapf(err, "failed to record the host key of machine %s", machine)
+	}
+	if err := ioutil.WriteFile(o.MachinesPath, contents, info.Mode()); err != nil {
+		return errors.Wrapf(err, "failed to write machines manifest %s", o.MachinesPath)
+	}
+	log.Infof("Pinned the host key of machine %s in %s", machine, o.MachinesPath)
+	return nil
 }
@@ -0,0 +1,324 @@
+package preflight
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	existinginfrav1 "github.com/weaveworks/cluster-api-provider-existinginfra/apis/cluster.weave.works/v1alpha3"
+	capeios "github.com/weaveworks/cluster-api-provider-existinginfra/pkg/apis/wksprovider/machine/os"
+	"github.com/weaveworks/cluster-api-provider-existinginfra/pkg/plan"
+	"github.com/weaveworks/cluster-api-provider-existinginfra/pkg/plan/runners/sudo"
+)
+
+const (
+	mib = 1024 * 1024
+	gib = 1024 * mib
+	// minMasterMemory is the memory kubeadm requires of control plane nodes.
+	minMasterMemory = 1700 * mib
+	minWorkerMemory = 1 * gib
+	// minFreeDisk is the space under /var/lib the packages and images of
+	// Kubernetes need.
+	minFreeDisk = 2 * gib
+	// maxClockSkew is the largest difference between the clocks of machines
+	// before certificates, etcd and leader elections misbehave.
+	maxClockSkew = 2 * time.Second
+	// dialTimeout is how long machines have to connect to each other.
+	dialTimeout = 5
+)
+
+// Ports Kubernetes listens on.
+var (
+	masterPorts = []int{6443, 2379, 2380, 10250, 10251, 10252}
+	workerPorts = []int{10250}
+)
+
+// target is a machine being checked, with the facts gathered about it which
+// are compared across machines.
+type target struct {
+	name      string
+	master    bool
+	eiMachine *existinginfrav1.ExistingInfraMachine
+	private   existinginfrav1.EndPoint
+	// runner runs commands on the machine, while it is connected to.
+	runner plan.Runner
+	// sudo runs commands as root, if the user can.
+	sudo        plan.Runner
+	hostname    string
+	productUUID string
+	// clockOffset is how far ahead of the local clock the machine's is, and
+	// clockErr the error reading it.
+	clockOffset time.Duration
+	clockErr    error
+	// unreachable lists the other machines the machine cannot connect to
+	// the private address of.
+	unreachable []string
+}
+
+func (t *target) run(ctx context.Context, command string) (string, error) {
+	out, err := t.runner.RunCommand(ctx, command, nil)
+	return strings.TrimSpace(out), err
+}
+
+// check runs the checks of the machine itself, and gathers the facts compared
+// across machines.
+func (t *target) check(ctx context.Context, r *Report) {
+	if _, err := t.run(ctx, "sudo -n true"); err != nil {
+		r.add(t.name, "sudo", Fail, "the user cannot run commands as root with sudo without a password")
+	} else {
+		r.add(t.name, "sudo", Pass, "passwordless")
+		t.sudo = &sudo.Runner{Runner: t.runner}
+	}
+
+	if installer, err := capeios.Identify(ctx, t.runner); err != nil {
+		r.add(t.name, "os", Fail, "%v", err)
+	} else {
+		r.add(t.name, "os", Pass, "%s", installer.Name)
+	}
+
+	if out, err := t.run(ctx, "cat /proc/swaps"); err != nil {
+		r.add(t.name, "swap", Warn, "failed to read /proc/swaps: %v", err)
+	} else if swaps := strings.Count(out, "\n"); swaps > 0 {
+		r.add(t.name, "swap", Warn, "%d swap device(s) on, the kubelet refuses to run with swap unless the plan turns it off", swaps)
+	} else {
+		r.add(t.name, "swap", Pass, "off")
+	}
+
+	t.checkResource(ctx, r, "disk", "df -Pk /var/lib", parseAvailableDisk, minFreeDisk, "free under /var/lib")
+	minMemory := int64(minWorkerMemory)
+	if t.master {
+		minMemory = minMasterMemory
+	}
+	t.checkResource(ctx, r, "memory", "cat /proc/meminfo", parseTotalMemory, minMemory, "of memory")
+
+	t.checkPorts(ctx, r)
+
+	if hostname, err := t.run(ctx, "hostname"); err != nil {
+		r.add(t.name, "hostname", Fail, "failed to read hostname: %v", err)
+	} else {
+		t.hostname = hostname
+	}
+	if t.sudo != nil {
+		if uuid, err := t.sudo.RunCommand(ctx, "cat /sys/class/dmi/id/product_uuid", nil); err != nil {
+			r.add(t.name, "product-uuid", Warn, "failed to read product UUID: %v", err)
+		} else {
+			t.productUUID = strings.TrimSpace(uuid)
+		}
+	}
+
+	before := time.Now()
+	out, err := t.run(ctx, "date +%s.%N")
+	after := time.Now()
+	if err == nil {
+		var seconds float64
+		seconds, err = strconv.ParseFloat(out, 64)
+		if err == nil {
+			sec, frac := math.Modf(seconds)
+			remote := time.Unix(int64(sec), int64(frac*float64(time.Second)))
+			// The remote clock was read half-way through the round trip.
+			t.clockOffset = remote.Sub(before.Add(after.Sub(before) / 2))
+		}
+	}
+	t.clockErr = err
+}
+
+func (t *target) checkResource(ctx context.Context, r *Report, check, command string, parse func(string) (int64, error), min int64, what string) {
+	out, err := t.run(ctx, command)
+	if err != nil {
+		r.add(t.name, check, Warn, "failed to run %q: %v", command, err)
+		return
+	}
+	available, err := parse(out)
+	if err != nil {
+		r.add(t.name, check, Warn, "%v", err)
+		return
+	}
+	if available < min {
+		r.add(t.name, check, Warn, "%s %s, less than the %s recommended", formatBytes(available), what, formatBytes(min))
+		return
+	}
+	r.add(t.name, check, Pass, "%s %s", formatBytes(available), what)
+}
+
+func (t *target) checkPorts(ctx context.Context, r *Report) {
+	if _, err := t.run(ctx, "test -e /etc/kubernetes/kubelet.conf"); err == nil {
+		r.add(t.name, "ports", Pass, "the machine already is a Kubernetes node")
+		return
+	}
+	out, err := t.run(ctx, "cat /proc/net/tcp /proc/net/tcp6")
+	if err != nil {
+		r.add(t.name, "ports", Warn, "failed to list listening ports: %v", err)
+		return
+	}
+	listening := parseListeningPorts(out)
+	ports := workerPorts
+	if t.master {
+		ports = masterPorts
+	}
+	var used []string
+	for _, port := range ports {
+		if listening[port] {
+			used = append(used, strconv.Itoa(port))
+		}
+	}
+	if len(used) > 0 {
+		r.add(t.name, "ports", Fail, "port(s) %s, which Kubernetes needs, already in use", strings.Join(used, ", "))
+		return
+	}
+	r.add(t.name, "ports", Pass, "free")
+}
+
+// checkUnique checks the machines have different values of the provided fact.
+func checkUnique(r *Report, targets []*target, check string, status Status, fact func(*target) string) {
+	byValue := map[string][]string{}
+	for _, t := range targets {
+		if v := fact(t); v != "" {
+			byValue[v] = append(byValue[v], t.name)
+		}
+	}
+	for _, t := range targets {
+		v := fact(t)
+		if v == "" {
+			continue
+		}
+		var others []string
+		for _, name := range byValue[v] {
+			if name != t.name {
+				others = append(others, name)
+			}
+		}
+		if len(others) > 0 {
+			r.add(t.name, check, status, "%s is also the one of %s", v, strings.Join(others, ", "))
+		} else {
+			r.add(t.name, check, Pass, "%s", v)
+		}
+	}
+}
+
+// checkClocks checks the clocks of the machines agree with the clock of the
+// first one.
+func checkClocks(r *Report, targets []*target) {
+	var reference *target
+	for _, t := range targets {
+		if t.clockErr != nil {
+			r.add(t.name, "clock", Warn, "failed to read the clock: %v", t.clockErr)
+			continue
+		}
+		if reference == nil {
+			reference = t
+			r.add(t.name, "clock", Pass, "reference clock")
+			continue
+		}
+		skew := t.clockOffset - reference.clockOffset
+		direction := "ahead of"
+		if skew < 0 {
+			skew, direction = -skew, "behind"
+		}
+		skew = skew.Round(time.Millisecond)
+		if skew > maxClockSkew {
+			r.add(t.name, "clock", Fail, "%v %s %s, more than the %v tolerated, synchronize the clocks with NTP", skew, direction, reference.name, maxClockSkew)
+			continue
+		}
+		r.add(t.name, "clock", Pass, "%v %s %s", skew, direction, reference.name)
+	}
+}
+
+// probePrivateNetwork tries to connect from the machine to the private
+// address of the other machines, including those which cannot be reached by
+// SSH.
+func (t *target) probePrivateNetwork(ctx context.Context, targets []*target) {
+	for _, other := range targets {
+		if other == t {
+			continue
+		}
+		command := fmt.Sprintf("timeout %d bash -c '</dev/tcp/%s/%d'", dialTimeout, other.private.Address, other.private.Port)
+		if _, err := t.run(ctx, command); err != nil {
+			t.unreachable = append(t.unreachable, fmt.Sprintf("%s (%s:%d)", other.name, other.private.Address, other.private.Port))
+		}
+	}
+}
+
+// checkPrivateNetwork checks each machine can connect to the private address
+// of the others, as probed by probePrivateNetwork.
+func checkPrivateNetwork(r *Report, targets []*target) {
+	for _, t := range targets {
+		if len(t.unreachable) > 0 {
+			r.add(t.name, "private-network", Fail, "cannot reach %s", strings.Join(t.unreachable, ", "))
+			continue
+		}
+		r.add(t.name, "private-network", Pass, "reaches the other machines")
+	}
+}
+
+// parseAvailableDisk returns the available space, in bytes, from the output
+// of df -Pk.
+func parseAvailableDisk(out string) (int64, error) {
+	lines := strings.Split(out, "\n")
+	fields := strings.Fields(lines[len(lines)-1])
+	if len(lines) < 2 || len(fields) < 4 {
+		return 0, errors.Errorf("unexpected output of df: %q", out)
+	}
+	kib, err := strconv.ParseInt(fields[3], 10, 64)
+	if err != nil {
+		return 0, errors.Wrapf(err, "unexpected output of df: %q", out)
+	}
+	return kib * 1024, nil
+}
+
+// parseTotalMemory returns the total memory, in bytes, from the contents of
+// /proc/meminfo.
+func parseTotalMemory(meminfo string) (int64, error) {
+	scanner := bufio.NewScanner(strings.NewReader(meminfo))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 3 && fields[0] == "MemTotal:" && fields[2] == "kB" {
+			kib, err := strconv.ParseInt(fields[1], 10, 64)
+			if err != nil {
+				return 0, errors.Wrapf(err, "unexpected total memory %q", scanner.Text())
+			}
+			return kib * 1024, nil
+		}
+	}
+	return 0, errors.New("no total memory in /proc/meminfo")
+}
+
+// parseListeningPorts returns the ports listened on from the contents of
+// /proc/net/tcp and /proc/net/tcp6.
+func parseListeningPorts(procNetTCP string) map[int]bool {
+	const listen = "0A"
+	ports := map[int]bool{}
+	scanner := bufio.NewScanner(strings.NewReader(procNetTCP))
+	for scanner.Scan() {
+		// sl local_address rem_address st ...
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 4 || fields[3] != listen {
+			continue
+		}
+		colon := strings.LastIndex(fields[1], ":")
+		if colon < 0 {
+			continue
+		}
+		port, err := strconv.ParseUint(fields[1][colon+1:], 16, 16)
+		if err != nil {
+			continue
+		}
+		ports[int(port)] = true
+	}
+	return ports
+}
+
+func formatBytes(n int64) string {
+	switch {
+	case n >= gib:
+		return fmt.Sprintf("%.1f GiB", float64(n)/gib)
+	case n >= mib:
+		return fmt.Sprintf("%.1f MiB", float64(n)/mib)
+	default:
+		return fmt.Sprintf("%d B", n)
+	}
+}
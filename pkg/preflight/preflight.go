@@ -0,0 +1,154 @@
+package preflight
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	"github.com/pkg/errors"
+	existinginfrav1 "github.com/weaveworks/cluster-api-provider-existinginfra/apis/cluster.weave.works/v1alpha3"
+	capeimachine "github.com/weaveworks/cluster-api-provider-existinginfra/pkg/cluster/machine"
+	"github.com/weaveworks/cluster-api-provider-existinginfra/pkg/plan"
+	"github.com/weaveworks/wksctl/pkg/plan/runners/ssh"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha3"
+)
+
+// Status is the outcome of a check.
+type Status string
+
+const (
+	// Pass means the machine is fit.
+	Pass Status = "pass"
+	// Warn means the machine can be set up, but may not behave as expected.
+	Warn Status = "warn"
+	// Fail means setting up the machine would fail.
+	Fail Status = "fail"
+)
+
+// Result is the outcome of a check of a machine.
+type Result struct {
+	Machine string `json:"machine"`
+	Check   string `json:"check"`
+	Status  Status `json:"status"`
+	Message string `json:"message,omitempty"`
+}
+
+// Report holds the results of the checks of the machines, in the order of the
+// machines.
+type Report struct {
+	Results []Result `json:"results"`
+}
+
+// Failed returns whether any check failed.
+func (r *Report) Failed() bool {
+	for _, result := range r.Results {
+		if result.Status == Fail {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *Report) add(machine, check string, status Status, format string, args ...interface{}) {
+	r.Results = append(r.Results, Result{Machine: machine, Check: check, Status: status, Message: fmt.Sprintf(format, args...)})
+}
+
+// ValidateOutputFormat checks the provided report output format is supported.
+func ValidateOutputFormat(output string) error {
+	switch output {
+	case "table", "json":
+		return nil
+	default:
+		return errors.Errorf("invalid output format %q, expected table or json", output)
+	}
+}
+
+// Write writes the report to w, in the requested output format.
+func (r *Report) Write(w io.Writer, output string) error {
+	switch output {
+	case "table":
+		const tabWidth = 4
+		tw := tabwriter.NewWriter(w, 0, 0, tabWidth, ' ', 0)
+		fmt.Fprintln(tw, "MACHINE\tCHECK\tSTATUS\tMESSAGE")
+		for _, result := range r.Results {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", result.Machine, result.Check, result.Status, result.Message)
+		}
+		return tw.Flush()
+	case "json":
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(r)
+	default:
+		return ValidateOutputFormat(output)
+	}
+}
+
+// Connector connects to the provided machine, and returns the runner of
+// commands on it and the function closing the connection.
+type Connector func(m *existinginfrav1.ExistingInfraMachine) (plan.Runner, func(), error)
+
+// SSHConnector returns the connector reaching the machines of the provided
+// cluster by SSH, as the provided user.
+func SSHConnector(o *ssh.Options, cluster *clusterv1.Cluster, user string) Connector {
+	return func(m *existinginfrav1.ExistingInfraMachine) (plan.Runner, func(), error) {
+		client, err := o.NewClientForMachine(cluster, &m.Spec, user, false)
+		if err != nil {
+			return nil, nil, err
+		}
+		return client, func() { client.Close() }, nil
+	}
+}
+
+// Check connects to the provided machines and checks they can be set up, with
+// the checks of each machine first and the checks across machines last.
+// Machines are paired with the ExistingInfraMachine their infrastructure
+// reference names, and connected to one after the other.
+func Check(ctx context.Context, machines []*clusterv1.Machine, eiMachines []*existinginfrav1.ExistingInfraMachine, connect Connector) *Report {
+	r := &Report{}
+	eiMachinesByName := map[string]*existinginfrav1.ExistingInfraMachine{}
+	for _, eiMachine := range eiMachines {
+		eiMachinesByName[eiMachine.Name] = eiMachine
+	}
+	var targets []*target
+	for _, machine := range machines {
+		ref := machine.Spec.InfrastructureRef.Name
+		eiMachine, ok := eiMachinesByName[ref]
+		if !ok {
+			r.add(machine.Name, "manifest", Fail, "no ExistingInfraMachine %q, which the machine refers to", ref)
+			continue
+		}
+		targets = append(targets, &target{name: machine.Name, master: capeimachine.IsMaster(machine), eiMachine: eiMachine, private: eiMachine.Spec.Private})
+	}
+	var reached []*target
+	for _, t := range targets {
+		if checkMachine(ctx, r, t, targets, connect) {
+			reached = append(reached, t)
+		}
+	}
+	checkUnique(r, reached, "hostname", Fail, func(t *target) string { return t.hostname })
+	checkUnique(r, reached, "product-uuid", Warn, func(t *target) string { return t.productUUID })
+	checkClocks(r, reached)
+	checkPrivateNetwork(r, reached)
+	return r
+}
+
+// checkMachine connects to the machine of the target, runs its checks and
+// probes the private network from it, before closing the connection, for a
+// single machine to be connected to at a time. It returns whether the machine
+// was reached.
+func checkMachine(ctx context.Context, r *Report, t *target, targets []*target, connect Connector) bool {
+	runner, closeConnection, err := connect(t.eiMachine)
+	if err != nil {
+		r.add(t.name, "reachability", Fail, "%v", err)
+		return false
+	}
+	defer closeConnection()
+	r.add(t.name, "reachability", Pass, "reached %s:%d", t.eiMachine.Spec.Public.Address, t.eiMachine.Spec.Public.Port)
+	t.runner = runner
+	defer func() { t.runner, t.sudo = nil, nil }()
+	t.check(ctx, r)
+	t.probePrivateNetwork(ctx, targets)
+	return true
+}
@@ -0,0 +1,269 @@
+package preflight
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	existinginfrav1 "github.com/weaveworks/cluster-api-provider-existinginfra/apis/cluster.weave.works/v1alpha3"
+	"github.com/weaveworks/cluster-api-provider-existinginfra/pkg/plan"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha3"
+)
+
+const procNetTCP = `  sl  local_address rem_address   st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode
+   0: 00000000:0016 00000000:0000 0A 00000000:00000000 00:00000000 00000000     0        0 16123 1 0000000000000000 100 0 0 10 0
+   1: 0100007F:2AF8 0100007F:9C40 01 00000000:00000000 00:00000000 00000000     0        0 16124 1 0000000000000000 100 0 0 10 0
+  sl  local_address                         remote_address                        st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode
+   0: 00000000000000000000000000000000:1922 00000000000000000000000000000000:0000 0A 00000000:00000000 00:00000000 00000000     0        0 17345 1 0000000000000000 100 0 0 10 0
+`
+
+// machine answers the commands of the checks like a fit CentOS machine, unless
+// told otherwise by overrides.
+type machine struct {
+	overrides map[string]string
+	failures  map[string]bool
+	clockSkew time.Duration
+}
+
+func (m *machine) RunCommand(_ context.Context, command string, _ io.Reader) (string, error) {
+	const sudo = "sudo -n -- sh -c "
+	if strings.HasPrefix(command, sudo) {
+		command = strings.Trim(strings.TrimPrefix(command, sudo), "'")
+	}
+	if m.failures[command] {
+		return "", &plan.RunError{ExitCode: 1}
+	}
+	if out, ok := m.overrides[command]; ok {
+		return out, nil
+	}
+	switch {
+	case command == "sudo -n true", strings.HasPrefix(command, "timeout "):
+		return "", nil
+	case command == "cat /etc/*release":
+		return "NAME=\"CentOS Linux\"\nID=\"centos\"\n", nil
+	case command == "cat /proc/swaps":
+		return "Filename\tType\tSize\tUsed\tPriority\n", nil
+	case command == "df -Pk /var/lib":
+		return "Filesystem     1024-blocks    Used Available Capacity Mounted on\n/dev/sda1         41152736 8123456  31234567      21% /\n", nil
+	case command == "cat /proc/meminfo":
+		return "MemTotal:        3880180 kB\nMemFree:          211584 kB\n", nil
+	case command == "test -e /etc/kubernetes/kubelet.conf":
+		return "", &plan.RunError{ExitCode: 1}
+	case command == "cat /proc/net/tcp /proc/net/tcp6":
+		return procNetTCP, nil
+	case command == "hostname":
+		return "localhost.localdomain\n", nil
+	case command == "cat /sys/class/dmi/id/product_uuid":
+		return "4C4C4544-0038-3310-8052-B4C04F384D32\n", nil
+	case command == "date +%s.%N":
+		now := time.Now().Add(m.clockSkew)
+		return fmt.Sprintf("%d.%09d\n", now.Unix(), now.Nanosecond()), nil
+	}
+	return "", errors.Errorf("unexpected command %q", command)
+}
+
+func manifests(names ...string) ([]*clusterv1.Machine, []*existinginfrav1.ExistingInfraMachine) {
+	var machines []*clusterv1.Machine
+	var eiMachines []*existinginfrav1.ExistingInfraMachine
+	for i, name := range names {
+		set := "worker"
+		if i == 0 {
+			set = "master"
+		}
+		machines = append(machines, &clusterv1.Machine{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Labels: map[string]string{"set": set}},
+			Spec:       clusterv1.MachineSpec{InfrastructureRef: corev1.ObjectReference{Kind: "ExistingInfraMachine", Name: name}},
+		})
+		eiMachines = append(eiMachines, &existinginfrav1.ExistingInfraMachine{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Spec: existinginfrav1.MachineSpec{
+				Public:  existinginfrav1.EndPoint{Address: "127.0.0.1", Port: uint16(2222 + i)},
+				Private: existinginfrav1.EndPoint{Address: fmt.Sprintf("172.17.8.%d", 101+i), Port: 22},
+			},
+		})
+	}
+	return machines, eiMachines
+}
+
+// connector connects to the provided machines, and counts the connections
+// open at the same time in the provided counters.
+func connector(runners map[string]*machine, counters ...*connections) Connector {
+	return func(m *existinginfrav1.ExistingInfraMachine) (plan.Runner, func(), error) {
+		runner, ok := runners[m.Name]
+		if !ok {
+			return nil, nil, errors.New("connection refused")
+		}
+		for _, c := range counters {
+			c.open()
+		}
+		return runner, func() {
+			for _, c := range counters {
+				c.close()
+			}
+		}, nil
+	}
+}
+
+type connections struct {
+	current, max int
+}
+
+func (c *connections) open() {
+	c.current++
+	if c.current > c.max {
+		c.max = c.current
+	}
+}
+
+func (c *connections) close() {
+	c.current--
+}
+
+func results(r *Report, status Status) []string {
+	var out []string
+	for _, result := range r.Results {
+		if result.Status == status {
+			out = append(out, result.Machine+"/"+result.Check)
+		}
+	}
+	return out
+}
+
+func TestCheckFitMachines(t *testing.T) {
+	machines, eiMachines := manifests("master-0", "worker-0")
+	r := Check(context.Background(), machines, eiMachines, connector(map[string]*machine{
+		"master-0": {overrides: map[string]string{"hostname": "master-0"}},
+		"worker-0": {overrides: map[string]string{"hostname": "worker-0", "cat /sys/class/dmi/id/product_uuid": "other-uuid"}},
+	}))
+	assert.False(t, r.Failed())
+	assert.Empty(t, results(r, Warn))
+	assert.Len(t, results(r, Pass), 2*11)
+}
+
+func TestCheckConnectsToOneMachineAtATime(t *testing.T) {
+	machines, eiMachines := manifests("master-0", "worker-0", "worker-1")
+	c := &connections{}
+	r := Check(context.Background(), machines, eiMachines, connector(map[string]*machine{
+		"master-0": {overrides: map[string]string{"hostname": "master-0"}},
+		"worker-0": {overrides: map[string]string{"hostname": "worker-0"}},
+		"worker-1": {overrides: map[string]string{"hostname": "worker-1"}},
+	}, c))
+	assert.Equal(t, 1, c.max)
+	assert.Equal(t, 0, c.current)
+	assert.Contains(t, results(r, Pass), "worker-1/private-network")
+}
+
+func TestCheckUnpairedMachines(t *testing.T) {
+	machines, eiMachines := manifests("master-0", "worker-0", "worker-1")
+	machines[1].Spec.InfrastructureRef.Name = "worker-9"
+	// Machines are paired by name, not by position.
+	eiMachines[0], eiMachines[2] = eiMachines[2], eiMachines[0]
+	r := Check(context.Background(), machines, eiMachines[:2], connector(map[string]*machine{
+		"master-0": {overrides: map[string]string{"hostname": "master-0"}},
+		"worker-1": {overrides: map[string]string{"hostname": "worker-1"}},
+	}))
+	assert.ElementsMatch(t, []string{"worker-0/manifest", "master-0/manifest"}, results(r, Fail))
+	assert.Contains(t, results(r, Pass), "worker-1/reachability")
+}
+
+func TestCheckUnfitMachines(t *testing.T) {
+	machines, eiMachines := manifests("master-0", "worker-0", "worker-1", "worker-2")
+	r := Check(context.Background(), machines, eiMachines, connector(map[string]*machine{
+		"master-0": {
+			overrides: map[string]string{
+				"cat /proc/net/tcp /proc/net/tcp6": procNetTCP + "   2: 00000000:192B 00000000:0000 0A 00000000:00000000 00:00000000 00000000     0        0 16123 1\n",
+				"cat /proc/meminfo":                "MemTotal:        1015812 kB\n",
+			},
+			failures: map[string]bool{"timeout 5 bash -c '</dev/tcp/172.17.8.104/22'": true},
+		},
+		"worker-0": {
+			overrides: map[string]string{"cat /etc/*release": "ID=arch\n", "cat /proc/swaps": "Filename\tType\tSize\tUsed\tPriority\n/dev/sda2 partition 2097148 0 -2\n"},
+			failures:  map[string]bool{"sudo -n true": true},
+			clockSkew: -time.Minute,
+		},
+		"worker-2": {overrides: map[string]string{"hostname": "worker-2"}},
+	}))
+	assert.True(t, r.Failed())
+	assert.ElementsMatch(t, []string{
+		"master-0/ports",
+		"master-0/hostname",
+		"master-0/private-network",
+		"worker-0/sudo",
+		"worker-0/os",
+		"worker-0/hostname",
+		"worker-0/clock",
+		"worker-1/reachability",
+	}, results(r, Fail))
+	assert.ElementsMatch(t, []string{
+		"master-0/memory",
+		"master-0/product-uuid",
+		"worker-0/swap",
+		"worker-2/product-uuid",
+	}, results(r, Warn))
+
+	for _, result := range r.Results {
+		switch result.Machine + "/" + result.Check {
+		case "master-0/ports":
+			assert.Equal(t, "port(s) 6443, which Kubernetes needs, already in use", result.Message)
+		case "master-0/private-network":
+			assert.Equal(t, "cannot reach worker-2 (172.17.8.104:22)", result.Message)
+		case "worker-0/hostname":
+			assert.Equal(t, "localhost.localdomain is also the one of master-0", result.Message)
+		}
+	}
+}
+
+func TestCheckExistingNodes(t *testing.T) {
+	machines, eiMachines := manifests("master-0")
+	r := Check(context.Background(), machines, eiMachines, connector(map[string]*machine{
+		"master-0": {overrides: map[string]string{
+			"test -e /etc/kubernetes/kubelet.conf": "",
+			"cat /proc/net/tcp /proc/net/tcp6":     "   0: 00000000:192B 00000000:0000 0A\n",
+		}},
+	}))
+	assert.False(t, r.Failed())
+}
+
+func TestParse(t *testing.T) {
+	ports := parseListeningPorts(procNetTCP)
+	assert.Equal(t, map[int]bool{22: true, 6434: true}, ports)
+
+	_, err := parseAvailableDisk("df: /var/lib: No such file or directory")
+	assert.Error(t, err)
+	_, err = parseTotalMemory("MemFree: 211584 kB\n")
+	assert.Error(t, err)
+
+	assert.Equal(t, "1.5 GiB", formatBytes(3*gib/2))
+	assert.Equal(t, "992.0 MiB", formatBytes(992*mib))
+}
+
+func TestWrite(t *testing.T) {
+	r := &Report{}
+	r.add("master-0", "sudo", Pass, "passwordless")
+	r.add("master-0", "ports", Fail, "port(s) %d already in use", 6443)
+
+	var out bytes.Buffer
+	require.NoError(t, r.Write(&out, "table"))
+	assert.Equal(t, `MACHINE     CHECK    STATUS    MESSAGE
+master-0    sudo     pass      passwordless
+master-0    ports    fail      port(s) 6443 already in use
+`, out.String())
+
+	out.Reset()
+	require.NoError(t, r.Write(&out, "json"))
+	var decoded Report
+	require.NoError(t, json.Unmarshal(out.Bytes(), &decoded))
+	assert.Equal(t, r, &decoded)
+
+	assert.Error(t, r.Write(&out, "yaml"))
+}
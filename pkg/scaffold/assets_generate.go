@@ -0,0 +1,21 @@
+// +build ignore
+
+package main
+
+import (
+	"log"
+
+	"github.com/shurcooL/vfsgen"
+	"github.com/weaveworks/wksctl/pkg/scaffold"
+)
+
+func main() {
+	err := vfsgen.Generate(scaffold.Templates, vfsgen.Options{
+		PackageName:  "scaffold",
+		BuildTags:    "!dev",
+		VariableName: "Templates",
+	})
+	if err != nil {
+		log.Fatalln(err)
+	}
+}
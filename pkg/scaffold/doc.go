@@ -0,0 +1,5 @@
+//go:generate go run -tags=dev assets_generate.go
+
+// Package scaffold contains the templates of the starter repository created by
+// 'wksctl init --scaffold'.
+package scaffold
@@ -0,0 +1,12 @@
+// +build dev
+
+package scaffold
+
+import (
+	"net/http"
+
+	"github.com/weaveworks/cluster-api-provider-existinginfra/pkg/utilities/fixeddate"
+)
+
+// Templates contains the templates of the starter repository.
+var Templates http.FileSystem = fixeddate.Dir("templates")
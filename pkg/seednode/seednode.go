@@ -0,0 +1,264 @@
+package seednode
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/pflag"
+	"github.com/weaveworks/cluster-api-provider-existinginfra/pkg/apis/wksprovider/machine/config"
+	capeios "github.com/weaveworks/cluster-api-provider-existinginfra/pkg/apis/wksprovider/machine/os"
+	"github.com/weaveworks/cluster-api-provider-existinginfra/pkg/cluster/machine"
+	"github.com/weaveworks/cluster-api-provider-existinginfra/pkg/plan"
+	capeispecs "github.com/weaveworks/cluster-api-provider-existinginfra/pkg/specs"
+	"github.com/weaveworks/cluster-api-provider-existinginfra/pkg/utilities/kubeadm"
+	"github.com/weaveworks/wksctl/pkg/addons"
+	wksos "github.com/weaveworks/wksctl/pkg/apis/wksprovider/machine/os"
+	"github.com/weaveworks/wksctl/pkg/manifests"
+	"github.com/weaveworks/wksctl/pkg/utilities"
+	"github.com/weaveworks/wksctl/pkg/utilities/manifest"
+	kubeadmapi "k8s.io/kubernetes/cmd/kubeadm/app/apis/kubeadm/v1beta1"
+)
+
+// Options groups the user-provided settings, on top of the cluster and
+// machines manifests, which shape the seed node plan.
+type Options struct {
+	ControllerImage  string
+	GitURL           string
+	GitBranch        string
+	GitPath          string
+	GitDeployKeyPath string
+	// GitClone only shapes how wksctl clones and verifies the repository,
+	// which the cluster syncs with by branch.
+	GitClone manifests.GitOptions
+	// ControllerSSHKeyPath is the path to the key the controller logs in to
+	// machines with. No key is sent to the cluster if it is empty.
+	ControllerSSHKeyPath string
+	SealedSecretKeyPath  string
+	SealedSecretCertPath string
+	ConfigDirectory      string
+	Namespace            string
+	UseManifestNamespace bool
+	AddonNamespaces      []string
+	// BootstrapToken is the token used by kubeadm init and kubeadm join. A
+	// new token is generated if it is nil.
+	BootstrapToken *kubeadmapi.BootstrapTokenString
+}
+
+// AddFlags registers the flags setting these options, apart from the hidden
+// --controller-image flag, which commands register themselves.
+func (o *Options) AddFlags(fs *pflag.FlagSet) {
+	o.AddPlanFlags(fs)
+	manifests.AddGitCloneFlags(fs, &o.GitClone)
+}
+
+// AddPlanFlags registers the flags setting the options which shape the plan,
+// i.e. all but those cloning the repository, for commands which don't clone it.
+func (o *Options) AddPlanFlags(fs *pflag.FlagSet) {
+	fs.StringVar(&o.GitURL, "git-url", "", "Git repo containing your cluster and machine information")
+	fs.StringVar(&o.GitBranch, "git-branch", "master", "Git branch WKS should use to sync with your cluster")
+	fs.StringVar(&o.GitPath, "git-path", ".", "Relative path to files in Git")
+	fs.StringVar(&o.GitDeployKeyPath, "git-deploy-key", "", "Path to the Git deploy key")
+	fs.StringVar(&o.ControllerSSHKeyPath, "controller-ssh-key", "", "Path to a key, without passphrase, the controller uses to log in to machines by SSH and set up the machines other than the seed node (required by apply when there are other machines)")
+	fs.StringVar(&o.SealedSecretKeyPath, "sealed-secret-key", "", "Path to a key used to decrypt sealed secrets")
+	fs.StringVar(&o.SealedSecretCertPath, "sealed-secret-cert", "", "Path to a certificate used to encrypt sealed secrets")
+	fs.StringVar(&o.ConfigDirectory, "config-directory", ".", "Directory containing configuration information for the cluster")
+	fs.StringVar(&o.Namespace, "namespace", manifest.DefaultNamespace, "namespace override for WKS components")
+	fs.BoolVar(&o.UseManifestNamespace, "use-manifest-namespace", false, "use namespaces from supplied manifests (overriding any --namespace argument)")
+	fs.StringSliceVar(&o.AddonNamespaces, "addon-namespace", []string{"weave-net=kube-system"}, "override namespace for specific addons")
+}
+
+// Git returns the options cloning the repository holding the manifests.
+func (o *Options) Git() manifests.GitOptions {
+	git := o.GitClone
+	git.URL = o.GitURL
+	git.Branch = o.GitBranch
+	git.Path = o.GitPath
+	git.DeployKeyPath = o.GitDeployKeyPath
+	return git
+}
+
+// Plan builds the plan which sets up the seed node of a cluster, i.e. the
+// first master, described by the provided specs and manifests, on the machine
+// installer talks to. Both "wksctl apply" and "wksctl plan view" go through
+// this code, so that the plan we look at is the plan we apply.
+func Plan(ctx context.Context, installer *capeios.OS, sp *capeispecs.Specs, clusterManifestPath, machinesManifestPath string, o Options) (*plan.Plan, error) {
+	params, err := Params(sp, clusterManifestPath, machinesManifestPath, o)
+	if err != nil {
+		return nil, err
+	}
+	return wksos.CreateSeedNodePlan(ctx, installer, params)
+}
+
+// Params computes the parameters of the seed node plan.
+func Params(sp *capeispecs.Specs, clusterManifestPath, machinesManifestPath string, o Options) (capeios.SeedNodeParams, error) {
+	token := o.BootstrapToken
+	if token == nil {
+		// N.B.: we generate this bootstrap token where wksctl is run hoping
+		// that this will be on a machine which has been running for a while,
+		// and therefore will generate a "more random" token, than we would on
+		// a potentially newly created VM which doesn't have much entropy yet.
+		var err error
+		token, err = kubeadm.GenerateBootstrapToken()
+		if err != nil {
+			return capeios.SeedNodeParams{}, errors.Wrap(err, "failed to generate bootstrap token")
+		}
+	}
+
+	// Point config dir at sync repo if using github and the user didn't override it
+	configDir := o.ConfigDirectory
+	if configDir == "." && o.GitURL != "" {
+		configDir = filepath.Dir(clusterManifestPath)
+	}
+
+	ns := ""
+	if !o.UseManifestNamespace {
+		ns = o.Namespace
+	}
+
+	addonNamespaces, err := parseAddonNamespaces(o.AddonNamespaces)
+	if err != nil {
+		return capeios.SeedNodeParams{}, err
+	}
+
+	sealedSecretKeyPath := o.SealedSecretKeyPath
+	if sealedSecretKeyPath == "" {
+		// Default to using the git deploy key to decrypt sealed secrets
+		sealedSecretKeyPath = o.GitDeployKeyPath
+	}
+
+	// TODO(damien): Transform the controller image into an addon.
+	controllerImage := o.ControllerImage
+	if controllerImage != "" {
+		controllerImage, err = addons.UpdateImage(o.ControllerImage, sp.ClusterSpec.ImageRepository)
+		if err != nil {
+			return capeios.SeedNodeParams{}, errors.Wrap(err, "failed to apply the cluster's image repository to the WKS controller's image")
+		}
+	}
+
+	clusterManifest, err := ioutil.ReadFile(clusterManifestPath)
+	if err != nil {
+		return capeios.SeedNodeParams{}, errors.Wrap(err, "failed to read cluster manifest: ")
+	}
+
+	// Read manifests and pass in the contents
+	machinesManifest, err := ioutil.ReadFile(machinesManifestPath)
+	if err != nil {
+		return capeios.SeedNodeParams{}, errors.Wrap(err, "failed to read machines manifest: ")
+	}
+
+	cluster, eic, err := capeispecs.ParseCluster(ioutil.NopCloser(bytes.NewReader(clusterManifest)))
+	if err != nil {
+		return capeios.SeedNodeParams{}, errors.Wrap(err, "failed to parse cluster manifest: ")
+	}
+
+	// Allow for versions to be on machines only (for now)
+	if eic.Spec.KubernetesVersion == "" {
+		machines, _, err := machine.Parse(ioutil.NopCloser(bytes.NewReader(machinesManifest)))
+		if err != nil {
+			return capeios.SeedNodeParams{}, errors.Wrap(err, "failed to parse machine manifest: ")
+		}
+
+		eic.Spec.KubernetesVersion = *machines[0].Spec.Version
+	}
+
+	if o.ControllerSSHKeyPath == "" && sp.GetMachineCount() > 1 {
+		log.Warn("No key was provided with --controller-ssh-key: the controller won't be able to set up the machines other than the seed node")
+	}
+	eic.Spec.DeprecatedSSHKeyPath = o.ControllerSSHKeyPath
+	clusterManifest, err = wksos.UnparseCluster(cluster, eic)
+	if err != nil {
+		return capeios.SeedNodeParams{}, errors.Wrap(err, "failed to annotate cluster manifest: ")
+	}
+
+	// Read sealed secret cert and key
+	var cert []byte
+	var key []byte
+	if utilities.FileExists(o.SealedSecretCertPath) && utilities.FileExists(sealedSecretKeyPath) {
+		cert, err = ioutil.ReadFile(o.SealedSecretCertPath)
+		if err != nil {
+			return capeios.SeedNodeParams{}, errors.Wrap(err, "failed to read sealed secret certificate: ")
+		}
+
+		key, err = ioutil.ReadFile(sealedSecretKeyPath)
+		if err != nil {
+			return capeios.SeedNodeParams{}, errors.Wrap(err, "failed to read sealed secret key: ")
+		}
+	}
+
+	return capeios.SeedNodeParams{
+		PublicIP:             sp.GetMasterPublicAddress(),
+		PrivateIP:            sp.GetMasterPrivateAddress(),
+		ServicesCIDRBlocks:   sp.Cluster.Spec.ClusterNetwork.Services.CIDRBlocks,
+		PodsCIDRBlocks:       sp.Cluster.Spec.ClusterNetwork.Pods.CIDRBlocks,
+		ExistingInfraCluster: *eic,
+		ClusterManifest:      string(clusterManifest),
+		MachinesManifest:     string(machinesManifest),
+		BootstrapToken:       token,
+		KubeletConfig: config.KubeletConfig{
+			NodeIP:         sp.GetMasterPrivateAddress(),
+			CloudProvider:  sp.GetCloudProvider(),
+			ExtraArguments: sp.GetKubeletArguments(),
+		},
+		Controller: capeios.ControllerParams{
+			ImageOverride: controllerImage,
+		},
+		GitData: capeios.GitParams{
+			GitURL:           o.GitURL,
+			GitBranch:        o.GitBranch,
+			GitPath:          o.GitPath,
+			GitDeployKeyPath: o.GitDeployKeyPath,
+		},
+		SealedSecretKey:      string(key),
+		SealedSecretCert:     string(cert),
+		ConfigDirectory:      configDir,
+		ImageRepository:      sp.ClusterSpec.ImageRepository,
+		ControlPlaneEndpoint: sp.ClusterSpec.ControlPlaneEndpoint,
+		AdditionalSANs:       sp.ClusterSpec.APIServer.AdditionalSANs,
+		Namespace:            ns,
+		AddonNamespaces:      addonNamespaces,
+		Flavor:               sp.ClusterSpec.Flavor,
+	}, nil
+}
+
+func parseAddonNamespaces(entries []string) (map[string]string, error) {
+	addonNamespaces := map[string]string{}
+	for _, entry := range entries {
+		parts := strings.SplitN(entry, "=", 2)
+		if len(parts) != 2 {
+			return nil, errors.Errorf("failed to validate the addon namespace (%s)", entry)
+		}
+		addonNamespaces[parts[0]] = parts[1]
+	}
+	return addonNamespaces, nil
+}
+
+// ValidateOutputFormat checks the provided plan output format is supported.
+func ValidateOutputFormat(output string) error {
+	switch output {
+	case "dot", "json":
+		return nil
+	default:
+		return errors.Errorf("invalid output format %q, expected dot or json", output)
+	}
+}
+
+// WritePlan writes the provided plan to w, in the requested output format.
+func WritePlan(w io.Writer, p *plan.Plan, output string) error {
+	switch output {
+	case "dot":
+		_, err := fmt.Fprintln(w, p.ToDOT())
+		return err
+	case "json":
+		_, err := fmt.Fprintln(w, p.ToHumanReadableJSON())
+		return err
+	default:
+		return ValidateOutputFormat(output)
+	}
+}
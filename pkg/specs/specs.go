@@ -25,6 +25,13 @@ func NewFromPaths(clusterManifestPath, machinesManifestPath string) *specs.Specs
 	return specs.New(cluster, eic, machines, bml)
 }
 
+// Validate parses the cluster and machines manifests and returns an error if
+// they are invalid.
+func Validate(clusterManifestPath, machinesManifestPath string) error {
+	_, _, _, _, err := parseManifests(clusterManifestPath, machinesManifestPath)
+	return err
+}
+
 func parseManifests(clusterManifestPath, machinesManifestPath string) (*clusterv1.Cluster, *existinginfra1.ExistingInfraCluster, []*clusterv1.Machine, []*existinginfra1.ExistingInfraMachine, error) {
 	cluster, eic, err := ParseClusterManifest(clusterManifestPath)
 	if err != nil {
@@ -0,0 +1,146 @@
+package logging
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/pflag"
+	"golang.org/x/crypto/ssh/terminal"
+)
+
+// Formats of logs.
+const (
+	TextFormat = "text"
+	JSONFormat = "json"
+)
+
+// Levels are the levels logs can be printed at, from the most verbose.
+var Levels = []string{"trace", "debug", "info", "warn", "error"}
+
+// consoleLevel is the level of the logs printed on the console, which may be
+// less verbose than the level of the logs kept in a file.
+var consoleLevel = log.InfoLevel
+
+// Options configure the logs of all commands.
+type Options struct {
+	Format  string
+	Level   string
+	File    string
+	Verbose bool
+}
+
+// AddFlags adds the logging flags to the provided flag set.
+func (o *Options) AddFlags(fs *pflag.FlagSet) {
+	fs.StringVar(&o.Format, "log-format", TextFormat, "Format of the logs (text|json)")
+	fs.StringVar(&o.Level, "log-level", "info", "Level of the logs printed on the console ("+strings.Join(Levels, "|")+")")
+	fs.StringVar(&o.File, "log-file", "", "Path to a file the logs are also written to, at debug level or the level of the console if more verbose")
+	fs.BoolVarP(&o.Verbose, "verbose", "v", false, "Enable verbose output, as --log-level=debug")
+}
+
+func parseLevel(level string) (log.Level, error) {
+	for _, l := range Levels {
+		if l == level {
+			return log.ParseLevel(level)
+		}
+	}
+	return 0, errors.Errorf("invalid log level %q, expected one of %s", level, strings.Join(Levels, ", "))
+}
+
+func formatter(format string) (log.Formatter, error) {
+	switch format {
+	case TextFormat:
+		return &log.TextFormatter{FullTimestamp: true}, nil
+	case JSONFormat:
+		return &log.JSONFormatter{}, nil
+	default:
+		return nil, errors.Errorf("invalid log format %q, expected text or json", format)
+	}
+}
+
+// Configure configures the standard logger, to print logs on the standard
+// error at the requested level, and to also write them to the requested file.
+func (o *Options) Configure() error {
+	level, err := parseLevel(o.Level)
+	if err != nil {
+		return err
+	}
+	if o.Verbose && level < log.DebugLevel {
+		level = log.DebugLevel
+	}
+	consoleFormatter, err := formatter(o.Format)
+	if err != nil {
+		return err
+	}
+	consoleLevel = level
+
+	if o.File == "" {
+		log.SetFormatter(consoleFormatter)
+		log.SetLevel(level)
+		return nil
+	}
+
+	file, err := os.OpenFile(o.File, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return errors.Wrap(err, "failed to open log file")
+	}
+	fileFormatter, _ := formatter(o.Format)
+	if text, ok := consoleFormatter.(*log.TextFormatter); ok {
+		// logrus can't tell the console is a terminal from the output of the
+		// logger, which is discarded.
+		text.ForceColors = terminal.IsTerminal(int(os.Stderr.Fd()))
+		text.DisableColors = !text.ForceColors
+		fileFormatter.(*log.TextFormatter).DisableColors = true
+	}
+	fileLevel := level
+	if fileLevel < log.DebugLevel {
+		fileLevel = log.DebugLevel
+	}
+	// Entries are logged at the level of the file, and printed on the
+	// console by a hook only up to the level of the console.
+	log.SetOutput(ioutil.Discard)
+	log.SetLevel(fileLevel)
+	log.AddHook(&writerHook{out: os.Stderr, formatter: consoleFormatter, levels: levelsUpTo(level)})
+	log.AddHook(&writerHook{out: file, formatter: fileFormatter, levels: levelsUpTo(fileLevel)})
+	return nil
+}
+
+// Verbose returns whether debug logs are printed on the console, in which case
+// commands also print the outputs of the commands they run on machines.
+func Verbose() bool {
+	return consoleLevel >= log.DebugLevel
+}
+
+func levelsUpTo(level log.Level) []log.Level {
+	var levels []log.Level
+	for _, l := range log.AllLevels {
+		if l <= level {
+			levels = append(levels, l)
+		}
+	}
+	return levels
+}
+
+// writerHook writes the entries of the provided levels to out. logrus fires
+// hooks with the lock of the logger held.
+type writerHook struct {
+	out       io.Writer
+	formatter log.Formatter
+	levels    []log.Level
+}
+
+func (h *writerHook) Levels() []log.Level {
+	return h.levels
+}
+
+func (h *writerHook) Fire(entry *log.Entry) error {
+	b, err := h.formatter.Format(entry)
+	if err != nil {
+		return err
+	}
+	_, err = h.out.Write(b)
+	return err
+}
@@ -0,0 +1,66 @@
+package logging
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func resetLogger() {
+	log.SetOutput(os.Stderr)
+	log.SetFormatter(&log.TextFormatter{})
+	log.SetLevel(log.InfoLevel)
+	log.StandardLogger().ReplaceHooks(make(log.LevelHooks))
+	consoleLevel = log.InfoLevel
+}
+
+func TestConfigure(t *testing.T) {
+	defer resetLogger()
+
+	assert.Error(t, (&Options{Format: "xml", Level: "info"}).Configure())
+	assert.Error(t, (&Options{Format: TextFormat, Level: "fatal"}).Configure())
+
+	require.NoError(t, (&Options{Format: JSONFormat, Level: "warn"}).Configure())
+	assert.Equal(t, log.WarnLevel, log.GetLevel())
+	assert.IsType(t, &log.JSONFormatter{}, log.StandardLogger().Formatter)
+	assert.False(t, Verbose())
+
+	require.NoError(t, (&Options{Format: TextFormat, Level: "info", Verbose: true}).Configure())
+	assert.Equal(t, log.DebugLevel, log.GetLevel())
+	assert.True(t, Verbose())
+}
+
+func TestConfigureLogFile(t *testing.T) {
+	defer resetLogger()
+	dir, err := ioutil.TempDir("", "wksctl-logging")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "wksctl.log")
+
+	require.NoError(t, (&Options{Format: JSONFormat, Level: "info", File: path}).Configure())
+	assert.False(t, Verbose())
+	assert.Equal(t, log.DebugLevel, log.GetLevel())
+	log.Debug("debug message")
+	log.Trace("trace message")
+	log.Info("info message")
+
+	contents, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(string(contents)), "\n")
+	require.Len(t, lines, 2)
+	var entry map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(lines[0]), &entry))
+	assert.Equal(t, "debug message", entry["msg"])
+	assert.Equal(t, "debug", entry["level"])
+}
+
+func TestLevelsUpTo(t *testing.T) {
+	assert.Equal(t, []log.Level{log.PanicLevel, log.FatalLevel, log.ErrorLevel, log.WarnLevel, log.InfoLevel}, levelsUpTo(log.InfoLevel))
+}
@@ -0,0 +1,55 @@
+package manifest
+
+import (
+	"strconv"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// SetAnnotation sets the provided annotation of the object of the provided
+// kind and name of the manifest, leaving the rest of it, including comments
+// and formatting, untouched.
+func SetAnnotation(contents []byte, kind, name, key, value string) ([]byte, error) {
+	f, err := ParseFile(contents)
+	if err != nil {
+		return nil, err
+	}
+	for _, object := range f.Objects {
+		if Scalar(object, "kind") != kind || Scalar(object, "metadata", "name") != name {
+			continue
+		}
+		if err := setAnnotation(f, Field(object, "metadata"), key, value); err != nil {
+			return nil, errors.Wrapf(err, "%s %s at line %d", kind, name, object.Line)
+		}
+		return f.Bytes()
+	}
+	return nil, errors.Errorf("no %s %s in manifest", kind, name)
+}
+
+func setAnnotation(f *File, metadata *yaml.Node, key, value string) error {
+	annotations := Field(metadata, "annotations")
+	if v := Field(annotations, key); v != nil {
+		if v.Kind != yaml.ScalarNode {
+			return errors.Errorf("annotation %s is not a string", key)
+		}
+		f.Set(annotations, v, value)
+		if v.Style&(yaml.DoubleQuotedStyle|yaml.SingleQuotedStyle) == 0 {
+			// Replace plain values, which might not be valid plain YAML,
+			// by quoted ones.
+			v.Style = yaml.DoubleQuotedStyle
+		}
+		return nil
+	}
+	line := key + ": " + strconv.Quote(value)
+	if annotations != nil {
+		if annotations.Kind != yaml.MappingNode || annotations.Style&yaml.FlowStyle != 0 || len(annotations.Content) == 0 {
+			return errors.New("cannot add to its annotations")
+		}
+		return f.InsertBefore(annotations.Content[0], line)
+	}
+	if metadata == nil || metadata.Style&yaml.FlowStyle != 0 || len(metadata.Content) == 0 {
+		return errors.New("cannot add annotations to its metadata")
+	}
+	return f.InsertBefore(metadata.Content[0], "annotations:", "  "+line)
+}
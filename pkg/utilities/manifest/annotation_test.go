@@ -0,0 +1,72 @@
+package manifest
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const annotatedMachines = `apiVersion: cluster.weave.works/v1alpha3
+kind: ExistingInfraMachine
+metadata:
+  name: master-1 # First.
+spec:
+  public:
+    address: 10.0.0.1
+---
+apiVersion: cluster.weave.works/v1alpha3
+kind: ExistingInfraMachine
+metadata:
+    annotations:
+        example.com/owner: ops
+    name: node-1
+---
+apiVersion: cluster.weave.works/v1alpha3
+kind: ExistingInfraMachine
+metadata:
+  name: node-2
+  annotations:
+    example.com/key: old value
+`
+
+func TestSetAnnotation(t *testing.T) {
+	tests := []struct {
+		name     string
+		expected string
+	}{
+		{name: "master-1", expected: `apiVersion: cluster.weave.works/v1alpha3
+kind: ExistingInfraMachine
+metadata:
+  annotations:
+    example.com/key: "ssh-ed25519 AAAA"
+  name: master-1 # First.
+`},
+		{name: "node-1", expected: `metadata:
+    annotations:
+        example.com/key: "ssh-ed25519 AAAA"
+        example.com/owner: ops
+    name: node-1
+`},
+		{name: "node-2", expected: `  name: node-2
+  annotations:
+    example.com/key: "ssh-ed25519 AAAA"
+`},
+	}
+	for _, test := range tests {
+		out, err := SetAnnotation([]byte(annotatedMachines), "ExistingInfraMachine", test.name, "example.com/key", "ssh-ed25519 AAAA")
+		require.NoError(t, err, test.name)
+		assert.Contains(t, string(out), test.expected, test.name)
+	}
+
+	// Quoted values are replaced whole, escapes included.
+	escaped := "kind: ExistingInfraMachine\nmetadata:\n  name: node-1\n  annotations:\n    example.com/key: \"old \\\"value\\\"\" # Set by apply.\n"
+	out, err := SetAnnotation([]byte(escaped), "ExistingInfraMachine", "node-1", "example.com/key", "ssh-ed25519 AAAA")
+	require.NoError(t, err)
+	assert.Equal(t, "kind: ExistingInfraMachine\nmetadata:\n  name: node-1\n  annotations:\n    example.com/key: \"ssh-ed25519 AAAA\" # Set by apply.\n", string(out))
+
+	_, err = SetAnnotation([]byte(annotatedMachines), "ExistingInfraMachine", "node-3", "example.com/key", "value")
+	assert.Error(t, err)
+	_, err = SetAnnotation([]byte("kind: ExistingInfraMachine\nmetadata: {name: node-1}\n"), "ExistingInfraMachine", "node-1", "example.com/key", "value")
+	assert.Error(t, err)
+}
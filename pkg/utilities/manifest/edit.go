@@ -0,0 +1,281 @@
+package manifest
+
+import (
+	"bytes"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/pkg/errors"
+	yaml "gopkg.in/yaml.v3"
+)
+
+// Manifests are edited in place: their YAML documents are parsed to find the
+// values to change, which are then replaced in the original text, so that
+// comments, ordering and formatting are left as they were.
+
+// File is a manifest being edited.
+type File struct {
+	contents []byte
+	// lines are the offsets of the start of each line of contents.
+	lines []int
+	// Objects are the Kubernetes objects of all the documents of the file,
+	// including the items of lists.
+	Objects    []*yaml.Node
+	edits      []scalarEdit
+	insertions []insertion
+}
+
+type insertion struct {
+	offset int
+	text   string
+}
+
+type scalarEdit struct {
+	node *yaml.Node
+	// flow is whether the node is in a flow collection.
+	flow bool
+	// style is the style the node was written in.
+	style    yaml.Style
+	original string
+	value    string
+}
+
+// ParseFile parses the manifest to edit.
+func ParseFile(contents []byte) (*File, error) {
+	f := &File{contents: contents, lines: []int{0}}
+	for i, c := range contents {
+		if c == '\n' {
+			f.lines = append(f.lines, i+1)
+		}
+	}
+	decoder := yaml.NewDecoder(bytes.NewReader(contents))
+	for {
+		var doc yaml.Node
+		if err := decoder.Decode(&doc); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, errors.Wrap(err, "failed to parse manifest")
+		}
+		if len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+			continue
+		}
+		object := doc.Content[0]
+		if items := Field(object, "items"); Scalar(object, "kind") == "List" && items != nil {
+			for _, item := range items.Content {
+				if item.Kind == yaml.MappingNode {
+					f.Objects = append(f.Objects, item)
+				}
+			}
+			continue
+		}
+		f.Objects = append(f.Objects, object)
+	}
+	return f, nil
+}
+
+// Field returns the value at the path of keys of the mapping, or nil.
+func Field(node *yaml.Node, path ...string) *yaml.Node {
+	for _, key := range path {
+		if node == nil || node.Kind != yaml.MappingNode {
+			return nil
+		}
+		var value *yaml.Node
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			if node.Content[i].Value == key {
+				value = node.Content[i+1]
+				break
+			}
+		}
+		node = value
+	}
+	return node
+}
+
+// Scalar returns the scalar value at the path of keys of the mapping, or "".
+func Scalar(node *yaml.Node, path ...string) string {
+	value := Field(node, path...)
+	if value == nil || value.Kind != yaml.ScalarNode {
+		return ""
+	}
+	return value.Value
+}
+
+// Set replaces the value of the scalar node, a child of parent. The value is
+// written in the style of the node, which can be changed once set to write it
+// in another one.
+func (f *File) Set(parent, node *yaml.Node, value string) {
+	if node.Value == value {
+		return
+	}
+	for i := range f.edits {
+		if f.edits[i].node == node {
+			f.edits[i].value = value
+			node.Value = value
+			return
+		}
+	}
+	f.edits = append(f.edits, scalarEdit{node: node, flow: parent.Style&yaml.FlowStyle != 0, style: node.Style, original: node.Value, value: value})
+	node.Value = value
+}
+
+// SetField replaces the scalar value at the path of keys of the mapping, if
+// present.
+func (f *File) SetField(node *yaml.Node, value string, path ...string) {
+	parent := Field(node, path[:len(path)-1]...)
+	if child := Field(parent, path[len(path)-1]); child != nil && child.Kind == yaml.ScalarNode {
+		f.Set(parent, child, value)
+	}
+}
+
+// Changed returns whether values of the file were replaced, or lines inserted.
+func (f *File) Changed() bool {
+	return len(f.edits) > 0 || len(f.insertions) > 0
+}
+
+// Bytes returns the contents of the file with the edits applied.
+func (f *File) Bytes() ([]byte, error) {
+	type span struct {
+		start, end int
+		text       string
+	}
+	var spans []span
+	for _, e := range f.edits {
+		start, end, err := f.span(e)
+		if err != nil {
+			return nil, err
+		}
+		spans = append(spans, span{start, end, formatScalar(e.value, e.node.Style, e.flow)})
+	}
+	for _, i := range f.insertions {
+		spans = append(spans, span{i.offset, i.offset, i.text})
+	}
+	sort.SliceStable(spans, func(i, j int) bool { return spans[i].start > spans[j].start })
+	contents := append([]byte(nil), f.contents...)
+	for _, s := range spans {
+		contents = append(contents[:s.start], append([]byte(s.text), contents[s.end:]...)...)
+	}
+	return contents, nil
+}
+
+// offset returns the offset of the start of the node in the file.
+func (f *File) offset(node *yaml.Node) (int, error) {
+	if node.Line < 1 || node.Line > len(f.lines) {
+		return 0, errors.Errorf("no line %d in manifest", node.Line)
+	}
+	start := f.lines[node.Line-1]
+	for column := 1; column < node.Column && start < len(f.contents); column++ {
+		_, size := utf8.DecodeRune(f.contents[start:])
+		start += size
+	}
+	return start, nil
+}
+
+// span returns the offsets of the text of the edited scalar in the file.
+func (f *File) span(e scalarEdit) (int, int, error) {
+	node := e.node
+	start, err := f.offset(node)
+	if err != nil {
+		return 0, 0, err
+	}
+	text := f.contents[start:]
+	switch {
+	case e.style&yaml.DoubleQuotedStyle != 0:
+		for i := 1; i < len(text); i++ {
+			switch text[i] {
+			case '\\':
+				i++
+			case '"':
+				return start, start + i + 1, nil
+			}
+		}
+	case e.style&yaml.SingleQuotedStyle != 0:
+		for i := 1; i < len(text); i++ {
+			if text[i] == '\'' {
+				if i+1 < len(text) && text[i+1] == '\'' {
+					i++
+					continue
+				}
+				return start, start + i + 1, nil
+			}
+		}
+	case e.style&(yaml.LiteralStyle|yaml.FoldedStyle) == 0:
+		end := bytes.IndexAny(text, "\r\n")
+		if end < 0 {
+			end = len(text)
+		}
+		if comment := bytes.Index(text[:end], []byte(" #")); comment >= 0 {
+			end = comment
+		}
+		if e.flow {
+			if i := bytes.IndexAny(text[:end], ",]}"); i >= 0 {
+				end = i
+			}
+		}
+		end = len(bytes.TrimRight(text[:end], " \t"))
+		// Plain scalars spanning lines are not edited.
+		if string(text[:end]) == e.original {
+			return start, start + end, nil
+		}
+	}
+	return 0, 0, errors.Errorf("cannot edit the value at line %d of the manifest", node.Line)
+}
+
+// formatScalar returns the YAML text of the value, in the style of the
+// scalar it replaces if possible.
+func formatScalar(value string, style yaml.Style, flow bool) string {
+	switch {
+	case style&yaml.SingleQuotedStyle != 0:
+		return "'" + strings.ReplaceAll(value, "'", "''") + "'"
+	case style&yaml.DoubleQuotedStyle == 0 && isPlain(value, flow):
+		return value
+	default:
+		return strconv.Quote(value)
+	}
+}
+
+// isPlain returns whether the value reads back as the same string when
+// written without quotes.
+func isPlain(value string, flow bool) bool {
+	if value == "" || strings.ContainsAny(value, "\n\r\t") || (flow && strings.ContainsAny(value, ",[]{}")) {
+		return false
+	}
+	var node yaml.Node
+	if err := yaml.Unmarshal([]byte("- "+value), &node); err != nil || len(node.Content) == 0 {
+		return false
+	}
+	items := node.Content[0].Content
+	return len(items) == 1 && items[0].Kind == yaml.ScalarNode && items[0].Tag == "!!str" && items[0].Value == value
+}
+
+// InsertBefore inserts the lines before the one of the node, and the comments
+// above it, indented as the node. The node must start its line, or only follow
+// the dash of its sequence item, which then starts the first line inserted.
+func (f *File) InsertBefore(node *yaml.Node, lines ...string) error {
+	start, err := f.offset(node)
+	if err != nil {
+		return err
+	}
+	lineStart := f.lines[node.Line-1]
+	prefix := string(f.contents[lineStart:start])
+	if trimmed := strings.TrimSpace(prefix); trimmed != "" && trimmed != "-" {
+		return errors.Errorf("cannot insert before the value at line %d of the manifest", node.Line)
+	}
+	var text strings.Builder
+	for i, line := range lines {
+		if i == 0 {
+			text.WriteString(prefix)
+		} else {
+			text.WriteString(strings.Repeat(" ", utf8.RuneCountInString(prefix)))
+		}
+		text.WriteString(line + "\n")
+	}
+	first := node.Line - 1
+	for first > 0 && bytes.HasPrefix(bytes.TrimSpace(f.contents[f.lines[first-1]:f.lines[first]]), []byte("#")) {
+		first--
+	}
+	f.insertions = append(f.insertions, insertion{offset: f.lines[first], text: text.String()})
+	return nil
+}
@@ -0,0 +1,42 @@
+package manifest
+
+import (
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// SetKubernetesVersion sets the Kubernetes version of the Machine and
+// ExistingInfraCluster objects of the provided manifest, leaving the rest of
+// it, including comments and formatting, untouched. Machines without a version
+// are given one, whereas clusters are only updated if they declare one, as
+// they otherwise use the version of their machines.
+func SetKubernetesVersion(contents []byte, version string) ([]byte, error) {
+	f, err := ParseFile(contents)
+	if err != nil {
+		return nil, err
+	}
+	for _, object := range f.Objects {
+		spec := Field(object, "spec")
+		switch Scalar(object, "kind") {
+		case "Machine":
+			if spec == nil || spec.Kind != yaml.MappingNode {
+				return nil, errors.Errorf("Machine at line %d has no spec", object.Line)
+			}
+			if v := Field(spec, "version"); v != nil && v.Kind == yaml.ScalarNode {
+				f.Set(spec, v, version)
+				continue
+			}
+			if len(spec.Content) == 0 || spec.Style&yaml.FlowStyle != 0 {
+				return nil, errors.Errorf("cannot add a version to the spec of the Machine at line %d", object.Line)
+			}
+			if err := f.InsertBefore(spec.Content[0], "version: "+formatScalar(version, 0, false)); err != nil {
+				return nil, err
+			}
+		case "ExistingInfraCluster":
+			if v := Field(spec, "kubernetesVersion"); v != nil && v.Kind == yaml.ScalarNode {
+				f.Set(spec, v, version)
+			}
+		}
+	}
+	return f.Bytes()
+}
@@ -0,0 +1,119 @@
+package manifest
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const machines = `# Masters first.
+apiVersion: cluster.x-k8s.io/v1alpha3
+kind: Machine
+metadata:
+  name: master-1
+spec:
+  clusterName: example
+  version: 1.18.15 # Pinned.
+---
+apiVersion: cluster.x-k8s.io/v1alpha3
+kind: Machine
+metadata:
+  name: node-1
+spec:
+    clusterName: example
+    version: "1.18.15"
+---
+apiVersion: cluster.x-k8s.io/v1alpha3
+kind: Machine
+metadata:
+  name: node-2
+spec:
+  clusterName: example
+---
+apiVersion: cluster.weave.works/v1alpha3
+kind: ExistingInfraMachine
+metadata:
+  name: master-1
+spec:
+  version: not-a-kubernetes-version
+`
+
+const expectedMachines = `# Masters first.
+apiVersion: cluster.x-k8s.io/v1alpha3
+kind: Machine
+metadata:
+  name: master-1
+spec:
+  clusterName: example
+  version: 1.19.7 # Pinned.
+---
+apiVersion: cluster.x-k8s.io/v1alpha3
+kind: Machine
+metadata:
+  name: node-1
+spec:
+    clusterName: example
+    version: "1.19.7"
+---
+apiVersion: cluster.x-k8s.io/v1alpha3
+kind: Machine
+metadata:
+  name: node-2
+spec:
+  version: 1.19.7
+  clusterName: example
+---
+apiVersion: cluster.weave.works/v1alpha3
+kind: ExistingInfraMachine
+metadata:
+  name: master-1
+spec:
+  version: not-a-kubernetes-version
+`
+
+func TestSetKubernetesVersionOfMachines(t *testing.T) {
+	out, err := SetKubernetesVersion([]byte(machines), "1.19.7")
+	require.NoError(t, err)
+	assert.Equal(t, expectedMachines, string(out))
+}
+
+func TestSetKubernetesVersionOfCluster(t *testing.T) {
+	cluster := `apiVersion: cluster.weave.works/v1alpha3
+kind: ExistingInfraCluster
+metadata:
+  name: example
+spec:
+  cri:
+    kind: docker
+    version: 19.03.8
+  kubernetesVersion: '1.18.15'
+`
+	out, err := SetKubernetesVersion([]byte(cluster), "1.19.7")
+	require.NoError(t, err)
+	assert.Equal(t, `apiVersion: cluster.weave.works/v1alpha3
+kind: ExistingInfraCluster
+metadata:
+  name: example
+spec:
+  cri:
+    kind: docker
+    version: 19.03.8
+  kubernetesVersion: '1.19.7'
+`, string(out))
+
+	// Clusters without a version use the version of their machines.
+	cluster = `kind: ExistingInfraCluster
+spec:
+  cri:
+    version: 19.03.8
+`
+	out, err = SetKubernetesVersion([]byte(cluster), "1.19.7")
+	require.NoError(t, err)
+	assert.Equal(t, cluster, string(out))
+}
+
+func TestSetKubernetesVersionOfFlowSpec(t *testing.T) {
+	_, err := SetKubernetesVersion([]byte("kind: Machine\nspec: {}\n"), "1.19.7")
+	assert.Error(t, err)
+}
@@ -50,3 +50,15 @@ func CreateDirectory(path string) (string, error) {
 func Kubeconfig(artifactDirectory, ns, clusterName string) string {
 	return filepath.Join(WKSResourcePath(artifactDirectory, ns, clusterName), "kubeconfig")
 }
+
+// ApplyJournal returns the path of the journal of the last apply of the
+// provided cluster.
+func ApplyJournal(artifactDirectory, ns, clusterName string) string {
+	return filepath.Join(WKSResourcePath(artifactDirectory, ns, clusterName), "apply-journal.json")
+}
+
+// VersionCheckCache returns the path of the cached result of the last check
+// for newer versions of wksctl.
+func VersionCheckCache(artifactDirectory string) string {
+	return WKSResourcePath(artifactDirectory, "checkpoint-cache")
+}
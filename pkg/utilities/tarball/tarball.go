@@ -1,8 +1,15 @@
 package tarball
 
 import (
+	"archive/tar"
+	"compress/bzip2"
+	"compress/gzip"
 	"fmt"
+	"io"
+	"os"
 	"os/exec"
+	"path/filepath"
+	"strings"
 
 	"github.com/weaveworks/wksctl/pkg/utilities"
 )
@@ -24,11 +31,134 @@ func (t *Tarball) Check() error {
 	return nil
 }
 
+// Unpack extracts the tarball to destDir, refusing entries, and symbolic
+// links, leading outside of it.
 func (t *Tarball) Unpack(destDir string) error {
-	flags := fmt.Sprintf("-x%s", t.Compression)
-	out, err := exec.Command("tar", flags, "-f", t.Path, "-C", destDir).CombinedOutput()
+	f, err := os.Open(t.Path)
 	if err != nil {
-		return fmt.Errorf("tar failed: %v; combined output:\n%s", err, utilities.Indent(string(out), "\t"))
+		return err
+	}
+	defer f.Close()
+	var r io.Reader = f
+	switch t.Compression {
+	case "":
+	case "z":
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return err
+		}
+		defer gz.Close()
+		r = gz
+	case "j":
+		r = bzip2.NewReader(f)
+	default:
+		return fmt.Errorf("unsupported compression %q", t.Compression)
+	}
+	return Extract(r, destDir)
+}
+
+// Extract extracts the tar archive read from r to dir. Symbolic links must
+// point inside dir, and files are never written through them.
+func Extract(r io.Reader, dir string) error {
+	dir = filepath.Clean(dir)
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return checkSymlinks(dir)
+		}
+		if err != nil {
+			return err
+		}
+		path := filepath.Join(dir, filepath.FromSlash(hdr.Name))
+		if !within(dir, path) || path == dir {
+			return fmt.Errorf("invalid path %q in archive", hdr.Name)
+		}
+		if err := checkNoSymlinkIn(dir, path); err != nil {
+			return err
+		}
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(path, 0755); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+				return err
+			}
+			f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, os.FileMode(hdr.Mode).Perm())
+			if err != nil {
+				return err
+			}
+			_, err = io.Copy(f, tr)
+			if closeErr := f.Close(); err == nil {
+				err = closeErr
+			}
+			if err != nil {
+				return err
+			}
+		case tar.TypeSymlink:
+			target := filepath.FromSlash(hdr.Linkname)
+			if filepath.IsAbs(target) || !within(dir, filepath.Join(filepath.Dir(path), target)) {
+				return fmt.Errorf("symbolic link %q in archive points outside of it, to %q", hdr.Name, hdr.Linkname)
+			}
+			if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+				return err
+			}
+			if err := os.Symlink(target, path); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// within returns whether the clean path is dir or under it.
+func within(dir, path string) bool {
+	return path == dir || strings.HasPrefix(path, dir+string(os.PathSeparator))
+}
+
+// checkNoSymlinkIn returns an error if path, or one of its directories under
+// dir, is a symbolic link, through which files would be written elsewhere.
+func checkNoSymlinkIn(dir, path string) error {
+	for p := path; p != dir; p = filepath.Dir(p) {
+		info, err := os.Lstat(p)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if info.Mode()&os.ModeSymlink != 0 {
+			rel, _ := filepath.Rel(dir, path)
+			return fmt.Errorf("invalid path %q in archive, through symbolic link %q", filepath.ToSlash(rel), filepath.Base(p))
+		}
 	}
 	return nil
 }
+
+// checkSymlinks returns an error if one of the symbolic links extracted to dir
+// resolves outside of it, through other links.
+func checkSymlinks(dir string) error {
+	realDir, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		return err
+	}
+	return filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.Mode()&os.ModeSymlink == 0 {
+			return err
+		}
+		resolved, err := filepath.EvalSymlinks(path)
+		if os.IsNotExist(err) {
+			// Dangling links lead nowhere.
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if !within(realDir, resolved) {
+			rel, _ := filepath.Rel(dir, path)
+			return fmt.Errorf("symbolic link %q in archive points outside of it", filepath.ToSlash(rel))
+		}
+		return nil
+	})
+}
@@ -0,0 +1,105 @@
+package tarball
+
+import (
+	"archive/tar"
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type entry struct {
+	name, link, contents string
+}
+
+func archive(t *testing.T, entries ...entry) *bytes.Buffer {
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, e := range entries {
+		hdr := &tar.Header{Name: e.name, Mode: 0644, Typeflag: tar.TypeReg, Size: int64(len(e.contents))}
+		if e.link != "" {
+			hdr.Typeflag, hdr.Linkname, hdr.Size = tar.TypeSymlink, e.link, 0
+		}
+		require.NoError(t, tw.WriteHeader(hdr))
+		_, err := tw.Write([]byte(e.contents))
+		require.NoError(t, err)
+	}
+	require.NoError(t, tw.Close())
+	return &buf
+}
+
+func TestExtract(t *testing.T) {
+	dir, err := ioutil.TempDir("", "extract")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	require.NoError(t, Extract(archive(t,
+		entry{name: "config/repo.yaml", contents: "repo"},
+		entry{name: "machines.yaml", link: "config/repo.yaml"},
+		entry{name: "config/self", link: "."},
+		entry{name: "dangling", link: "missing"},
+	), dir))
+	contents, err := ioutil.ReadFile(filepath.Join(dir, "machines.yaml"))
+	require.NoError(t, err)
+	assert.Equal(t, "repo", string(contents))
+}
+
+func TestExtractRefusesEscapes(t *testing.T) {
+	tests := []struct {
+		name    string
+		entries []entry
+		err     string
+	}{
+		{
+			name:    "path outside",
+			entries: []entry{{name: "../escaped", contents: "x"}},
+			err:     `invalid path "../escaped"`,
+		},
+		{
+			name:    "absolute link",
+			entries: []entry{{name: "etc", link: "/etc"}},
+			err:     `symbolic link "etc" in archive points outside of it`,
+		},
+		{
+			name:    "link outside",
+			entries: []entry{{name: "config/up", link: "../../.."}},
+			err:     `symbolic link "config/up" in archive points outside of it`,
+		},
+		{
+			name: "write through a link",
+			entries: []entry{
+				{name: "config", link: "."},
+				{name: "config/machines.yaml", contents: "x"},
+			},
+			err: `invalid path "config/machines.yaml" in archive, through symbolic link "config"`,
+		},
+		{
+			name: "link outside through another link",
+			entries: []entry{
+				{name: "b", link: "a/.."},
+				{name: "a", link: "."},
+			},
+			err: `symbolic link "b" in archive points outside of it`,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			parent, err := ioutil.TempDir("", "extract")
+			require.NoError(t, err)
+			defer os.RemoveAll(parent)
+			dir := filepath.Join(parent, "dir")
+			require.NoError(t, os.Mkdir(dir, 0755))
+
+			err = Extract(archive(t, test.entries...), dir)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), test.err)
+			files, err := ioutil.ReadDir(parent)
+			require.NoError(t, err)
+			assert.Len(t, files, 1, "files were written outside of the directory")
+		})
+	}
+}
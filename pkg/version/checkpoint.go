@@ -0,0 +1,94 @@
+package version
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/weaveworks/go-checkpoint"
+)
+
+// DisableCheckEnv is the environment variable which, set to any value,
+// disables the check for newer versions of wksctl.
+const DisableCheckEnv = "WKSCTL_DISABLE_CHECKPOINT"
+
+const (
+	// checkTimeout is how long the check can delay the exit of commands.
+	checkTimeout = 2 * time.Second
+	// checkCacheDuration is how long the result of a check, or its failure,
+	// is reused for.
+	checkCacheDuration = 24 * time.Hour
+)
+
+// check is checkpoint.Check, replaced by tests.
+var check = checkpoint.Check
+
+// CheckDisabled returns whether the environment disables the check for newer
+// versions of wksctl.
+func CheckDisabled() bool {
+	return os.Getenv(DisableCheckEnv) != "" || checkpoint.IsCheckDisabled()
+}
+
+// StartCheck starts checking, in the background, whether a newer version of
+// wksctl is available, reusing the result cached in cacheFile for a day. The
+// returned function waits for the check, until checkTimeout after it started,
+// and logs the newer version, if any. A check which fails or times out isn't
+// run again for a day either.
+func StartCheck(cacheFile string) (report func()) {
+	failureFile := cacheFile + ".failed"
+	if checkFailedRecently(failureFile) {
+		log.Debugf("Skipped checking for a newer version of wksctl, which failed less than %v ago", checkCacheDuration)
+		return func() {}
+	}
+	responses := make(chan *checkpoint.CheckResponse, 1)
+	deadline := time.Now().Add(checkTimeout)
+	go func() {
+		response, err := check(&checkpoint.CheckParams{
+			Product:       "wksctl",
+			Version:       Version,
+			CacheFile:     cacheFile,
+			CacheDuration: checkCacheDuration,
+		})
+		if err != nil {
+			log.Debugf("Failed to check for a newer version of wksctl: %v", err)
+		}
+		responses <- response
+	}()
+	return func() {
+		select {
+		case response := <-responses:
+			if response == nil {
+				recordCheckFailure(failureFile)
+				return
+			}
+			os.Remove(failureFile)
+			if response.Outdated {
+				log.Infof("wksctl version %s is available; please update at %s",
+					response.CurrentVersion, response.CurrentDownloadURL)
+			}
+		case <-time.After(time.Until(deadline)):
+			log.Debugf("Gave up checking for a newer version of wksctl after %v", checkTimeout)
+			recordCheckFailure(failureFile)
+		}
+	}
+}
+
+// checkFailedRecently returns whether failureFile records a check which failed
+// less than checkCacheDuration ago.
+func checkFailedRecently(failureFile string) bool {
+	fi, err := os.Stat(failureFile)
+	return err == nil && time.Since(fi.ModTime()) < checkCacheDuration
+}
+
+// recordCheckFailure records in failureFile that a check failed now.
+func recordCheckFailure(failureFile string) {
+	if err := os.MkdirAll(filepath.Dir(failureFile), 0755); err != nil {
+		log.Debugf("Failed to record the failure of the check for a newer version of wksctl: %v", err)
+		return
+	}
+	if err := ioutil.WriteFile(failureFile, []byte(time.Now().Format(time.RFC3339)+"\n"), 0644); err != nil {
+		log.Debugf("Failed to record the failure of the check for a newer version of wksctl: %v", err)
+	}
+}
@@ -0,0 +1,76 @@
+package version
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/weaveworks/go-checkpoint"
+)
+
+func stubCheck(t *testing.T, cacheFile string, response *checkpoint.CheckResponse, err error, delay time.Duration) {
+	check = func(p *checkpoint.CheckParams) (*checkpoint.CheckResponse, error) {
+		assert.Equal(t, "wksctl", p.Product)
+		assert.Equal(t, cacheFile, p.CacheFile)
+		assert.Equal(t, 24*time.Hour, p.CacheDuration)
+		time.Sleep(delay)
+		return response, err
+	}
+}
+
+func TestStartCheck(t *testing.T) {
+	defer func() { check = checkpoint.Check }()
+	hook := test.NewGlobal()
+	log.SetLevel(log.DebugLevel)
+	defer log.SetLevel(log.InfoLevel)
+	dir, err := ioutil.TempDir("", "wksctl-checkpoint")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	cacheFile := filepath.Join(dir, "checkpoint-cache")
+
+	stubCheck(t, cacheFile, &checkpoint.CheckResponse{Outdated: true, CurrentVersion: "0.9.0", CurrentDownloadURL: "https://example.com"}, nil, 0)
+	StartCheck(cacheFile)()
+	assert.Equal(t, log.InfoLevel, hook.LastEntry().Level)
+	assert.Equal(t, "wksctl version 0.9.0 is available; please update at https://example.com", hook.LastEntry().Message)
+	hook.Reset()
+
+	stubCheck(t, cacheFile, &checkpoint.CheckResponse{}, nil, 0)
+	StartCheck(cacheFile)()
+	assert.Empty(t, hook.AllEntries())
+
+	stubCheck(t, cacheFile, nil, errors.New("no route to host"), 0)
+	StartCheck(cacheFile)()
+	assert.Equal(t, "Failed to check for a newer version of wksctl: no route to host", hook.LastEntry().Message)
+	hook.Reset()
+
+	// Failed checks aren't run again for a day.
+	stubCheck(t, cacheFile, &checkpoint.CheckResponse{Outdated: true}, nil, 0)
+	StartCheck(cacheFile)()
+	assert.Equal(t, "Skipped checking for a newer version of wksctl, which failed less than 24h0m0s ago", hook.LastEntry().Message)
+	hook.Reset()
+	old := time.Now().Add(-25 * time.Hour)
+	require.NoError(t, os.Chtimes(cacheFile+".failed", old, old))
+
+	stubCheck(t, cacheFile, &checkpoint.CheckResponse{Outdated: true}, nil, time.Hour)
+	start := time.Now()
+	StartCheck(cacheFile)()
+	assert.WithinDuration(t, start.Add(checkTimeout), time.Now(), time.Second)
+	assert.Equal(t, "Gave up checking for a newer version of wksctl after 2s", hook.LastEntry().Message)
+	assert.True(t, checkFailedRecently(cacheFile+".failed"))
+}
+
+func TestCheckDisabled(t *testing.T) {
+	defer os.Unsetenv(DisableCheckEnv)
+	os.Unsetenv(DisableCheckEnv)
+	os.Unsetenv("CHECKPOINT_DISABLE")
+	assert.False(t, CheckDisabled())
+	os.Setenv(DisableCheckEnv, "1")
+	assert.True(t, CheckDisabled())
+}
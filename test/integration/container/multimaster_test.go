@@ -426,6 +426,7 @@ func TestMultimasterSetup(t *testing.T) {
 			runShowingOutput(t, filepath.Join(rootDir, "cmd/wksctl/wksctl"), "apply",
 				fmt.Sprintf("--cluster=%s", clusterYAMLFile), fmt.Sprintf("--machines=%s", machinesYAMLFile),
 				fmt.Sprintf("--config-directory=%s", testTempDir),
+				fmt.Sprintf("--controller-ssh-key=%s", filepath.Join(testTempDir, "cluster-key")),
 				"--verbose",
 				fmt.Sprintf("--controller-image=%s", capeiImage))
 
@@ -423,13 +423,13 @@ func TestApply(t *testing.T) {
 	// Fail to install the cluster.
 	run, _ := apply(exe, "--cluster="+clusterManifestPath, "--machines="+badMachinesManifestPath,
 		"--config-directory="+configDir, "--sealed-secret-key="+configPath("ss.key"), "--sealed-secret-cert="+configPath("ss.cert"),
-		"--verbose=true", "--ssh-key="+sshKeyPath)
+		"--verbose=true", "--ssh-key="+sshKeyPath, "--controller-ssh-key="+sshKeyPath)
 	assert.Equal(t, 1, run.ExitCode())
 
 	// Install the Cluster.
 	run, err = apply(exe, "--cluster="+clusterManifestPath, "--machines="+machinesManifestPath,
 		"--config-directory="+configDir, "--sealed-secret-key="+configPath("ss.key"), "--sealed-secret-cert="+configPath("ss.cert"),
-		"--verbose=true", "--ssh-key="+sshKeyPath, "--controller-image=docker.io/weaveworks/cluster-api-existinginfra-controller:v0.2.2")
+		"--verbose=true", "--ssh-key="+sshKeyPath, "--controller-ssh-key="+sshKeyPath, "--controller-image=docker.io/weaveworks/cluster-api-existinginfra-controller:v0.2.2")
 	assert.NoError(t, err)
 	require.Equal(t, 0, run.ExitCode())
 